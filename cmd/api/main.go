@@ -4,9 +4,23 @@ import (
 	"context"
 	app "debt-manager/internal"
 	"debt-manager/internal/config"
+	apphttp "debt-manager/internal/http"
+	"debt-manager/internal/http/handlers"
+	"debt-manager/internal/jwtkeys"
+	"debt-manager/internal/mail"
+	"debt-manager/internal/maintenance"
+	"debt-manager/internal/oidc"
+	"debt-manager/internal/ratelimit"
+	"expvar"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/joho/godotenv"
 )
 
@@ -21,13 +35,131 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	var mailer mail.Sender
+	switch cfg.MailDriver {
+	case "log":
+		mailer = mail.LogSender{}
+	case "smtp":
+		mailer = mail.SMTPSender{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}
+	default:
+		log.Fatalf("unknown MAIL_DRIVER %q", cfg.MailDriver)
+	}
+
+	emailPolicy, err := handlers.NewEmailPolicy(cfg.VerifiedEmailRequiredFor)
+	if err != nil {
+		log.Fatal("invalid VERIFIED_EMAIL_REQUIRED_FOR:", err)
+	}
+
+	oidcProviders := map[string]*oidc.Provider{}
+	for _, p := range cfg.OIDCProviders {
+		redirect := *cfg.BaseURL
+		redirect.Path += "/auth/oidc/" + p.Name + "/callback"
+		oidcProviders[p.Name] = oidc.NewProvider(oidc.ProviderConfig{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			Scopes:       p.Scopes,
+			RedirectURL:  redirect.String(),
+		})
+	}
+
+	keys, err := jwtkeys.Load(jwtkeys.Config{
+		Dir:          cfg.JWTKeysDir,
+		SigningKeyID: cfg.JWTSigningKeyID,
+		HMACSecret:   []byte(cfg.JWTSecretKey),
+	})
+	if err != nil {
+		log.Fatal("cannot load jwt keys:", err)
+	}
+
+	// SIGHUP picks up added or removed key files without dropping live
+	// tokens.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := keys.Reload(); err != nil {
+				log.Println("failed to reload jwt keys:", err)
+				continue
+			}
+			log.Println("jwt keys reloaded")
+		}
+	}()
+
+	webAuthn, err := webauthn.New(&webauthn.Config{
+		RPID:                  cfg.WebAuthnRPID,
+		RPDisplayName:         cfg.WebAuthnRPName,
+		RPOrigins:             cfg.WebAuthnRPOrigins,
+		AttestationPreference: protocol.ConveyancePreference(cfg.WebAuthnAttestation),
+	})
+	if err != nil {
+		log.Fatal("invalid webauthn config:", err)
+	}
+
 	DBDSN := "postgres://" + cfg.DBUser + ":" + cfg.DBPassword + "@" + cfg.DBHost + ":" + cfg.DBPort + "/" + cfg.DBName
-	a, err := app.New(ctx, DBDSN, []byte(cfg.JWTSecretKey))
+	a, err := app.New(ctx, DBDSN, app.Options{
+		Keys:           keys,
+		Argon2: handlers.Argon2Params{
+			Time:    cfg.Argon2Time,
+			Memory:  cfg.Argon2MemoryKiB,
+			Threads: cfg.Argon2Threads,
+			KeyLen:  handlers.DefaultArgon2.KeyLen,
+			SaltLen: handlers.DefaultArgon2.SaltLen,
+		},
+		Mailer:         mailer,
+		EmailPolicy:    emailPolicy,
+		OIDCProviders:  oidcProviders,
+		RateLimitStore: cfg.RateLimitStore,
+		RefreshGrace:   cfg.RefreshGrace,
+		CookieMode:     cfg.CookieMode,
+		WebAuthn:       webAuthn,
+		AppURL:         cfg.AppURL,
+		CORS: apphttp.CORS{
+			AllowedOrigins:   cfg.CORSAllowedOrigins,
+			AllowCredentials: cfg.CORSAllowCredentials,
+			MaxAge:           cfg.CORSMaxAge,
+		},
+		Lockout: ratelimit.Lockout{
+			Threshold: 5,
+			Base:      time.Minute,
+			Max:       time.Hour,
+			Window:    24 * time.Hour,
+		},
+	})
 	if err != nil {
 		log.Fatal("cannot create app:", err)
 	}
 	defer a.Close()
 
+	if cfg.MaintenanceInterval > 0 {
+		worker := &maintenance.Worker{
+			Tx: a.Server.Tx,
+			Config: maintenance.Config{
+				Retention:     cfg.MaintenanceRetention,
+				RateLimitIdle: maintenance.DefaultConfig.RateLimitIdle,
+				Interval:      cfg.MaintenanceInterval,
+				BatchSize:     cfg.MaintenanceBatchSize,
+			},
+		}
+		go worker.Run(ctx)
+	}
+
+	// Metrics are served on their own address so they are not public.
+	if cfg.MetricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/debug/vars", expvar.Handler())
+		go func() {
+			log.Println("metrics server stopped:", http.ListenAndServe(cfg.MetricsAddr, metricsMux))
+		}()
+	}
+
 	log.Printf("starting server at :%s...", cfg.Port)
 	http.ListenAndServe(":"+cfg.Port, a.Mux)
 
@@ -0,0 +1,84 @@
+// Command maintenance purges expired sessions, tokens and invitations.
+//
+//	maintenance once    purge once, print what was deleted and exit
+//	maintenance worker  purge every MAINTENANCE_INTERVAL until interrupted
+//
+// The API runs the worker itself unless MAINTENANCE_INTERVAL is 0; this
+// command is for running it from cron or by hand instead.
+package main
+
+import (
+	"context"
+	"debt-manager/internal/config"
+	"debt-manager/internal/db"
+	"debt-manager/internal/maintenance"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: maintenance once|worker")
+	os.Exit(2)
+}
+
+func main() {
+	godotenv.Load()
+	if len(os.Args) != 2 {
+		usage()
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("cannot load config:", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	DBDSN := "postgres://" + cfg.DBUser + ":" + cfg.DBPassword + "@" + cfg.DBHost + ":" + cfg.DBPort + "/" + cfg.DBName
+	pool, err := pgxpool.New(ctx, DBDSN)
+	if err != nil {
+		log.Fatal("cannot connect to database:", err)
+	}
+	defer pool.Close()
+
+	worker := &maintenance.Worker{
+		Tx: db.NewTxRunner(pool),
+		Config: maintenance.Config{
+			Retention:     cfg.MaintenanceRetention,
+			RateLimitIdle: maintenance.DefaultConfig.RateLimitIdle,
+			Interval:      cfg.MaintenanceInterval,
+			BatchSize:     cfg.MaintenanceBatchSize,
+		},
+	}
+
+	switch os.Args[1] {
+	case "once":
+		res, err := worker.RunOnce(ctx)
+		names := make([]string, 0, len(res))
+		for name := range res {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%-24s %d\n", name, res[name])
+		}
+		if err != nil {
+			log.Fatal("maintenance failed:", err)
+		}
+	case "worker":
+		if worker.Config.Interval <= 0 {
+			log.Fatal("MAINTENANCE_INTERVAL must be positive for the worker")
+		}
+		worker.Run(ctx)
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,352 @@
+// Command softauthn is a software passkey for trying out and testing the
+// WebAuthn endpoints without a browser. It keeps its key in a file and answers
+// the API's challenges the way a platform authenticator with "none"
+// attestation would. The key file is not protected, so use it for test
+// accounts only.
+//
+// Register a passkey for a signed in user, with an access token from
+// /auth/login:
+//
+//	go run ./cmd/softauthn register -token <access token>
+//
+// then log in with it:
+//
+//	go run ./cmd/softauthn login
+//
+// The origin must be one of WEBAUTHN_RP_ORIGINS; it defaults to the API URL,
+// which matches the API's defaults.
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+)
+
+// Authenticator data flags, see the WebAuthn spec section 6.1.
+const (
+	flagUserPresent        = 0x01
+	flagUserVerified       = 0x04
+	flagAttestedCredential = 0x40
+)
+
+var b64 = base64.RawURLEncoding
+
+// passkey is what the key file holds.
+type passkey struct {
+	RPID         string `json:"rp_id"`
+	CredentialID string `json:"credential_id"`
+	UserHandle   string `json:"user_handle"`
+	PrivateKey   string `json:"private_key"`
+	SignCount    uint32 `json:"sign_count"`
+}
+
+type client struct {
+	api    string
+	origin string
+	token  string
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	api := fs.String("api", "http://localhost:8080", "API base URL")
+	origin := fs.String("origin", "", "origin reported to the API (default: the API URL)")
+	keyFile := fs.String("key", "passkey.json", "key file")
+	token := fs.String("token", "", "access token (register)")
+	name := fs.String("name", "softauthn", "passkey name (register)")
+	email := fs.String("email", "", "email to log in as; empty for a discoverable login (login)")
+	fs.Parse(os.Args[2:])
+
+	c := &client{api: strings.TrimSuffix(*api, "/"), origin: *origin, token: *token}
+	if c.origin == "" {
+		u, err := url.Parse(c.api)
+		if err != nil {
+			log.Fatal("invalid API URL:", err)
+		}
+		c.origin = u.Scheme + "://" + u.Host
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "register":
+		if c.token == "" {
+			log.Fatal("register needs -token")
+		}
+		err = c.register(*keyFile, *name)
+	case "login":
+		err = c.login(*keyFile, *email)
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: softauthn register|login [flags]")
+	os.Exit(2)
+}
+
+func (c *client) register(keyFile, name string) error {
+	var start struct {
+		ChallengeID string `json:"challenge_id"`
+		Options     struct {
+			PublicKey struct {
+				Challenge string `json:"challenge"`
+				RP        struct {
+					ID string `json:"id"`
+				} `json:"rp"`
+				User struct {
+					ID string `json:"id"`
+				} `json:"user"`
+			} `json:"publicKey"`
+		} `json:"options"`
+	}
+	if err := c.post("/me/webauthn/register/start", nil, &start); err != nil {
+		return err
+	}
+	opts := start.Options.PublicKey
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	credentialID := make([]byte, 32)
+	if _, err := rand.Read(credentialID); err != nil {
+		return err
+	}
+
+	coseKey, err := webauthncbor.Marshal(map[int]any{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		return err
+	}
+
+	authData := authenticatorData(opts.RP.ID, flagUserPresent|flagUserVerified|flagAttestedCredential, 0)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(credentialID)))
+	authData = append(authData, credentialID...)
+	authData = append(authData, coseKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		return err
+	}
+
+	clientData, err := c.clientData("webauthn.create", opts.Challenge)
+	if err != nil {
+		return err
+	}
+
+	var created map[string]any
+	err = c.post("/me/webauthn/register/finish", map[string]any{
+		"challenge_id": start.ChallengeID,
+		"name":         name,
+		"credential": map[string]any{
+			"id":    b64.EncodeToString(credentialID),
+			"rawId": b64.EncodeToString(credentialID),
+			"type":  "public-key",
+			"response": map[string]any{
+				"clientDataJSON":    b64.EncodeToString(clientData),
+				"attestationObject": b64.EncodeToString(attestation),
+				"transports":        []string{"internal"},
+			},
+		},
+	}, &created)
+	if err != nil {
+		return err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := savePasskey(keyFile, passkey{
+		RPID:         opts.RP.ID,
+		CredentialID: b64.EncodeToString(credentialID),
+		UserHandle:   opts.User.ID,
+		PrivateKey:   b64.EncodeToString(der),
+	}); err != nil {
+		return err
+	}
+
+	log.Printf("registered passkey %v, key saved to %s", created["id"], keyFile)
+	return nil
+}
+
+func (c *client) login(keyFile, email string) error {
+	pk, err := loadPasskey(keyFile)
+	if err != nil {
+		return err
+	}
+	der, err := b64.DecodeString(pk.PrivateKey)
+	if err != nil {
+		return err
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return err
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return errors.New("key file does not hold an ECDSA key")
+	}
+
+	var start struct {
+		ChallengeID string `json:"challenge_id"`
+		Options     struct {
+			PublicKey struct {
+				Challenge string `json:"challenge"`
+				RPID      string `json:"rpId"`
+			} `json:"publicKey"`
+		} `json:"options"`
+	}
+	if err := c.post("/auth/webauthn/login/start", map[string]string{"email": email}, &start); err != nil {
+		return err
+	}
+	if start.Options.PublicKey.RPID != pk.RPID {
+		return fmt.Errorf("passkey is for %q, the API asks for %q", pk.RPID, start.Options.PublicKey.RPID)
+	}
+
+	pk.SignCount++
+	authData := authenticatorData(pk.RPID, flagUserPresent|flagUserVerified, pk.SignCount)
+	clientData, err := c.clientData("webauthn.get", start.Options.PublicKey.Challenge)
+	if err != nil {
+		return err
+	}
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return err
+	}
+
+	var tokens json.RawMessage
+	err = c.post("/auth/webauthn/login/finish", map[string]any{
+		"challenge_id": start.ChallengeID,
+		"credential": map[string]any{
+			"id":    pk.CredentialID,
+			"rawId": pk.CredentialID,
+			"type":  "public-key",
+			"response": map[string]any{
+				"clientDataJSON":    b64.EncodeToString(clientData),
+				"authenticatorData": b64.EncodeToString(authData),
+				"signature":         b64.EncodeToString(signature),
+				"userHandle":        pk.UserHandle,
+			},
+		},
+	}, &tokens)
+	if err != nil {
+		return err
+	}
+
+	if err := savePasskey(keyFile, pk); err != nil {
+		return err
+	}
+	fmt.Println(string(tokens))
+	return nil
+}
+
+// authenticatorData is the fixed part of the authenticator data: the RP ID
+// hash, the flags and the signature counter.
+func authenticatorData(rpID string, flags byte, signCount uint32) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, signCount)
+}
+
+func (c *client) clientData(typ, challenge string) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        typ,
+		"challenge":   challenge,
+		"origin":      c.origin,
+		"crossOrigin": false,
+	})
+}
+
+// post sends body as JSON and decodes the response into v. Native client
+// mode keeps the refresh token in the response body.
+func (c *client) post(path string, body, v any) error {
+	var reqBody io.Reader = http.NoBody
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.api+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-Type", "native")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s: %s: %s", path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, v)
+}
+
+func loadPasskey(file string) (passkey, error) {
+	var pk passkey
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return pk, err
+	}
+	err = json.Unmarshal(data, &pk)
+	return pk, err
+}
+
+func savePasskey(file string, pk passkey) error {
+	data, err := json.MarshalIndent(pk, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0o600)
+}
@@ -5,9 +5,17 @@ import (
 	"debt-manager/internal/db"
 	"debt-manager/internal/http"
 	"debt-manager/internal/http/handlers"
+	"debt-manager/internal/jwtkeys"
+	"debt-manager/internal/mail"
+	"debt-manager/internal/oidc"
+	"debt-manager/internal/ratelimit"
+	"fmt"
 	"log"
+	"net/url"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -17,18 +25,59 @@ type App struct {
 	Mux    *chi.Mux
 }
 
-func New(ctx context.Context, dsn string, jwtKey []byte) (*App, error) {
+// Options are the parts of the app that depend on configuration.
+type Options struct {
+	Keys          *jwtkeys.Set
+	Argon2        handlers.Argon2Params
+	RefreshGrace  time.Duration
+	CookieMode    bool
+	CORS          http.CORS
+	Mailer        mail.Sender
+	EmailPolicy   handlers.EmailPolicy
+	OIDCProviders map[string]*oidc.Provider
+	// RateLimitStore is "memory" or "postgres"; the latter shares limits
+	// between instances.
+	RateLimitStore string
+	Lockout        ratelimit.Lockout
+	// AppURL is the frontend that links in mail point at.
+	AppURL *url.URL
+	// WebAuthn is the relying party passkeys are registered with.
+	WebAuthn *webauthn.WebAuthn
+}
+
+func New(ctx context.Context, dsn string, opts Options) (*App, error) {
 	pool, err := pgxpool.New(ctx, dsn)
 	if err != nil {
 		return nil, err
 	}
+	tx := db.NewTxRunner(pool)
+
+	var store ratelimit.Store
+	switch opts.RateLimitStore {
+	case "memory":
+		store = ratelimit.NewMemoryStore()
+	case "postgres":
+		store = ratelimit.PostgresStore{Tx: tx}
+	default:
+		pool.Close()
+		return nil, fmt.Errorf("unknown rate limit store %q", opts.RateLimitStore)
+	}
 
 	server := &handlers.Server{
-		Tx:              db.NewTxRunner(pool),
-		HS256PrivateKey: jwtKey,
+		Tx:            tx,
+		Keys:          opts.Keys,
+		Argon2:        opts.Argon2,
+		RefreshGrace:  opts.RefreshGrace,
+		CookieMode:    opts.CookieMode,
+		Mailer:        opts.Mailer,
+		EmailPolicy:   opts.EmailPolicy,
+		OIDCProviders: opts.OIDCProviders,
+		Limiter:       &ratelimit.Limiter{Store: store, Lockout: opts.Lockout},
+		WebAuthn:      opts.WebAuthn,
+		AppURL:        opts.AppURL,
 	}
 
-	mux := http.NewMux(server)
+	mux := http.NewMux(server, opts.CORS)
 
 	return &App{
 		DB:     pool,
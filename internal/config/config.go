@@ -1,9 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -16,7 +20,47 @@ type Config struct {
 	MigrationsUser		 	string
 	MigrationsPassword 	string
 	JWTSecretKey			 	string
+	JWTKeysDir					string
+	JWTSigningKeyID			string
+	Argon2Time					uint32
+	Argon2MemoryKiB			uint32
+	Argon2Threads				uint8
+	MaintenanceInterval	time.Duration
+	MaintenanceRetention	time.Duration
+	MaintenanceBatchSize	int32
+	MetricsAddr					string
+	RefreshGrace				time.Duration
+	CookieMode					bool
+	CORSAllowedOrigins	[]string
+	CORSAllowCredentials	bool
+	CORSMaxAge					time.Duration
 	BaseURL 						*url.URL
+	// AppURL is where the frontend lives. Links in mail point at its pages,
+	// which hand the token on to the API.
+	AppURL							*url.URL
+	MailDriver					string
+	MailFrom						string
+	SMTPHost						string
+	SMTPPort						string
+	SMTPUsername				string
+	SMTPPassword				string
+	VerifiedEmailRequiredFor	[]string
+	OIDCProviders				[]OIDCProvider
+	RateLimitStore			string
+	WebAuthnRPID				string
+	WebAuthnRPName			string
+	WebAuthnRPOrigins		[]string
+	WebAuthnAttestation	string
+}
+
+// OIDCProvider is read from OIDC_<NAME>_* variables for every name listed in
+// OIDC_PROVIDERS.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
 }
 
 func baseURL(protocol, host, port string) string {
@@ -38,6 +82,11 @@ func Load() (Config, error) {
 		log.Fatalf("Invalid BASE_URL: %v", err)
 	}
 
+	appURL, err := url.Parse(getenv("APP_URL", "http://localhost:3000"))
+	if err != nil || appURL.Scheme == "" || appURL.Host == "" {
+		return Config{}, fmt.Errorf("invalid APP_URL: %q", getenv("APP_URL"))
+	}
+
 	cfg := Config{
 		Port: getenv("PORT", "8080"),
 		DBHost: getenv("DB_HOST"),
@@ -48,7 +97,89 @@ func Load() (Config, error) {
 		MigrationsUser: getenv("MIGRATIONS_USER"),
 		MigrationsPassword: getenv("MIGRATIONS_PASSWORD"),
 		JWTSecretKey: getenv("JWT_SECRET_KEY"),
+		JWTKeysDir: getenv("JWT_KEYS_DIR"),
+		JWTSigningKeyID: getenv("JWT_SIGNING_KEY_ID"),
 		BaseURL: baseURL,
+		AppURL: appURL,
+		MailDriver: getenv("MAIL_DRIVER", "log"),
+		MailFrom: getenv("MAIL_FROM", "no-reply@localhost"),
+		SMTPHost: getenv("SMTP_HOST", "localhost"),
+		SMTPPort: getenv("SMTP_PORT", "25"),
+		SMTPUsername: getenv("SMTP_USERNAME"),
+		SMTPPassword: getenv("SMTP_PASSWORD"),
+		VerifiedEmailRequiredFor: splitList(getenv("VERIFIED_EMAIL_REQUIRED_FOR", "create_invitation")),
+		RateLimitStore: getenv("RATE_LIMIT_STORE", "memory"),
+		MetricsAddr: getenv("METRICS_ADDR"),
+		CORSAllowedOrigins: splitList(getenv("CORS_ALLOWED_ORIGINS")),
+		WebAuthnRPID: getenv("WEBAUTHN_RP_ID", baseURL.Hostname()),
+		WebAuthnRPName: getenv("WEBAUTHN_RP_NAME", "Debt Manager"),
+		WebAuthnRPOrigins: splitList(getenv("WEBAUTHN_RP_ORIGINS")),
+		WebAuthnAttestation: getenv("WEBAUTHN_ATTESTATION", "none"),
+	}
+
+	// Passkeys are created on the frontend, so its origins are the ones the
+	// browser reports; without a frontend the API serves itself.
+	if len(cfg.WebAuthnRPOrigins) == 0 {
+		cfg.WebAuthnRPOrigins = cfg.CORSAllowedOrigins
+	}
+	if len(cfg.WebAuthnRPOrigins) == 0 {
+		cfg.WebAuthnRPOrigins = []string{baseURL.Scheme + "://" + baseURL.Host}
+	}
+	switch cfg.WebAuthnAttestation {
+	case "none", "indirect", "direct", "enterprise":
+	default:
+		return Config{}, fmt.Errorf("invalid WEBAUTHN_ATTESTATION: %q", cfg.WebAuthnAttestation)
+	}
+
+	argon2Time, err := getenvUint("ARGON2_TIME", 2, 32)
+	if err != nil {
+		return Config{}, err
+	}
+	argon2Memory, err := getenvUint("ARGON2_MEMORY_KIB", 64*1024, 32)
+	if err != nil {
+		return Config{}, err
+	}
+	argon2Threads, err := getenvUint("ARGON2_THREADS", 1, 8)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Argon2Time = uint32(argon2Time)
+	cfg.Argon2MemoryKiB = uint32(argon2Memory)
+	cfg.Argon2Threads = uint8(argon2Threads)
+
+	if cfg.MaintenanceInterval, err = getenvDuration("MAINTENANCE_INTERVAL", "1h"); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaintenanceRetention, err = getenvDuration("MAINTENANCE_RETENTION", "720h"); err != nil {
+		return Config{}, err
+	}
+	if cfg.RefreshGrace, err = getenvDuration("REFRESH_GRACE_PERIOD", "10s"); err != nil {
+		return Config{}, err
+	}
+	if cfg.CookieMode, err = getenvBool("AUTH_COOKIE_MODE", false); err != nil {
+		return Config{}, err
+	}
+	if cfg.CORSAllowCredentials, err = getenvBool("CORS_ALLOW_CREDENTIALS", true); err != nil {
+		return Config{}, err
+	}
+	if cfg.CORSMaxAge, err = getenvDuration("CORS_MAX_AGE", "10m"); err != nil {
+		return Config{}, err
+	}
+	batchSize, err := getenvUint("MAINTENANCE_BATCH_SIZE", 1000, 31)
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.MaintenanceBatchSize = int32(batchSize)
+
+	for _, name := range splitList(getenv("OIDC_PROVIDERS")) {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		cfg.OIDCProviders = append(cfg.OIDCProviders, OIDCProvider{
+			Name:         name,
+			Issuer:       getenv(prefix + "ISSUER"),
+			ClientID:     getenv(prefix + "CLIENT_ID"),
+			ClientSecret: getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       splitList(getenv(prefix + "SCOPES")),
+		})
 	}
 	return cfg, nil
 }
@@ -64,3 +195,50 @@ func getenv(k string, def ...string) string {
 	}
 	return d
 }
+
+// getenvUint parses a positive integer that fits in bits.
+func getenvUint(k string, def uint64, bits int) (uint64, error) {
+	v := getenv(k)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(v, 10, bits)
+	if err != nil || n == 0 {
+		return 0, fmt.Errorf("invalid %s: %q", k, v)
+	}
+	return n, nil
+}
+
+// getenvBool parses a strconv.ParseBool value such as "true" or "0".
+func getenvBool(k string, def bool) (bool, error) {
+	v := getenv(k)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %q", k, v)
+	}
+	return b, nil
+}
+
+// getenvDuration parses a time.ParseDuration value such as "90m".
+func getenvDuration(k, def string) (time.Duration, error) {
+	v := getenv(k, def)
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", k, v)
+	}
+	return d, nil
+}
+
+// splitList parses a comma separated env value, ignoring empty entries.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -2,3 +2,5 @@ package contextkeys
 
 type UserID struct{}
 type SessionID struct{}
+type TokenGrant struct{}
+type Language struct{}
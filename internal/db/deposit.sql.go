@@ -12,14 +12,18 @@ import (
 )
 
 const createDeposit = `-- name: CreateDeposit :one
-INSERT INTO deposits (amount, payer_user_id, payee_user_id, list_id) VALUES ($1, $2, $3, $4) RETURNING id, amount, created_at, payer_user_id, payee_user_id, list_id
+INSERT INTO deposits (amount, payer_user_id, payee_user_id, list_id, payer_placeholder_id, payee_placeholder_id, is_settlement)
+VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, amount, created_at, payer_user_id, payee_user_id, list_id, version, payer_placeholder_id, payee_placeholder_id, created_by, is_settlement
 `
 
 type CreateDepositParams struct {
-	Amount      pgtype.Numeric
-	PayerUserID pgtype.UUID
-	PayeeUserID pgtype.UUID
-	ListID      pgtype.UUID
+	Amount             pgtype.Numeric
+	PayerUserID        pgtype.UUID
+	PayeeUserID        pgtype.UUID
+	ListID             pgtype.UUID
+	PayerPlaceholderID pgtype.UUID
+	PayeePlaceholderID pgtype.UUID
+	IsSettlement       bool
 }
 
 func (q *Queries) CreateDeposit(ctx context.Context, arg CreateDepositParams) (Deposit, error) {
@@ -28,6 +32,9 @@ func (q *Queries) CreateDeposit(ctx context.Context, arg CreateDepositParams) (D
 		arg.PayerUserID,
 		arg.PayeeUserID,
 		arg.ListID,
+		arg.PayerPlaceholderID,
+		arg.PayeePlaceholderID,
+		arg.IsSettlement,
 	)
 	var i Deposit
 	err := row.Scan(
@@ -37,12 +44,36 @@ func (q *Queries) CreateDeposit(ctx context.Context, arg CreateDepositParams) (D
 		&i.PayerUserID,
 		&i.PayeeUserID,
 		&i.ListID,
+		&i.Version,
+		&i.PayerPlaceholderID,
+		&i.PayeePlaceholderID,
+		&i.CreatedBy,
+		&i.IsSettlement,
 	)
 	return i, err
 }
 
+const deleteDepositByID = `-- name: DeleteDepositByID :execrows
+DELETE FROM deposits
+WHERE id = $1
+  AND ($2::int IS NULL OR version = $2)
+`
+
+type DeleteDepositByIDParams struct {
+	ID              pgtype.UUID
+	ExpectedVersion pgtype.Int4
+}
+
+func (q *Queries) DeleteDepositByID(ctx context.Context, arg DeleteDepositByIDParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteDepositByID, arg.ID, arg.ExpectedVersion)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getAllDepositsForListID = `-- name: GetAllDepositsForListID :many
-SELECT id, amount, created_at, payer_user_id, payee_user_id, list_id FROM deposits WHERE list_id = $1
+SELECT id, amount, created_at, payer_user_id, payee_user_id, list_id, version, payer_placeholder_id, payee_placeholder_id, created_by, is_settlement FROM deposits WHERE list_id = $1
 `
 
 func (q *Queries) GetAllDepositsForListID(ctx context.Context, listID pgtype.UUID) ([]Deposit, error) {
@@ -61,6 +92,11 @@ func (q *Queries) GetAllDepositsForListID(ctx context.Context, listID pgtype.UUI
 			&i.PayerUserID,
 			&i.PayeeUserID,
 			&i.ListID,
+			&i.Version,
+			&i.PayerPlaceholderID,
+			&i.PayeePlaceholderID,
+			&i.CreatedBy,
+			&i.IsSettlement,
 		); err != nil {
 			return nil, err
 		}
@@ -71,3 +107,26 @@ func (q *Queries) GetAllDepositsForListID(ctx context.Context, listID pgtype.UUI
 	}
 	return items, nil
 }
+
+const getDepositByID = `-- name: GetDepositByID :one
+SELECT id, amount, created_at, payer_user_id, payee_user_id, list_id, version, payer_placeholder_id, payee_placeholder_id, created_by, is_settlement FROM deposits WHERE id = $1
+`
+
+func (q *Queries) GetDepositByID(ctx context.Context, id pgtype.UUID) (Deposit, error) {
+	row := q.db.QueryRow(ctx, getDepositByID, id)
+	var i Deposit
+	err := row.Scan(
+		&i.ID,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.PayerUserID,
+		&i.PayeeUserID,
+		&i.ListID,
+		&i.Version,
+		&i.PayerPlaceholderID,
+		&i.PayeePlaceholderID,
+		&i.CreatedBy,
+		&i.IsSettlement,
+	)
+	return i, err
+}
@@ -12,18 +12,24 @@ import (
 )
 
 const createDivision = `-- name: CreateDivision :one
-INSERT INTO public.divisions (owe_user_id, amount, payment_id)
-VALUES ($1, $2, $3) RETURNING id, amount, created_at, owe_user_id, payment_id
+INSERT INTO public.divisions (owe_user_id, amount, payment_id, owe_placeholder_id)
+VALUES ($1, $2, $3, $4) RETURNING id, amount, created_at, owe_user_id, payment_id, owe_placeholder_id
 `
 
 type CreateDivisionParams struct {
-	OweUserID pgtype.UUID
-	Amount    pgtype.Numeric
-	PaymentID pgtype.UUID
+	OweUserID        pgtype.UUID
+	Amount           pgtype.Numeric
+	PaymentID        pgtype.UUID
+	OwePlaceholderID pgtype.UUID
 }
 
 func (q *Queries) CreateDivision(ctx context.Context, arg CreateDivisionParams) (Division, error) {
-	row := q.db.QueryRow(ctx, createDivision, arg.OweUserID, arg.Amount, arg.PaymentID)
+	row := q.db.QueryRow(ctx, createDivision,
+		arg.OweUserID,
+		arg.Amount,
+		arg.PaymentID,
+		arg.OwePlaceholderID,
+	)
 	var i Division
 	err := row.Scan(
 		&i.ID,
@@ -31,12 +37,13 @@ func (q *Queries) CreateDivision(ctx context.Context, arg CreateDivisionParams)
 		&i.CreatedAt,
 		&i.OweUserID,
 		&i.PaymentID,
+		&i.OwePlaceholderID,
 	)
 	return i, err
 }
 
 const getDivisionsByPaymentID = `-- name: GetDivisionsByPaymentID :many
-SELECT id, amount, created_at, owe_user_id, payment_id FROM public.divisions WHERE payment_id = $1
+SELECT id, amount, created_at, owe_user_id, payment_id, owe_placeholder_id FROM public.divisions WHERE payment_id = $1
 `
 
 func (q *Queries) GetDivisionsByPaymentID(ctx context.Context, paymentID pgtype.UUID) ([]Division, error) {
@@ -54,6 +61,7 @@ func (q *Queries) GetDivisionsByPaymentID(ctx context.Context, paymentID pgtype.
 			&i.CreatedAt,
 			&i.OweUserID,
 			&i.PaymentID,
+			&i.OwePlaceholderID,
 		); err != nil {
 			return nil, err
 		}
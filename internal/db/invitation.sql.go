@@ -11,6 +11,22 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const acceptInvitation = `-- name: AcceptInvitation :one
+SELECT app.accept_invitation($1, $2::uuid)
+`
+
+type AcceptInvitationParams struct {
+	Hash          string
+	PlaceholderID pgtype.UUID
+}
+
+func (q *Queries) AcceptInvitation(ctx context.Context, arg AcceptInvitationParams) (pgtype.UUID, error) {
+	row := q.db.QueryRow(ctx, acceptInvitation, arg.Hash, arg.PlaceholderID)
+	var accept_invitation pgtype.UUID
+	err := row.Scan(&accept_invitation)
+	return accept_invitation, err
+}
+
 const createInvitation = `-- name: CreateInvitation :one
 INSERT INTO public.invitations (invited_to_list_id, expires_at, created_by, hash)
 VALUES ($1, $2, $3, $4) RETURNING id, hash, invited_to_list_id, expires_at, revoked_at, created_at, created_by, used_by, used_at
@@ -11,6 +11,31 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const closeList = `-- name: CloseList :one
+UPDATE lists
+SET
+  closed_at = now(),
+  closed_by = app.current_user_id(),
+  version   = version + 1
+WHERE id = $1 AND closed_at IS NULL
+RETURNING id, currency, title, created_at, version, closed_at, closed_by
+`
+
+func (q *Queries) CloseList(ctx context.Context, id pgtype.UUID) (List, error) {
+	row := q.db.QueryRow(ctx, closeList, id)
+	var i List
+	err := row.Scan(
+		&i.ID,
+		&i.Currency,
+		&i.Title,
+		&i.CreatedAt,
+		&i.Version,
+		&i.ClosedAt,
+		&i.ClosedBy,
+	)
+	return i, err
+}
+
 const createList = `-- name: CreateList :exec
 INSERT INTO lists (id, title, currency) VALUES ($1, $2, $3)
 `
@@ -27,43 +52,61 @@ func (q *Queries) CreateList(ctx context.Context, arg CreateListParams) error {
 }
 
 const createUserListRelation = `-- name: CreateUserListRelation :one
-INSERT INTO users_lists (user_id, list_id) VALUES ($1, $2) RETURNING user_id, list_id
+INSERT INTO users_lists (user_id, list_id, role) VALUES ($1, $2, $3) RETURNING user_id, list_id, role, left_at
 `
 
 type CreateUserListRelationParams struct {
 	UserID pgtype.UUID
 	ListID pgtype.UUID
+	Role   ListRole
 }
 
 func (q *Queries) CreateUserListRelation(ctx context.Context, arg CreateUserListRelationParams) (UsersList, error) {
-	row := q.db.QueryRow(ctx, createUserListRelation, arg.UserID, arg.ListID)
+	row := q.db.QueryRow(ctx, createUserListRelation, arg.UserID, arg.ListID, arg.Role)
 	var i UsersList
-	err := row.Scan(&i.UserID, &i.ListID)
+	err := row.Scan(
+		&i.UserID,
+		&i.ListID,
+		&i.Role,
+		&i.LeftAt,
+	)
 	return i, err
 }
 
 const deleteList = `-- name: DeleteList :one
-DELETE FROM lists WHERE id = $1 RETURNING id, currency, title, created_at
+DELETE FROM lists
+WHERE id = $1
+  AND ($2::int IS NULL OR version = $2)
+RETURNING id, currency, title, created_at, version, closed_at, closed_by
 `
 
-func (q *Queries) DeleteList(ctx context.Context, id pgtype.UUID) (List, error) {
-	row := q.db.QueryRow(ctx, deleteList, id)
+type DeleteListParams struct {
+	ID              pgtype.UUID
+	ExpectedVersion pgtype.Int4
+}
+
+func (q *Queries) DeleteList(ctx context.Context, arg DeleteListParams) (List, error) {
+	row := q.db.QueryRow(ctx, deleteList, arg.ID, arg.ExpectedVersion)
 	var i List
 	err := row.Scan(
 		&i.ID,
 		&i.Currency,
 		&i.Title,
 		&i.CreatedAt,
+		&i.Version,
+		&i.ClosedAt,
+		&i.ClosedBy,
 	)
 	return i, err
 }
 
 const getAllLists = `-- name: GetAllLists :many
-SELECT id, currency, title, created_at FROM lists
+SELECT id, currency, title, created_at, version, closed_at, closed_by FROM lists
+WHERE $1::bool OR closed_at IS NULL
 `
 
-func (q *Queries) GetAllLists(ctx context.Context) ([]List, error) {
-	rows, err := q.db.Query(ctx, getAllLists)
+func (q *Queries) GetAllLists(ctx context.Context, includeArchived bool) ([]List, error) {
+	rows, err := q.db.Query(ctx, getAllLists, includeArchived)
 	if err != nil {
 		return nil, err
 	}
@@ -76,6 +119,9 @@ func (q *Queries) GetAllLists(ctx context.Context) ([]List, error) {
 			&i.Currency,
 			&i.Title,
 			&i.CreatedAt,
+			&i.Version,
+			&i.ClosedAt,
+			&i.ClosedBy,
 		); err != nil {
 			return nil, err
 		}
@@ -88,7 +134,7 @@ func (q *Queries) GetAllLists(ctx context.Context) ([]List, error) {
 }
 
 const getListByID = `-- name: GetListByID :one
-SELECT id, currency, title, created_at FROM lists WHERE id = $1
+SELECT id, currency, title, created_at, version, closed_at, closed_by FROM lists WHERE id = $1
 `
 
 func (q *Queries) GetListByID(ctx context.Context, id pgtype.UUID) (List, error) {
@@ -99,10 +145,46 @@ func (q *Queries) GetListByID(ctx context.Context, id pgtype.UUID) (List, error)
 		&i.Currency,
 		&i.Title,
 		&i.CreatedAt,
+		&i.Version,
+		&i.ClosedAt,
+		&i.ClosedBy,
 	)
 	return i, err
 }
 
+const getListMember = `-- name: GetListMember :one
+SELECT user_id, list_id, role, left_at FROM users_lists WHERE list_id = $1 AND user_id = $2 AND left_at IS NULL
+`
+
+type GetListMemberParams struct {
+	ListID pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) GetListMember(ctx context.Context, arg GetListMemberParams) (UsersList, error) {
+	row := q.db.QueryRow(ctx, getListMember, arg.ListID, arg.UserID)
+	var i UsersList
+	err := row.Scan(
+		&i.UserID,
+		&i.ListID,
+		&i.Role,
+		&i.LeftAt,
+	)
+	return i, err
+}
+
+const getMyListRole = `-- name: GetMyListRole :one
+SELECT role FROM users_lists
+WHERE list_id = $1 AND user_id = app.current_user_id() AND left_at IS NULL
+`
+
+func (q *Queries) GetMyListRole(ctx context.Context, listID pgtype.UUID) (ListRole, error) {
+	row := q.db.QueryRow(ctx, getMyListRole, listID)
+	var role ListRole
+	err := row.Scan(&role)
+	return role, err
+}
+
 const getUsersInList = `-- name: GetUsersInList :many
 SELECT id, username, email FROM users
 JOIN users_lists ON user_id = id
@@ -135,21 +217,127 @@ func (q *Queries) GetUsersInList(ctx context.Context, listID pgtype.UUID) ([]Get
 	return items, nil
 }
 
-const updateList = `-- name: UpdateList :exec
+const leaveList = `-- name: LeaveList :exec
+SELECT app.leave_list($1)
+`
+
+func (q *Queries) LeaveList(ctx context.Context, listID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, leaveList, listID)
+	return err
+}
+
+const reopenList = `-- name: ReopenList :one
+UPDATE lists
+SET
+  closed_at = NULL,
+  closed_by = NULL,
+  version   = version + 1
+WHERE id = $1 AND closed_at IS NOT NULL
+RETURNING id, currency, title, created_at, version, closed_at, closed_by
+`
+
+func (q *Queries) ReopenList(ctx context.Context, id pgtype.UUID) (List, error) {
+	row := q.db.QueryRow(ctx, reopenList, id)
+	var i List
+	err := row.Scan(
+		&i.ID,
+		&i.Currency,
+		&i.Title,
+		&i.CreatedAt,
+		&i.Version,
+		&i.ClosedAt,
+		&i.ClosedBy,
+	)
+	return i, err
+}
+
+const removeListMember = `-- name: RemoveListMember :exec
+SELECT app.remove_list_member($1, $2)
+`
+
+type RemoveListMemberParams struct {
+	ListID pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) RemoveListMember(ctx context.Context, arg RemoveListMemberParams) error {
+	_, err := q.db.Exec(ctx, removeListMember, arg.ListID, arg.UserID)
+	return err
+}
+
+const transferListOwnership = `-- name: TransferListOwnership :exec
+SELECT app.transfer_list_ownership($1, $2)
+`
+
+type TransferListOwnershipParams struct {
+	ListID   pgtype.UUID
+	NewOwner pgtype.UUID
+}
+
+func (q *Queries) TransferListOwnership(ctx context.Context, arg TransferListOwnershipParams) error {
+	_, err := q.db.Exec(ctx, transferListOwnership, arg.ListID, arg.NewOwner)
+	return err
+}
+
+const updateList = `-- name: UpdateList :one
 UPDATE lists
 SET
   title    = COALESCE($2, title),
-  currency = COALESCE($3, currency)
+  currency = COALESCE($3, currency),
+  version  = version + 1
 WHERE id = $1
+  AND ($4::int IS NULL OR version = $4)
+RETURNING id, currency, title, created_at, version, closed_at, closed_by
 `
 
 type UpdateListParams struct {
-	ID       pgtype.UUID
-	Title    pgtype.Text
-	Currency NullCurrency
+	ID              pgtype.UUID
+	Title           pgtype.Text
+	Currency        NullCurrency
+	ExpectedVersion pgtype.Int4
 }
 
-func (q *Queries) UpdateList(ctx context.Context, arg UpdateListParams) error {
-	_, err := q.db.Exec(ctx, updateList, arg.ID, arg.Title, arg.Currency)
-	return err
+func (q *Queries) UpdateList(ctx context.Context, arg UpdateListParams) (List, error) {
+	row := q.db.QueryRow(ctx, updateList,
+		arg.ID,
+		arg.Title,
+		arg.Currency,
+		arg.ExpectedVersion,
+	)
+	var i List
+	err := row.Scan(
+		&i.ID,
+		&i.Currency,
+		&i.Title,
+		&i.CreatedAt,
+		&i.Version,
+		&i.ClosedAt,
+		&i.ClosedBy,
+	)
+	return i, err
+}
+
+const updateMemberRole = `-- name: UpdateMemberRole :one
+UPDATE users_lists
+SET role = $3
+WHERE list_id = $1 AND user_id = $2
+RETURNING user_id, list_id, role, left_at
+`
+
+type UpdateMemberRoleParams struct {
+	ListID pgtype.UUID
+	UserID pgtype.UUID
+	Role   ListRole
+}
+
+func (q *Queries) UpdateMemberRole(ctx context.Context, arg UpdateMemberRoleParams) (UsersList, error) {
+	row := q.db.QueryRow(ctx, updateMemberRole, arg.ListID, arg.UserID, arg.Role)
+	var i UsersList
+	err := row.Scan(
+		&i.UserID,
+		&i.ListID,
+		&i.Role,
+		&i.LeftAt,
+	)
+	return i, err
 }
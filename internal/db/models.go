@@ -56,6 +56,87 @@ func (ns NullCurrency) Value() (driver.Value, error) {
 	return string(ns.Currency), nil
 }
 
+type ListRole string
+
+const (
+	ListRoleViewer ListRole = "viewer"
+	ListRoleMember ListRole = "member"
+	ListRoleAdmin  ListRole = "admin"
+	ListRoleOwner  ListRole = "owner"
+)
+
+func (e *ListRole) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = ListRole(s)
+	case string:
+		*e = ListRole(s)
+	default:
+		return fmt.Errorf("unsupported scan type for ListRole: %T", src)
+	}
+	return nil
+}
+
+type NullListRole struct {
+	ListRole ListRole
+	Valid    bool // Valid is true if ListRole is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullListRole) Scan(value interface{}) error {
+	if value == nil {
+		ns.ListRole, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.ListRole.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullListRole) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.ListRole), nil
+}
+
+type AppDeviceAlert struct {
+	ID        pgtype.UUID
+	UserID    pgtype.UUID
+	SessionID pgtype.UUID
+	TokenHash []byte
+	CreatedAt pgtype.Timestamptz
+	ExpiresAt pgtype.Timestamptz
+	UsedAt    pgtype.Timestamptz
+}
+
+type AppEmailChangeRequest struct {
+	ID        pgtype.UUID
+	UserID    pgtype.UUID
+	NewEmail  string
+	TokenHash []byte
+	CreatedAt pgtype.Timestamptz
+	ExpiresAt pgtype.Timestamptz
+	UsedAt    pgtype.Timestamptz
+}
+
+type AppEmailVerificationToken struct {
+	ID        pgtype.UUID
+	UserID    pgtype.UUID
+	Email     string
+	TokenHash []byte
+	CreatedAt pgtype.Timestamptz
+	ExpiresAt pgtype.Timestamptz
+	UsedAt    pgtype.Timestamptz
+}
+
+type AppKnownDevice struct {
+	UserID      pgtype.UUID
+	Fingerprint []byte
+	FirstSeenAt pgtype.Timestamptz
+	LastSeenAt  pgtype.Timestamptz
+}
+
 type AppLoginSecret struct {
 	ID           pgtype.UUID
 	PasswordHash pgtype.Text
@@ -63,6 +144,59 @@ type AppLoginSecret struct {
 	Email        string
 }
 
+type AppMagicLinkToken struct {
+	ID        pgtype.UUID
+	UserID    pgtype.UUID
+	Email     string
+	TokenHash []byte
+	NonceHash []byte
+	CreatedAt pgtype.Timestamptz
+	ExpiresAt pgtype.Timestamptz
+	UsedAt    pgtype.Timestamptz
+}
+
+type AppMfaRecoveryCode struct {
+	ID        pgtype.UUID
+	UserID    pgtype.UUID
+	CodeHash  []byte
+	CreatedAt pgtype.Timestamptz
+	UsedAt    pgtype.Timestamptz
+}
+
+type AppPasswordResetToken struct {
+	ID        pgtype.UUID
+	UserID    pgtype.UUID
+	TokenHash []byte
+	CreatedAt pgtype.Timestamptz
+	ExpiresAt pgtype.Timestamptz
+	UsedAt    pgtype.Timestamptz
+}
+
+type AppPersonalAccessToken struct {
+	ID         pgtype.UUID
+	UserID     pgtype.UUID
+	Name       string
+	TokenHash  []byte
+	Scopes     []string
+	ListIds    []pgtype.UUID
+	CreatedAt  pgtype.Timestamptz
+	ExpiresAt  pgtype.Timestamptz
+	LastUsedAt pgtype.Timestamptz
+	RevokedAt  pgtype.Timestamptz
+}
+
+type AppRateLimitBucket struct {
+	Key       string
+	Tokens    float64
+	UpdatedAt pgtype.Timestamptz
+}
+
+type AppRateLimitFailure struct {
+	Key           string
+	Failures      int32
+	LastFailureAt pgtype.Timestamptz
+}
+
 type AppRefreshToken struct {
 	ID           pgtype.UUID
 	SessionID    pgtype.UUID
@@ -72,16 +206,49 @@ type AppRefreshToken struct {
 	CreatedAt    pgtype.Timestamptz
 	ExpiresAt    pgtype.Timestamptz
 	RevokedAt    pgtype.Timestamptz
+	ReplacedAt   pgtype.Timestamptz
 }
 
-type AppSession struct {
+type AppSecurityEvent struct {
 	ID        pgtype.UUID
 	UserID    pgtype.UUID
-	CreatedAt pgtype.Timestamptz
-	ExpiresAt pgtype.Timestamptz
-	RevokedAt pgtype.Timestamptz
-	UserAgent pgtype.Text
+	Kind      string
+	SessionID pgtype.UUID
 	Ip        pgtype.Text
+	UserAgent pgtype.Text
+	Browser   pgtype.Text
+	Os        pgtype.Text
+	NewDevice bool
+	CreatedAt pgtype.Timestamptz
+}
+
+type AppSession struct {
+	ID         pgtype.UUID
+	UserID     pgtype.UUID
+	CreatedAt  pgtype.Timestamptz
+	ExpiresAt  pgtype.Timestamptz
+	RevokedAt  pgtype.Timestamptz
+	UserAgent  pgtype.Text
+	Ip         pgtype.Text
+	LastUsedAt pgtype.Timestamptz
+}
+
+type AppUserIdentity struct {
+	ID          pgtype.UUID
+	UserID      pgtype.UUID
+	Provider    string
+	Subject     string
+	Email       pgtype.Text
+	CreatedAt   pgtype.Timestamptz
+	LastLoginAt pgtype.Timestamptz
+}
+
+type AppUserMfa struct {
+	UserID       pgtype.UUID
+	TotpSecret   string
+	CreatedAt    pgtype.Timestamptz
+	ConfirmedAt  pgtype.Timestamptz
+	LastUsedStep pgtype.Int8
 }
 
 type AppUsersSafe struct {
@@ -91,6 +258,9 @@ type AppUsersSafe struct {
 	CreatedAt         pgtype.Timestamptz
 	PasswordChangedAt pgtype.Timestamptz
 	LastLoginAt       pgtype.Timestamptz
+	DisplayName       pgtype.Text
+	EmailVerifiedAt   pgtype.Timestamptz
+	Language          pgtype.Text
 }
 
 type AppVMembership struct {
@@ -98,6 +268,30 @@ type AppVMembership struct {
 	ListID pgtype.UUID
 }
 
+type AppWebauthnChallenge struct {
+	ID        pgtype.UUID
+	UserID    pgtype.UUID
+	Ceremony  string
+	Session   []byte
+	CreatedAt pgtype.Timestamptz
+	ExpiresAt pgtype.Timestamptz
+}
+
+type AppWebauthnCredential struct {
+	ID              pgtype.UUID
+	UserID          pgtype.UUID
+	Name            string
+	CredentialID    []byte
+	PublicKey       []byte
+	AttestationType string
+	Transports      []string
+	Aaguid          []byte
+	SignCount       int64
+	Flags           int16
+	CreatedAt       pgtype.Timestamptz
+	LastUsedAt      pgtype.Timestamptz
+}
+
 type Category struct {
 	ID        pgtype.UUID
 	Name      string
@@ -106,20 +300,26 @@ type Category struct {
 }
 
 type Deposit struct {
-	ID          pgtype.UUID
-	Amount      pgtype.Numeric
-	CreatedAt   pgtype.Timestamptz
-	PayerUserID pgtype.UUID
-	PayeeUserID pgtype.UUID
-	ListID      pgtype.UUID
+	ID                 pgtype.UUID
+	Amount             pgtype.Numeric
+	CreatedAt          pgtype.Timestamptz
+	PayerUserID        pgtype.UUID
+	PayeeUserID        pgtype.UUID
+	ListID             pgtype.UUID
+	Version            int32
+	PayerPlaceholderID pgtype.UUID
+	PayeePlaceholderID pgtype.UUID
+	CreatedBy          pgtype.UUID
+	IsSettlement       bool
 }
 
 type Division struct {
-	ID        pgtype.UUID
-	Amount    pgtype.Numeric
-	CreatedAt pgtype.Timestamptz
-	OweUserID pgtype.UUID
-	PaymentID pgtype.UUID
+	ID               pgtype.UUID
+	Amount           pgtype.Numeric
+	CreatedAt        pgtype.Timestamptz
+	OweUserID        pgtype.UUID
+	PaymentID        pgtype.UUID
+	OwePlaceholderID pgtype.UUID
 }
 
 type Invitation struct {
@@ -139,16 +339,41 @@ type List struct {
 	Currency  Currency
 	Title     string
 	CreatedAt pgtype.Timestamptz
+	Version   int32
+	ClosedAt  pgtype.Timestamptz
+	ClosedBy  pgtype.UUID
 }
 
-type Payment struct {
+type ListPlaceholder struct {
 	ID          pgtype.UUID
-	Amount      pgtype.Numeric
-	CreatedAt   pgtype.Timestamptz
-	PhotoUrl    pgtype.Text
-	PayerUserID pgtype.UUID
 	ListID      pgtype.UUID
-	Title       pgtype.Text
+	DisplayName string
+	CreatedAt   pgtype.Timestamptz
+	CreatedBy   pgtype.UUID
+	ClaimedBy   pgtype.UUID
+	ClaimedAt   pgtype.Timestamptz
+}
+
+type ListSettlement struct {
+	ID           pgtype.UUID
+	ListID       pgtype.UUID
+	Balances     []byte
+	Transactions []byte
+	CreatedAt    pgtype.Timestamptz
+	CreatedBy    pgtype.UUID
+}
+
+type Payment struct {
+	ID                 pgtype.UUID
+	Amount             pgtype.Numeric
+	CreatedAt          pgtype.Timestamptz
+	PhotoUrl           pgtype.Text
+	PayerUserID        pgtype.UUID
+	ListID             pgtype.UUID
+	Title              pgtype.Text
+	Version            int32
+	PayerPlaceholderID pgtype.UUID
+	CreatedBy          pgtype.UUID
 }
 
 type PaymentsCategory struct {
@@ -165,9 +390,14 @@ type User struct {
 	PasswordAlgo      string
 	PasswordChangedAt pgtype.Timestamptz
 	LastLoginAt       pgtype.Timestamptz
+	DisplayName       pgtype.Text
+	EmailVerifiedAt   pgtype.Timestamptz
+	Language          pgtype.Text
 }
 
 type UsersList struct {
 	UserID pgtype.UUID
 	ListID pgtype.UUID
+	Role   ListRole
+	LeftAt pgtype.Timestamptz
 }
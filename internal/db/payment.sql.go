@@ -12,16 +12,17 @@ import (
 )
 
 const createPayment = `-- name: CreatePayment :one
-INSERT INTO public.payments (payer_user_id, amount, photo_url, list_id, title)
-VALUES ($1, $2, $3, $4, $5) RETURNING id, amount, created_at, photo_url, payer_user_id, list_id, title
+INSERT INTO public.payments (payer_user_id, amount, photo_url, list_id, title, payer_placeholder_id)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, amount, created_at, photo_url, payer_user_id, list_id, title, version, payer_placeholder_id, created_by
 `
 
 type CreatePaymentParams struct {
-	PayerUserID pgtype.UUID
-	Amount      pgtype.Numeric
-	PhotoUrl    pgtype.Text
-	ListID      pgtype.UUID
-	Title       pgtype.Text
+	PayerUserID        pgtype.UUID
+	Amount             pgtype.Numeric
+	PhotoUrl           pgtype.Text
+	ListID             pgtype.UUID
+	Title              pgtype.Text
+	PayerPlaceholderID pgtype.UUID
 }
 
 func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (Payment, error) {
@@ -31,6 +32,7 @@ func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (P
 		arg.PhotoUrl,
 		arg.ListID,
 		arg.Title,
+		arg.PayerPlaceholderID,
 	)
 	var i Payment
 	err := row.Scan(
@@ -41,22 +43,34 @@ func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (P
 		&i.PayerUserID,
 		&i.ListID,
 		&i.Title,
+		&i.Version,
+		&i.PayerPlaceholderID,
+		&i.CreatedBy,
 	)
 	return i, err
 }
 
-const deletePaymentByID = `-- name: DeletePaymentByID :exec
+const deletePaymentByID = `-- name: DeletePaymentByID :execrows
 DELETE FROM public.payments
 WHERE id = $1
+  AND ($2::int IS NULL OR version = $2)
 `
 
-func (q *Queries) DeletePaymentByID(ctx context.Context, id pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, deletePaymentByID, id)
-	return err
+type DeletePaymentByIDParams struct {
+	ID              pgtype.UUID
+	ExpectedVersion pgtype.Int4
+}
+
+func (q *Queries) DeletePaymentByID(ctx context.Context, arg DeletePaymentByIDParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deletePaymentByID, arg.ID, arg.ExpectedVersion)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
 }
 
 const getAllPaymentsForList = `-- name: GetAllPaymentsForList :many
-SELECT id, amount, created_at, photo_url, payer_user_id, list_id, title FROM public.payments
+SELECT id, amount, created_at, photo_url, payer_user_id, list_id, title, version, payer_placeholder_id, created_by FROM public.payments
 WHERE list_id = $1
 `
 
@@ -77,6 +91,9 @@ func (q *Queries) GetAllPaymentsForList(ctx context.Context, listID pgtype.UUID)
 			&i.PayerUserID,
 			&i.ListID,
 			&i.Title,
+			&i.Version,
+			&i.PayerPlaceholderID,
+			&i.CreatedBy,
 		); err != nil {
 			return nil, err
 		}
@@ -89,7 +106,7 @@ func (q *Queries) GetAllPaymentsForList(ctx context.Context, listID pgtype.UUID)
 }
 
 const getPaymentByID = `-- name: GetPaymentByID :one
-SELECT id, amount, created_at, photo_url, payer_user_id, list_id, title FROM public.payments
+SELECT id, amount, created_at, photo_url, payer_user_id, list_id, title, version, payer_placeholder_id, created_by FROM public.payments
 WHERE id = $1
 `
 
@@ -104,6 +121,9 @@ func (q *Queries) GetPaymentByID(ctx context.Context, id pgtype.UUID) (Payment,
 		&i.PayerUserID,
 		&i.ListID,
 		&i.Title,
+		&i.Version,
+		&i.PayerPlaceholderID,
+		&i.CreatedBy,
 	)
 	return i, err
 }
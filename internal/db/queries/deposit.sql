@@ -1,5 +1,14 @@
 -- name: CreateDeposit :one
-INSERT INTO deposits (amount, payer_user_id, payee_user_id, list_id) VALUES ($1, $2, $3, $4) RETURNING *;
+INSERT INTO deposits (amount, payer_user_id, payee_user_id, list_id, payer_placeholder_id, payee_placeholder_id, is_settlement)
+VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *;
 
 -- name: GetAllDepositsForListID :many
 SELECT * FROM deposits WHERE list_id = $1;
+
+-- name: GetDepositByID :one
+SELECT * FROM deposits WHERE id = $1;
+
+-- name: DeleteDepositByID :execrows
+DELETE FROM deposits
+WHERE id = $1
+  AND (sqlc.narg(expected_version)::int IS NULL OR version = sqlc.narg(expected_version));
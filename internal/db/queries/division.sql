@@ -1,7 +1,7 @@
 
 -- name: CreateDivision :one
-INSERT INTO public.divisions (owe_user_id, amount, payment_id)
-VALUES ($1, $2, $3) RETURNING *;
+INSERT INTO public.divisions (owe_user_id, amount, payment_id, owe_placeholder_id)
+VALUES ($1, $2, $3, $4) RETURNING *;
 
 -- name: GetDivisionsByPaymentID :many
 SELECT * FROM public.divisions WHERE payment_id = $1;
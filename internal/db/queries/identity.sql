@@ -0,0 +1,17 @@
+-- name: GetUserIdentity :one
+SELECT * FROM app.user_identities
+WHERE provider = $1 AND subject = $2;
+
+-- name: CreateUserIdentity :one
+INSERT INTO app.user_identities (user_id, provider, subject, email, last_login_at)
+VALUES ($1, $2, $3, $4, now())
+RETURNING *;
+
+-- name: TouchUserIdentity :exec
+UPDATE app.user_identities
+SET last_login_at = now(),
+    email = $2
+WHERE id = $1;
+
+-- name: RegisterOIDCUser :one
+SELECT app.register_oidc_user($1, $2, $3, $4, $5);
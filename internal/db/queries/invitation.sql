@@ -19,3 +19,6 @@ WHERE id = $1;
 -- name: GetInvitationByID :one
 SELECT * FROM public.invitations
 WHERE id = $1;
+
+-- name: AcceptInvitation :one
+SELECT app.accept_invitation(sqlc.arg(hash), sqlc.narg(placeholder_id)::uuid);
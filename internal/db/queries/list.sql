@@ -2,25 +2,72 @@
 INSERT INTO lists (id, title, currency) VALUES ($1, $2, $3);
 
 -- name: CreateUserListRelation :one
-INSERT INTO users_lists (user_id, list_id) VALUES ($1, $2) RETURNING *;
+INSERT INTO users_lists (user_id, list_id, role) VALUES ($1, $2, $3) RETURNING *;
 
 -- name: GetListByID :one
 SELECT * FROM lists WHERE id = $1;
 
--- name: UpdateList :exec
+-- name: UpdateList :one
 UPDATE lists
 SET
   title    = COALESCE(sqlc.narg(title), title),
-  currency = COALESCE(sqlc.narg(currency), currency)
-WHERE id = $1;
+  currency = COALESCE(sqlc.narg(currency), currency),
+  version  = version + 1
+WHERE id = $1
+  AND (sqlc.narg(expected_version)::int IS NULL OR version = sqlc.narg(expected_version))
+RETURNING *;
 
 -- name: DeleteList :one
-DELETE FROM lists WHERE id = $1 RETURNING *;
+DELETE FROM lists
+WHERE id = $1
+  AND (sqlc.narg(expected_version)::int IS NULL OR version = sqlc.narg(expected_version))
+RETURNING *;
 
 -- name: GetAllLists :many
-SELECT * FROM lists;
+SELECT * FROM lists
+WHERE sqlc.arg(include_archived)::bool OR closed_at IS NULL;
 
 -- name: GetUsersInList :many
 SELECT id, username, email FROM users
 JOIN users_lists ON user_id = id
 WHERE list_id = $1 AND id <> app.current_user_id();
+
+-- name: GetMyListRole :one
+SELECT role FROM users_lists
+WHERE list_id = $1 AND user_id = app.current_user_id() AND left_at IS NULL;
+
+-- name: GetListMember :one
+SELECT * FROM users_lists WHERE list_id = $1 AND user_id = $2 AND left_at IS NULL;
+
+-- name: UpdateMemberRole :one
+UPDATE users_lists
+SET role = $3
+WHERE list_id = $1 AND user_id = $2
+RETURNING *;
+
+-- name: TransferListOwnership :exec
+SELECT app.transfer_list_ownership($1, $2);
+
+-- name: LeaveList :exec
+SELECT app.leave_list($1);
+
+-- name: RemoveListMember :exec
+SELECT app.remove_list_member($1, $2);
+
+-- name: CloseList :one
+UPDATE lists
+SET
+  closed_at = now(),
+  closed_by = app.current_user_id(),
+  version   = version + 1
+WHERE id = $1 AND closed_at IS NULL
+RETURNING *;
+
+-- name: ReopenList :one
+UPDATE lists
+SET
+  closed_at = NULL,
+  closed_by = NULL,
+  version   = version + 1
+WHERE id = $1 AND closed_at IS NOT NULL
+RETURNING *;
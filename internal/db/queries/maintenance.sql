@@ -0,0 +1,20 @@
+-- name: PurgeSessions :one
+SELECT app.purge_sessions($1, $2);
+
+-- name: PurgeRefreshTokens :one
+SELECT app.purge_refresh_tokens($1, $2);
+
+-- name: PurgeInvitations :one
+SELECT app.purge_invitations($1, $2);
+
+-- name: PurgeAccountTokens :one
+SELECT app.purge_account_tokens($1, $2);
+
+-- name: PurgePersonalAccessTokens :one
+SELECT app.purge_personal_access_tokens($1, $2);
+
+-- name: PurgeRateLimits :one
+SELECT app.purge_rate_limits($1, $2);
+
+-- name: PurgeSecurityEvents :one
+SELECT app.purge_security_events($1, $2);
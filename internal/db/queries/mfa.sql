@@ -0,0 +1,39 @@
+-- name: GetUserMFA :one
+SELECT * FROM app.user_mfa WHERE user_id = $1;
+
+-- name: StartMFAEnrollment :one
+-- Starting over is allowed until the enrollment is confirmed.
+INSERT INTO app.user_mfa (user_id, totp_secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET totp_secret = EXCLUDED.totp_secret,
+    created_at = now(),
+    last_used_step = NULL
+WHERE app.user_mfa.confirmed_at IS NULL
+RETURNING *;
+
+-- name: ConfirmMFAEnrollment :one
+UPDATE app.user_mfa
+SET confirmed_at = now(),
+    last_used_step = $2
+WHERE user_id = $1 AND confirmed_at IS NULL
+RETURNING *;
+
+-- name: UseMFAStep :execrows
+UPDATE app.user_mfa
+SET last_used_step = $2
+WHERE user_id = $1 AND (last_used_step IS NULL OR last_used_step < $2);
+
+-- name: DeleteUserMFA :exec
+DELETE FROM app.user_mfa WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO app.mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2);
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM app.mfa_recovery_codes WHERE user_id = $1;
+
+-- name: UseRecoveryCode :execrows
+UPDATE app.mfa_recovery_codes
+SET used_at = now()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
@@ -1,6 +1,6 @@
 -- name: CreatePayment :one
-INSERT INTO public.payments (payer_user_id, amount, photo_url, list_id, title)
-VALUES ($1, $2, $3, $4, $5) RETURNING *;
+INSERT INTO public.payments (payer_user_id, amount, photo_url, list_id, title, payer_placeholder_id)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
 
 -- name: GetAllPaymentsForList :many
 SELECT * FROM public.payments
@@ -10,6 +10,7 @@ WHERE list_id = $1;
 SELECT * FROM public.payments
 WHERE id = $1;
 
--- name: DeletePaymentByID :exec
+-- name: DeletePaymentByID :execrows
 DELETE FROM public.payments
-WHERE id = $1;
+WHERE id = $1
+  AND (sqlc.narg(expected_version)::int IS NULL OR version = sqlc.narg(expected_version));
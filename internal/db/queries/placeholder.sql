@@ -0,0 +1,16 @@
+-- name: CreatePlaceholder :one
+INSERT INTO public.list_placeholders (list_id, display_name, created_by)
+VALUES ($1, $2, app.current_user_id()) RETURNING *;
+
+-- name: GetPlaceholdersForList :many
+SELECT * FROM public.list_placeholders
+WHERE list_id = $1
+ORDER BY created_at;
+
+-- name: GetPlaceholderByID :one
+SELECT * FROM public.list_placeholders
+WHERE id = $1;
+
+-- name: DeletePlaceholder :execrows
+DELETE FROM public.list_placeholders
+WHERE id = $1 AND list_id = $2;
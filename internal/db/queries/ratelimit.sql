@@ -0,0 +1,19 @@
+-- name: TakeRateLimitToken :one
+SELECT app.take_rate_limit_token($1, $2, $3);
+
+-- name: AddRateLimitFailure :one
+INSERT INTO app.rate_limit_failures AS f (key, failures, last_failure_at)
+VALUES (sqlc.arg(key), 1, now())
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+      WHEN f.last_failure_at < now() - make_interval(secs => sqlc.arg(window_seconds)::float8) THEN 1
+      ELSE f.failures + 1
+    END,
+    last_failure_at = now()
+RETURNING failures;
+
+-- name: GetRateLimitFailures :one
+SELECT failures, last_failure_at FROM app.rate_limit_failures WHERE key = $1;
+
+-- name: ClearRateLimitFailures :exec
+DELETE FROM app.rate_limit_failures WHERE key = $1;
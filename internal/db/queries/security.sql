@@ -0,0 +1,28 @@
+-- name: CreateSecurityEvent :exec
+INSERT INTO app.security_events (user_id, kind, session_id, ip, user_agent, browser, os, new_device)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+
+-- name: GetSecurityEventsForUser :many
+SELECT * FROM app.security_events
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: CountKnownDevices :one
+SELECT count(*) FROM app.known_devices WHERE user_id = $1;
+
+-- name: TouchKnownDevice :one
+INSERT INTO app.known_devices (user_id, fingerprint)
+VALUES ($1, $2)
+ON CONFLICT (user_id, fingerprint) DO UPDATE SET last_seen_at = now()
+RETURNING (xmax = 0) AS inserted;
+
+-- name: CreateDeviceAlert :exec
+INSERT INTO app.device_alerts (user_id, session_id, token_hash, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: UseDeviceAlert :one
+UPDATE app.device_alerts
+SET used_at = now()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+RETURNING session_id;
@@ -17,17 +17,22 @@ SELECT
   rt.expires_at   AS rt_expires_at,
   rt.revoked_at   AS rt_revoked_at,
   rt.replaced_by_id AS rt_replaced_by_id,
+  rt.replaced_at  AS rt_replaced_at,
   s.user_id       AS user_id,
   s.revoked_at    AS session_revoked_at,
   s.expires_at AS max_expires_at
 FROM app.refresh_tokens rt
 JOIN app.sessions s ON s.id = rt.session_id
 WHERE rt.token_hash = $1
-LIMIT 1;
+LIMIT 1
+FOR UPDATE OF rt;
+
+-- name: GetRefreshTokenByID :one
+SELECT * FROM app.refresh_tokens WHERE id = $1;
 
 -- name: MarkOldTokenReplaced :execrows
 UPDATE app.refresh_tokens
-SET replaced_by_id = $2
+SET replaced_by_id = $2, replaced_at = now()
 WHERE id = $1 AND replaced_by_id IS NULL;
 
 -- name: RevokeWholeSession :exec
@@ -40,3 +45,29 @@ WHERE id = $1 AND revoked_at IS NULL;
 UPDATE app.refresh_tokens
 SET revoked_at = now()
 WHERE session_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :exec
+UPDATE app.sessions
+SET revoked_at = now()
+WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL;
+
+-- name: TouchSession :exec
+UPDATE app.sessions
+SET last_used_at = now()
+WHERE id = $1
+  AND (last_used_at IS NULL OR last_used_at < now() - interval '5 minutes');
+
+-- name: GetActiveSessionsForUser :many
+SELECT * FROM app.sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+ORDER BY COALESCE(last_used_at, created_at) DESC;
+
+-- name: RevokeUserSession :execrows
+UPDATE app.sessions
+SET revoked_at = now()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeAllUserSessions :exec
+UPDATE app.sessions
+SET revoked_at = now()
+WHERE user_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,12 @@
+-- name: CreateListSettlement :one
+INSERT INTO list_settlements (list_id, balances, transactions)
+VALUES ($1, $2, $3) RETURNING *;
+
+-- name: GetLatestListSettlement :one
+SELECT * FROM list_settlements
+WHERE list_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: SettlementOutstanding :one
+SELECT app.settlement_outstanding(sqlc.arg(list_id), sqlc.arg(payer_id), sqlc.arg(payee_id), sqlc.arg(amount)::numeric);
@@ -14,6 +14,49 @@ SELECT * FROM app.login_secret WHERE email = $1;
 SELECT app.update_last_login($1);
 
 -- name: GetUsersFromList :many
-SELECT u.* FROM app.users_safe u
+SELECT u.*, ul.role, ul.left_at FROM app.users_safe u
 JOIN users_lists ul ON u.id = ul.user_id
 WHERE ul.list_id = $1;
+
+-- name: UpdateProfile :exec
+SELECT app.update_profile(sqlc.narg(username), sqlc.narg(display_name), sqlc.narg(language));
+
+-- name: UpdateUserPassword :exec
+SELECT app.update_user_password($1, $2, $3);
+
+-- name: RehashUserPassword :one
+SELECT app.rehash_user_password($1, $2, $3, $4);
+
+-- name: CreateEmailChangeRequest :one
+INSERT INTO app.email_change_requests (user_id, new_email, token_hash, expires_at)
+VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: ConfirmEmailChange :one
+SELECT app.confirm_email_change($1);
+
+-- name: CreatePasswordResetToken :exec
+INSERT INTO app.password_reset_tokens (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3);
+
+-- name: ResetPassword :one
+SELECT app.reset_password($1, $2, $3);
+
+-- name: CreateEmailVerificationToken :exec
+INSERT INTO app.email_verification_tokens (user_id, email, token_hash, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: GetLatestEmailVerificationToken :one
+SELECT * FROM app.email_verification_tokens
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: VerifyEmail :one
+SELECT app.verify_email($1);
+
+-- name: CreateMagicLinkToken :exec
+INSERT INTO app.magic_link_tokens (user_id, email, token_hash, nonce_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: ConsumeMagicLink :one
+SELECT app.consume_magic_link($1, $2);
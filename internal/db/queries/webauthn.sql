@@ -0,0 +1,29 @@
+-- name: CreateWebAuthnCredential :one
+INSERT INTO app.webauthn_credentials (
+  user_id, name, credential_id, public_key, attestation_type, transports, aaguid, sign_count, flags
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+RETURNING *;
+
+-- name: GetWebAuthnCredentialsForUser :many
+SELECT * FROM app.webauthn_credentials
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: UseWebAuthnCredential :exec
+UPDATE app.webauthn_credentials
+SET sign_count = $2,
+    last_used_at = now()
+WHERE id = $1;
+
+-- name: DeleteWebAuthnCredential :execrows
+DELETE FROM app.webauthn_credentials WHERE id = $1 AND user_id = $2;
+
+-- name: CreateWebAuthnChallenge :one
+INSERT INTO app.webauthn_challenges (user_id, ceremony, session, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING id;
+
+-- name: TakeWebAuthnChallenge :one
+DELETE FROM app.webauthn_challenges
+WHERE id = $1 AND ceremony = $2
+RETURNING *;
@@ -18,6 +18,7 @@ SELECT
   rt.expires_at   AS rt_expires_at,
   rt.revoked_at   AS rt_revoked_at,
   rt.replaced_by_id AS rt_replaced_by_id,
+  rt.replaced_at  AS rt_replaced_at,
   s.user_id       AS user_id,
   s.revoked_at    AS session_revoked_at,
   s.expires_at AS max_expires_at
@@ -25,6 +26,7 @@ FROM app.refresh_tokens rt
 JOIN app.sessions s ON s.id = rt.session_id
 WHERE rt.token_hash = $1
 LIMIT 1
+FOR UPDATE OF rt
 `
 
 type AuthRefreshLookupRow struct {
@@ -33,6 +35,7 @@ type AuthRefreshLookupRow struct {
 	RtExpiresAt      pgtype.Timestamptz
 	RtRevokedAt      pgtype.Timestamptz
 	RtReplacedByID   pgtype.UUID
+	RtReplacedAt     pgtype.Timestamptz
 	UserID           pgtype.UUID
 	SessionRevokedAt pgtype.Timestamptz
 	MaxExpiresAt     pgtype.Timestamptz
@@ -47,6 +50,7 @@ func (q *Queries) AuthRefreshLookup(ctx context.Context, tokenHash []byte) (Auth
 		&i.RtExpiresAt,
 		&i.RtRevokedAt,
 		&i.RtReplacedByID,
+		&i.RtReplacedAt,
 		&i.UserID,
 		&i.SessionRevokedAt,
 		&i.MaxExpiresAt,
@@ -94,7 +98,7 @@ func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshToken
 }
 
 const createSession = `-- name: CreateSession :one
-INSERT INTO app.sessions (user_id, expires_at, user_agent, ip) VALUES ($1, $2, $3, $4) RETURNING id, user_id, created_at, expires_at, revoked_at, user_agent, ip
+INSERT INTO app.sessions (user_id, expires_at, user_agent, ip) VALUES ($1, $2, $3, $4) RETURNING id, user_id, created_at, expires_at, revoked_at, user_agent, ip, last_used_at
 `
 
 type CreateSessionParams struct {
@@ -120,12 +124,69 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (A
 		&i.RevokedAt,
 		&i.UserAgent,
 		&i.Ip,
+		&i.LastUsedAt,
+	)
+	return i, err
+}
+
+const getActiveSessionsForUser = `-- name: GetActiveSessionsForUser :many
+SELECT id, user_id, created_at, expires_at, revoked_at, user_agent, ip, last_used_at FROM app.sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+ORDER BY COALESCE(last_used_at, created_at) DESC
+`
+
+func (q *Queries) GetActiveSessionsForUser(ctx context.Context, userID pgtype.UUID) ([]AppSession, error) {
+	rows, err := q.db.Query(ctx, getActiveSessionsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AppSession
+	for rows.Next() {
+		var i AppSession
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.UserAgent,
+			&i.Ip,
+			&i.LastUsedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRefreshTokenByID = `-- name: GetRefreshTokenByID :one
+SELECT id, session_id, token_hash, parent_id, replaced_by_id, created_at, expires_at, revoked_at, replaced_at FROM app.refresh_tokens WHERE id = $1
+`
+
+func (q *Queries) GetRefreshTokenByID(ctx context.Context, id pgtype.UUID) (AppRefreshToken, error) {
+	row := q.db.QueryRow(ctx, getRefreshTokenByID, id)
+	var i AppRefreshToken
+	err := row.Scan(
+		&i.ID,
+		&i.SessionID,
+		&i.TokenHash,
+		&i.ParentID,
+		&i.ReplacedByID,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.ReplacedAt,
 	)
 	return i, err
 }
 
 const getSessionByID = `-- name: GetSessionByID :one
-SELECT id, user_id, created_at, expires_at, revoked_at, user_agent, ip FROM app.sessions WHERE id = $1
+SELECT id, user_id, created_at, expires_at, revoked_at, user_agent, ip, last_used_at FROM app.sessions WHERE id = $1
 `
 
 func (q *Queries) GetSessionByID(ctx context.Context, id pgtype.UUID) (AppSession, error) {
@@ -139,13 +200,14 @@ func (q *Queries) GetSessionByID(ctx context.Context, id pgtype.UUID) (AppSessio
 		&i.RevokedAt,
 		&i.UserAgent,
 		&i.Ip,
+		&i.LastUsedAt,
 	)
 	return i, err
 }
 
 const markOldTokenReplaced = `-- name: MarkOldTokenReplaced :execrows
 UPDATE app.refresh_tokens
-SET replaced_by_id = $2
+SET replaced_by_id = $2, replaced_at = now()
 WHERE id = $1 AND replaced_by_id IS NULL
 `
 
@@ -174,6 +236,52 @@ func (q *Queries) RevokeAllTokensInSession(ctx context.Context, sessionID pgtype
 	return err
 }
 
+const revokeAllUserSessions = `-- name: RevokeAllUserSessions :exec
+UPDATE app.sessions
+SET revoked_at = now()
+WHERE user_id = $1 AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAllUserSessions(ctx context.Context, userID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, revokeAllUserSessions, userID)
+	return err
+}
+
+const revokeOtherSessions = `-- name: RevokeOtherSessions :exec
+UPDATE app.sessions
+SET revoked_at = now()
+WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+`
+
+type RevokeOtherSessionsParams struct {
+	UserID pgtype.UUID
+	ID     pgtype.UUID
+}
+
+func (q *Queries) RevokeOtherSessions(ctx context.Context, arg RevokeOtherSessionsParams) error {
+	_, err := q.db.Exec(ctx, revokeOtherSessions, arg.UserID, arg.ID)
+	return err
+}
+
+const revokeUserSession = `-- name: RevokeUserSession :execrows
+UPDATE app.sessions
+SET revoked_at = now()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeUserSessionParams struct {
+	ID     pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) RevokeUserSession(ctx context.Context, arg RevokeUserSessionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeUserSession, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const revokeWholeSession = `-- name: RevokeWholeSession :exec
 UPDATE app.sessions
 SET revoked_at = now()
@@ -184,3 +292,15 @@ func (q *Queries) RevokeWholeSession(ctx context.Context, id pgtype.UUID) error
 	_, err := q.db.Exec(ctx, revokeWholeSession, id)
 	return err
 }
+
+const touchSession = `-- name: TouchSession :exec
+UPDATE app.sessions
+SET last_used_at = now()
+WHERE id = $1
+  AND (last_used_at IS NULL OR last_used_at < now() - interval '5 minutes')
+`
+
+func (q *Queries) TouchSession(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, touchSession, id)
+	return err
+}
@@ -11,6 +11,127 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const confirmEmailChange = `-- name: ConfirmEmailChange :one
+SELECT app.confirm_email_change($1)
+`
+
+func (q *Queries) ConfirmEmailChange(ctx context.Context, tokenHash []byte) (string, error) {
+	row := q.db.QueryRow(ctx, confirmEmailChange, tokenHash)
+	var confirm_email_change string
+	err := row.Scan(&confirm_email_change)
+	return confirm_email_change, err
+}
+
+const consumeMagicLink = `-- name: ConsumeMagicLink :one
+SELECT app.consume_magic_link($1, $2)
+`
+
+type ConsumeMagicLinkParams struct {
+	TokenHash []byte
+	NonceHash []byte
+}
+
+func (q *Queries) ConsumeMagicLink(ctx context.Context, arg ConsumeMagicLinkParams) (pgtype.UUID, error) {
+	row := q.db.QueryRow(ctx, consumeMagicLink, arg.TokenHash, arg.NonceHash)
+	var consume_magic_link pgtype.UUID
+	err := row.Scan(&consume_magic_link)
+	return consume_magic_link, err
+}
+
+const createEmailChangeRequest = `-- name: CreateEmailChangeRequest :one
+INSERT INTO app.email_change_requests (user_id, new_email, token_hash, expires_at)
+VALUES ($1, $2, $3, $4) RETURNING id, user_id, new_email, token_hash, created_at, expires_at, used_at
+`
+
+type CreateEmailChangeRequestParams struct {
+	UserID    pgtype.UUID
+	NewEmail  string
+	TokenHash []byte
+	ExpiresAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreateEmailChangeRequest(ctx context.Context, arg CreateEmailChangeRequestParams) (AppEmailChangeRequest, error) {
+	row := q.db.QueryRow(ctx, createEmailChangeRequest,
+		arg.UserID,
+		arg.NewEmail,
+		arg.TokenHash,
+		arg.ExpiresAt,
+	)
+	var i AppEmailChangeRequest
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.NewEmail,
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
+const createEmailVerificationToken = `-- name: CreateEmailVerificationToken :exec
+INSERT INTO app.email_verification_tokens (user_id, email, token_hash, expires_at)
+VALUES ($1, $2, $3, $4)
+`
+
+type CreateEmailVerificationTokenParams struct {
+	UserID    pgtype.UUID
+	Email     string
+	TokenHash []byte
+	ExpiresAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreateEmailVerificationToken(ctx context.Context, arg CreateEmailVerificationTokenParams) error {
+	_, err := q.db.Exec(ctx, createEmailVerificationToken,
+		arg.UserID,
+		arg.Email,
+		arg.TokenHash,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const createMagicLinkToken = `-- name: CreateMagicLinkToken :exec
+INSERT INTO app.magic_link_tokens (user_id, email, token_hash, nonce_hash, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type CreateMagicLinkTokenParams struct {
+	UserID    pgtype.UUID
+	Email     string
+	TokenHash []byte
+	NonceHash []byte
+	ExpiresAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreateMagicLinkToken(ctx context.Context, arg CreateMagicLinkTokenParams) error {
+	_, err := q.db.Exec(ctx, createMagicLinkToken,
+		arg.UserID,
+		arg.Email,
+		arg.TokenHash,
+		arg.NonceHash,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const createPasswordResetToken = `-- name: CreatePasswordResetToken :exec
+INSERT INTO app.password_reset_tokens (user_id, token_hash, expires_at)
+VALUES ($1, $2, $3)
+`
+
+type CreatePasswordResetTokenParams struct {
+	UserID    pgtype.UUID
+	TokenHash []byte
+	ExpiresAt pgtype.Timestamptz
+}
+
+func (q *Queries) CreatePasswordResetToken(ctx context.Context, arg CreatePasswordResetTokenParams) error {
+	_, err := q.db.Exec(ctx, createPasswordResetToken, arg.UserID, arg.TokenHash, arg.ExpiresAt)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
 SELECT register_user FROM app.register_user($1, $2, $3, $4)
 `
@@ -34,6 +155,28 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (pgtype.
 	return register_user, err
 }
 
+const getLatestEmailVerificationToken = `-- name: GetLatestEmailVerificationToken :one
+SELECT id, user_id, email, token_hash, created_at, expires_at, used_at FROM app.email_verification_tokens
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestEmailVerificationToken(ctx context.Context, userID pgtype.UUID) (AppEmailVerificationToken, error) {
+	row := q.db.QueryRow(ctx, getLatestEmailVerificationToken, userID)
+	var i AppEmailVerificationToken
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Email,
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+		&i.UsedAt,
+	)
+	return i, err
+}
+
 const getLoginSecretsByEmail = `-- name: GetLoginSecretsByEmail :one
 SELECT id, password_hash, password_algo, email FROM app.login_secret WHERE email = $1
 `
@@ -51,7 +194,7 @@ func (q *Queries) GetLoginSecretsByEmail(ctx context.Context, email string) (App
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, username, email, created_at, password_changed_at, last_login_at FROM app.users_safe WHERE email = $1
+SELECT id, username, email, created_at, password_changed_at, last_login_at, display_name, email_verified_at, language FROM app.users_safe WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (AppUsersSafe, error) {
@@ -64,12 +207,15 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (AppUsersSaf
 		&i.CreatedAt,
 		&i.PasswordChangedAt,
 		&i.LastLoginAt,
+		&i.DisplayName,
+		&i.EmailVerifiedAt,
+		&i.Language,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, username, email, created_at, password_changed_at, last_login_at FROM app.users_safe WHERE id = $1
+SELECT id, username, email, created_at, password_changed_at, last_login_at, display_name, email_verified_at, language FROM app.users_safe WHERE id = $1
 `
 
 func (q *Queries) GetUserByID(ctx context.Context, id pgtype.UUID) (AppUsersSafe, error) {
@@ -82,25 +228,42 @@ func (q *Queries) GetUserByID(ctx context.Context, id pgtype.UUID) (AppUsersSafe
 		&i.CreatedAt,
 		&i.PasswordChangedAt,
 		&i.LastLoginAt,
+		&i.DisplayName,
+		&i.EmailVerifiedAt,
+		&i.Language,
 	)
 	return i, err
 }
 
 const getUsersFromList = `-- name: GetUsersFromList :many
-SELECT u.id, u.username, u.email, u.created_at, u.password_changed_at, u.last_login_at FROM app.users_safe u
+SELECT u.id, u.username, u.email, u.created_at, u.password_changed_at, u.last_login_at, u.display_name, u.email_verified_at, u.language, ul.role, ul.left_at FROM app.users_safe u
 JOIN users_lists ul ON u.id = ul.user_id
 WHERE ul.list_id = $1
 `
 
-func (q *Queries) GetUsersFromList(ctx context.Context, listID pgtype.UUID) ([]AppUsersSafe, error) {
+type GetUsersFromListRow struct {
+	ID                pgtype.UUID
+	Username          string
+	Email             string
+	CreatedAt         pgtype.Timestamptz
+	PasswordChangedAt pgtype.Timestamptz
+	LastLoginAt       pgtype.Timestamptz
+	DisplayName       pgtype.Text
+	EmailVerifiedAt   pgtype.Timestamptz
+	Language          pgtype.Text
+	Role              ListRole
+	LeftAt            pgtype.Timestamptz
+}
+
+func (q *Queries) GetUsersFromList(ctx context.Context, listID pgtype.UUID) ([]GetUsersFromListRow, error) {
 	rows, err := q.db.Query(ctx, getUsersFromList, listID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []AppUsersSafe
+	var items []GetUsersFromListRow
 	for rows.Next() {
-		var i AppUsersSafe
+		var i GetUsersFromListRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.Username,
@@ -108,6 +271,11 @@ func (q *Queries) GetUsersFromList(ctx context.Context, listID pgtype.UUID) ([]A
 			&i.CreatedAt,
 			&i.PasswordChangedAt,
 			&i.LastLoginAt,
+			&i.DisplayName,
+			&i.EmailVerifiedAt,
+			&i.Language,
+			&i.Role,
+			&i.LeftAt,
 		); err != nil {
 			return nil, err
 		}
@@ -119,6 +287,61 @@ func (q *Queries) GetUsersFromList(ctx context.Context, listID pgtype.UUID) ([]A
 	return items, nil
 }
 
+const rehashUserPassword = `-- name: RehashUserPassword :one
+SELECT app.rehash_user_password($1, $2, $3, $4)
+`
+
+type RehashUserPasswordParams struct {
+	UserID  pgtype.UUID
+	OldHash string
+	NewHash string
+	NewAlgo string
+}
+
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) (bool, error) {
+	row := q.db.QueryRow(ctx, rehashUserPassword,
+		arg.UserID,
+		arg.OldHash,
+		arg.NewHash,
+		arg.NewAlgo,
+	)
+	var rehash_user_password bool
+	err := row.Scan(&rehash_user_password)
+	return rehash_user_password, err
+}
+
+const resetPassword = `-- name: ResetPassword :one
+SELECT app.reset_password($1, $2, $3)
+`
+
+type ResetPasswordParams struct {
+	TokenHash    []byte
+	PasswordHash string
+	PasswordAlgo string
+}
+
+func (q *Queries) ResetPassword(ctx context.Context, arg ResetPasswordParams) (pgtype.UUID, error) {
+	row := q.db.QueryRow(ctx, resetPassword, arg.TokenHash, arg.PasswordHash, arg.PasswordAlgo)
+	var reset_password pgtype.UUID
+	err := row.Scan(&reset_password)
+	return reset_password, err
+}
+
+const updateProfile = `-- name: UpdateProfile :exec
+SELECT app.update_profile($1, $2, $3)
+`
+
+type UpdateProfileParams struct {
+	Username    pgtype.Text
+	DisplayName pgtype.Text
+	Language    pgtype.Text
+}
+
+func (q *Queries) UpdateProfile(ctx context.Context, arg UpdateProfileParams) error {
+	_, err := q.db.Exec(ctx, updateProfile, arg.Username, arg.DisplayName, arg.Language)
+	return err
+}
+
 const updateUserLastLogin = `-- name: UpdateUserLastLogin :exec
 SELECT app.update_last_login($1)
 `
@@ -127,3 +350,29 @@ func (q *Queries) UpdateUserLastLogin(ctx context.Context, UserID pgtype.UUID) e
 	_, err := q.db.Exec(ctx, updateUserLastLogin, UserID)
 	return err
 }
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+SELECT app.update_user_password($1, $2, $3)
+`
+
+type UpdateUserPasswordParams struct {
+	UserID          pgtype.UUID
+	NewPasswordHash string
+	NewPasswordAlgo string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.Exec(ctx, updateUserPassword, arg.UserID, arg.NewPasswordHash, arg.NewPasswordAlgo)
+	return err
+}
+
+const verifyEmail = `-- name: VerifyEmail :one
+SELECT app.verify_email($1)
+`
+
+func (q *Queries) VerifyEmail(ctx context.Context, tokenHash []byte) (pgtype.UUID, error) {
+	row := q.db.QueryRow(ctx, verifyEmail, tokenHash)
+	var verify_email pgtype.UUID
+	err := row.Scan(&verify_email)
+	return verify_email, err
+}
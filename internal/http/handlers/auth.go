@@ -1,15 +1,19 @@
 package handlers
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
 	"debt-manager/internal/db"
+	"debt-manager/internal/mail"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"net/mail"
 	"strings"
 	"time"
 
@@ -19,9 +23,9 @@ import (
 )
 
 type CreateUserRequest struct {
-	Username string
-	Email    string
-	Password string
+	Username string `json:"username" validate:"required,max=50,safechars"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=8,max=128,safechars"`
 }
 
 const(
@@ -29,11 +33,6 @@ const(
 		atTTL = 15 * time.Minute // 15 minutes
 )
 
-func isValidEmail(email string) bool {
-	_, err := mail.ParseAddress(email)
-	return err == nil
-}
-
 func containsRestrictedChars(s string) bool {
 	var restricted_chars = []string{" ", "/", "\\", "?", "%", "*", ":", "|", "\"", "<", ">"}
 	for _, char := range restricted_chars {
@@ -45,16 +44,12 @@ func containsRestrictedChars(s string) bool {
 }
 
 func (s *Server) makeAccessToken(claims *Claims) (string, error) {
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-		signed, err := token.SignedString(s.HS256PrivateKey)
-		if err != nil {
-			return "", err
-		}
-		return signed, nil
+		return s.Keys.Sign(claims)
 }
 
-func createRefreshToken() (raw string, hash []byte, err error) {
+// createHashedToken returns a random URL-safe token for the client and the
+// sha256 of it, which is what gets stored.
+func createHashedToken() (raw string, hash []byte, err error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
 		return "", nil, err
@@ -64,7 +59,10 @@ func createRefreshToken() (raw string, hash []byte, err error) {
 	return raw, h[:], nil
 }
 
-func (s *Server) createSession(user db.AppUsersSafe, w http.ResponseWriter, r *http.Request, q *db.Queries) error {
+// createSession starts a session for the user and writes its tokens. It
+// returns the new device alert of the login, if any, for the caller to send
+// with sendLoginAlert after committing.
+func (s *Server) createSession(user db.AppUsersSafe, w http.ResponseWriter, r *http.Request, q *db.Queries) (*mail.Message, error) {
 	session, err := q.CreateSession(r.Context(), db.CreateSessionParams{
 		UserID:    user.ID,
 		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(365 * 24 * time.Hour), Valid: true},
@@ -72,16 +70,17 @@ func (s *Server) createSession(user db.AppUsersSafe, w http.ResponseWriter, r *h
 		Ip: pgtype.Text{String: r.RemoteAddr, Valid: true},
 	})
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to create session")
-		log.Println("failed to create session:", err)
-		return err
+		return nil, internalError("failed to create session", err)
 	}
 
-	rtRaw, rtHash, err := createRefreshToken()
+	alert, err := s.recordLogin(w, r, q, user, session.ID)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to create refresh token")
-		log.Println("failed to create refresh token:", err)
-		return err
+		return nil, internalError("failed to create session", err)
+	}
+
+	rtRaw, rtHash, err := createHashedToken()
+	if err != nil {
+		return nil, internalError("failed to create refresh token", err)
 	}
 
 	expiresAt := time.Now().Add(expiration_time)
@@ -92,15 +91,14 @@ func (s *Server) createSession(user db.AppUsersSafe, w http.ResponseWriter, r *h
 		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
 	})
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to store refresh token")
-		log.Println("failed to store refresh token:", err)
-		return err
+		return nil, internalError("failed to store refresh token", err)
 	}
 
 	signed, err := s.makeAccessToken(&Claims{
 		SessionID: session.ID.String(),
 		UserID:    user.ID.String(),
 		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{accessAudience},
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(atTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Issuer:    "debt-manager",
@@ -108,209 +106,228 @@ func (s *Server) createSession(user db.AppUsersSafe, w http.ResponseWriter, r *h
 		},
 	})
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to create access token")
-		log.Println("failed to create access token:", err)
+		return nil, internalError("failed to create access token", err)
 	}
 
-	setCookie(w, "refresh_token", rtRaw, time.Until(expiresAt), "/auth/refresh")
-
-	writeJSON(w, http.StatusOK, map[string]any{
-		"access_token": signed,
-		"token_type":   "Bearer",
-		"expires_in":   int(atTTL.Seconds()),
-	})
-
-	return nil
+	return alert, s.writeTokens(w, r, signed, rtRaw, expiresAt)
 }
 
-func (s *Server) SignUp(w http.ResponseWriter, r *http.Request) {
+func (s *Server) SignUp(w http.ResponseWriter, r *http.Request) error {
 	var req CreateUserRequest
-	if err := decodeJSON(r, &req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
-		return
-	}
-
-	unvalid_chars_message := "%s must not contain any of the following characters: space, /, \\, ?, %, *, :, |, \", <, >"
-
-	if req.Username == "" {
-		writeError(w, http.StatusBadRequest, "username cannot be empty")
-		return
-	}
-
-	if containsRestrictedChars(req.Username) {
-		writeError(w, http.StatusBadRequest, fmt.Sprintf(unvalid_chars_message, "username"))
-		return
-	}
-
-	if req.Email == "" {
-		writeError(w, http.StatusBadRequest, "email cannot be empty")
-		return
-	}
-
-	if !isValidEmail(req.Email) {
-		writeError(w, http.StatusBadRequest, "invalid email format")
-		return
-	}
-
-	if len(req.Password) < 8 {
-		writeError(w, http.StatusBadRequest, "password must be at least 8 characters long")
-		return
-	}
-
-	if containsRestrictedChars(req.Password) {
-		writeError(w, http.StatusBadRequest, fmt.Sprintf(unvalid_chars_message, "password"))
-		return
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
 	}
 
-	hash, err := HashPassword(req.Password)
+	hash, err := HashPassword(req.Password, s.Argon2)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "failed to hash password")
-		log.Println("failed to hash password:", err)
-		return
+		return internalError("failed to hash password", err)
 	}
 
 	log.Println("Creating user:", req.Username, req.Email)
 
-	s.Tx.WithTx(r.Context(), func(q *db.Queries) error {
+	var (
+		userID pgtype.UUID
+		alert  *mail.Message
+	)
+	err = s.Tx.WithTx(r.Context(), func(q *db.Queries) error {
 		user_id, err := q.CreateUser(r.Context(), db.CreateUserParams{
 			Email: req.Email,
 			Username: req.Username,
 			PasswordHash: hash,
-			PasswordAlgo: "argon2id",
+			PasswordAlgo: PasswordAlgo,
 		})
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to create user")
-			log.Println("failed to create user:", err)
-			return err
+			return internalError("failed to create user", err)
 		}
 
 		user, err := q.GetUserByID(r.Context(), user_id)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to retrieve created user")
-			log.Println("failed to retrieve created user:", err)
-			return err
+			return internalError("failed to retrieve created user", err)
 		}
 
 		log.Println("User created with ID:", user.ID)
+		userID = user.ID
 
-		s.createSession(user, w, r, q)
-		return nil
+		alert, err = s.createSession(user, w, r, q)
+		return err
 	})
+	if err != nil {
+		return err
+	}
+
+	s.sendLoginAlert(r.Context(), alert)
+	s.mailEmailVerification(r.Context(), userID)
+	return nil
 }
 
 type LoginRequest struct {
-	Email    string
-	Password string
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
+// accessAudience is the audience of access tokens. The MFA challenge and the
+// OIDC state are signed with the same keys but carry their own audience, so
+// anything verifying access tokens, here or through the JWKS, has to check it.
+const accessAudience = "debt-manager-api"
+
 type Claims struct {
 	SessionID string `json:"session_id"`
 	UserID    string `json:"user_id"`
 	jwt.RegisteredClaims
 }
 
-func (s *Server) Login(w http.ResponseWriter, r *http.Request) {
+func (s *Server) Login(w http.ResponseWriter, r *http.Request) error {
 	var req LoginRequest
 
-	// Decode JSON body
-	if err := decodeJSON(r, &req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
-		return
-	}
-
-	// Validate input
-	if req.Email == "" {
-		writeError(w, http.StatusBadRequest, "email cannot be empty")
-		return
+	// Decode and validate JSON body
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
 	}
 
-	if req.Password == "" {
-		writeError(w, http.StatusBadRequest, "password cannot be empty")
-		return
+	key := accountKey("login", req.Email)
+	if err := s.throttleAccount(r.Context(), key, accountLoginLimit); err != nil {
+		return err
 	}
 
-	s.Tx.WithTx(r.Context(), func(q *db.Queries) error {
+	var (
+		userID    pgtype.UUID
+		staleHash string
+		alert     *mail.Message
+	)
+	err := s.Tx.WithTx(r.Context(), func(q *db.Queries) error {
+		// Unknown emails and accounts without a password still cost a hash,
+		// so the answer takes as long as for a wrong password.
 		user, err := q.GetUserByEmail(r.Context(), req.Email)
 		if err != nil {
 			log.Println("failed to get user by email:", err)
-			writeError(w, http.StatusUnauthorized, "invalid email or password")
-			return err
+			VerifyPassword(req.Password, dummyHash(s.Argon2))
+			s.failAccount(r.Context(), key)
+			return unauthorized("invalid_email_or_password", "invalid email or password")
 		}
 
 		loginSecrets, err := q.GetLoginSecretsByEmail(r.Context(), user.Email)
-		if err != nil {
-			log.Println("failed to get login secrets by email:", err)
-			writeError(w, http.StatusUnauthorized, "invalid email or password")
-			return err
+		if err != nil || !loginSecrets.PasswordHash.Valid {
+			VerifyPassword(req.Password, dummyHash(s.Argon2))
+			s.failAccount(r.Context(), key)
+			s.recordFailedLogin(r, user.ID)
+			return unauthorized("invalid_email_or_password", "invalid email or password")
 		}
 
 		password_ok, err := VerifyPassword(req.Password, loginSecrets.PasswordHash.String)
 		if err != nil || !password_ok {
 			log.Println("failed to verify password:", err)
-			writeError(w, http.StatusUnauthorized, "invalid email or password")
-			return err
+			s.failAccount(r.Context(), key)
+			s.recordFailedLogin(r, user.ID)
+			return unauthorized("invalid_email_or_password", "invalid email or password")
 		}
+		s.succeedAccount(r.Context(), key)
 
-		if err := q.UpdateUserLastLogin(r.Context(), user.ID); err != nil {
-			log.Println("failed to update last login:", err)
-			return err
+		if NeedsRehash(loginSecrets.PasswordHash.String, loginSecrets.PasswordAlgo, s.Argon2) {
+			userID, staleHash = user.ID, loginSecrets.PasswordHash.String
 		}
 
-		s.createSession(user, w, r, q)
-		return nil
+		alert, err = s.continueLogin(user, w, r, q)
+		return err
 	})
+	if err != nil {
+		return err
+	}
+
+	s.sendLoginAlert(r.Context(), alert)
+	if staleHash != "" {
+		s.rehashPassword(r.Context(), userID, staleHash, req.Password)
+	}
+	return nil
 }
 
-func (s *Server) Refresh(w http.ResponseWriter, r *http.Request) {
-	c, err := r.Cookie("refresh_token")
+// rehashPassword upgrades a stored hash while the plain password is at hand.
+// It runs after the login committed, in a transaction of its own, so that
+// failing to do so does not fail the login.
+func (s *Server) rehashPassword(ctx context.Context, userID pgtype.UUID, oldHash, password string) {
+	hash, err := HashPassword(password, s.Argon2)
 	if err != nil {
-		writeError(w, http.StatusUnauthorized, "missing refresh token")
+		log.Println("failed to rehash password:", err)
 		return
 	}
+	err = s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		_, err := q.RehashUserPassword(ctx, db.RehashUserPasswordParams{
+			UserID:  userID,
+			OldHash: oldHash,
+			NewHash: hash,
+			NewAlgo: PasswordAlgo,
+		})
+		return err
+	})
+	if err != nil {
+		log.Println("failed to store rehashed password:", err)
+	}
+}
+
+// continueLogin runs once the user proved who they are, by password or through
+// an identity provider: users with two-factor authentication get a challenge,
+// everyone else a session.
+func (s *Server) continueLogin(user db.AppUsersSafe, w http.ResponseWriter, r *http.Request, q *db.Queries) (*mail.Message, error) {
+	mfa, err := q.GetUserMFA(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, internalError("failed to log in", err)
+	}
+	if err == nil && mfa.ConfirmedAt.Valid {
+		return nil, s.startMFAChallenge(user, w)
+	}
+
+	return s.completeLogin(user, w, r, q)
+}
+
+// completeLogin records the login and hands out a new session. It is the last
+// step of both a password login and an MFA challenge.
+func (s *Server) completeLogin(user db.AppUsersSafe, w http.ResponseWriter, r *http.Request, q *db.Queries) (*mail.Message, error) {
+	if err := q.UpdateUserLastLogin(r.Context(), user.ID); err != nil {
+		return nil, internalError("failed to log in", err)
+	}
 
-	hash := sha256.Sum256([]byte(c.Value))
+	return s.createSession(user, w, r, q)
+}
+
+func (s *Server) Refresh(w http.ResponseWriter, r *http.Request) error {
+	rtRaw, err := readRefreshToken(w, r)
+	if err != nil {
+		return err
+	}
+	if s.CookieMode && !isNativeClient(r) && !verifyCSRF(r) {
+		return forbidden("invalid_csrf_token", "invalid csrf token")
+	}
+
+	hash := sha256.Sum256([]byte(rtRaw))
 
 	ctx := r.Context()
+	// A refresh can be denied after revoking the session, which has to be
+	// committed; the denial is returned once the transaction is.
+	var denied error
 	err = s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		// The lookup locks the token, so concurrent refreshes with the same
+		// token take turns and all but the first find it replaced.
 		row, err := q.AuthRefreshLookup(ctx, hash[:])
 		if err != nil {
-			writeError(w, http.StatusUnauthorized, "invalid refresh token")
-			log.Println("failed to lookup refresh token:", err)
-			return err
+			return unauthorized("invalid_refresh_token", "invalid refresh token")
 		}
 
 		if row.SessionRevokedAt.Valid {
-			writeError(w, http.StatusUnauthorized, "session revoked")
-			return nil
+			return unauthorized("session_revoked", "session revoked")
 		}
 
 		if row.RtRevokedAt.Valid || row.RtExpiresAt.Time.Before(time.Now()) {
-			writeError(w, http.StatusUnauthorized, "refresh token revoked")
-			return nil
+			return unauthorized("refresh_token_revoked", "refresh token revoked")
 		}
 
 		if row.RtReplacedByID.Valid {
-			q.RevokeWholeSession(ctx, row.SessionID)
-			clearCookie(w, "access_token")
-			writeError(w, http.StatusUnauthorized, "refresh token reused")
-			return errors.New("refresh token reused")
-		}
-
-
-		return nil
-	})
-
-	if err != nil {
-		return
-	}
-
-	err = s.Tx.WithTx(ctx, func(q *db.Queries) error {
-		row, err := q.AuthRefreshLookup(ctx, hash[:])
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to get session details")
-			log.Println("failed to get session details:", err)
+			err := s.refreshReplacedToken(w, r, q, row, rtRaw)
+			if errors.Is(err, ErrUnauthorized) {
+				denied = err
+				return nil
+			}
 			return err
 		}
+
 		expire_at := time.Now().Add(expiration_time)
 		if row.MaxExpiresAt.Valid && row.MaxExpiresAt.Time.Before(expire_at) {
 			expire_at = row.MaxExpiresAt.Time
@@ -320,27 +337,22 @@ func (s *Server) Refresh(w http.ResponseWriter, r *http.Request) {
 			q.RevokeWholeSession(ctx, row.SessionID)
 			clearCookie(w, "access_token")
 			clearCookie(w, "refresh_token")
-			writeError(w, http.StatusUnauthorized, "session expired")
-			return nil;
-		}
-
-		new_rt_raw, new_rt_hash, err := createRefreshToken()
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to create refresh token")
-			log.Println("failed to create refresh token:", err)
-			return err
+			denied = unauthorized("session_expired", "session expired")
+			return nil
 		}
 
 		new_rt_id := uuid.New()
-		log.Println("Old token ID:", row.RtID.Bytes, "   New token ID:", new_rt_id)
+		new_rt_raw, new_rt_hash := nextRefreshToken(rtRaw, new_rt_id)
+
 		affected, err := q.MarkOldTokenReplaced(ctx, db.MarkOldTokenReplacedParams{
 			ID: row.RtID,
 			ReplacedByID: pgtype.UUID{Bytes: new_rt_id, Valid: true},
 		})
 		if err != nil || affected == 0 {
-			writeError(w, http.StatusInternalServerError, "failed to mark old refresh token as replaced")
-			log.Println("failed to mark old refresh token as replaced:", err)
-			return err
+			if err == nil {
+				err = errors.New("refresh token already replaced")
+			}
+			return internalError("failed to mark old refresh token as replaced", err)
 		}
 
 		_, err = q.CreateRefreshToken(ctx, db.CreateRefreshTokenParams{
@@ -354,35 +366,76 @@ func (s *Server) Refresh(w http.ResponseWriter, r *http.Request) {
 			},
 		})
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to store refresh token")
-			log.Println("failed to store refresh token:", err)
-			return err
+			return internalError("failed to store refresh token", err)
 		}
 
-		at, err := s.makeAccessToken(&Claims{
-			SessionID: row.SessionID.String(),
-			UserID:    row.UserID.String(),
-			RegisteredClaims: jwt.RegisteredClaims{
-				ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-				IssuedAt:  jwt.NewNumericDate(time.Now()),
-				Issuer:    "debt-manager",
-				Subject:   fmt.Sprint(row.UserID),
-			},
-		})
+		return s.writeRefreshedTokens(w, r, row, new_rt_raw, expire_at)
+	})
+	if err != nil {
+		return err
+	}
+	return denied
+}
+
+// nextRefreshToken derives the token that replaces raw. Only the hash of a
+// refresh token is stored, yet a refresh inside the grace window has to hand
+// out the same successor again; deriving it from the old token and the new
+// token's ID allows that without keeping the raw token around. Neither half
+// is known to someone holding only the database or only the old token.
+func nextRefreshToken(raw string, id uuid.UUID) (string, []byte) {
+	mac := hmac.New(sha256.New, []byte(raw))
+	mac.Write(id[:])
+	next := base64.URLEncoding.EncodeToString(mac.Sum(nil))
+	h := sha256.Sum256([]byte(next))
+	return next, h[:]
+}
+
+// refreshReplacedToken handles a refresh token that was already used. Past
+// the grace window that means it was copied, and the session is revoked.
+// Within it, it is most likely a second tab refreshing at the same time,
+// which gets the token its twin got. A reuse is answered with an
+// unauthorized problem, and the revocation must still be committed.
+func (s *Server) refreshReplacedToken(w http.ResponseWriter, r *http.Request, q *db.Queries, row db.AuthRefreshLookupRow, raw string) error {
+	ctx := r.Context()
+
+	if row.RtReplacedAt.Valid && time.Since(row.RtReplacedAt.Time) <= s.RefreshGrace {
+		next, err := q.GetRefreshTokenByID(ctx, row.RtReplacedByID)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to create access token")
-			log.Println("failed to create access token:", err)
-			return nil
+			return internalError("failed to get refresh token", err)
 		}
 
-		setCookie(w, "refresh_token", new_rt_raw, time.Until(expire_at), "/auth/refresh")
+		nextRaw, nextHash := nextRefreshToken(raw, next.ID.Bytes)
+		if !next.ReplacedByID.Valid && !next.RevokedAt.Valid &&
+			subtle.ConstantTimeCompare(nextHash, next.TokenHash) == 1 {
+			return s.writeRefreshedTokens(w, r, row, nextRaw, next.ExpiresAt.Time)
+		}
+	}
 
-		writeJSON(w, http.StatusOK, map[string]any{
-			"access_token": at,
-			"token_type":   "Bearer",
-			"expires_in":   int(atTTL.Seconds()),
-		})
+	if err := q.RevokeWholeSession(ctx, row.SessionID); err != nil {
+		return internalError("failed to revoke session", err)
+	}
+	log.Println("refresh token reused, session revoked:", row.SessionID)
+	clearAuthCookies(w)
+	return unauthorized("refresh_token_reused", "refresh token reused")
+}
 
-		return nil
+// writeRefreshedTokens answers a refresh with a new access token and the
+// given refresh token.
+func (s *Server) writeRefreshedTokens(w http.ResponseWriter, r *http.Request, row db.AuthRefreshLookupRow, rtRaw string, expiresAt time.Time) error {
+	at, err := s.makeAccessToken(&Claims{
+		SessionID: row.SessionID.String(),
+		UserID:    row.UserID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{accessAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(atTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "debt-manager",
+			Subject:   fmt.Sprint(row.UserID),
+		},
 	})
+	if err != nil {
+		return internalError("failed to create access token", err)
+	}
+
+	return s.writeTokens(w, r, at, rtRaw, expiresAt)
 }
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Browsers keep the refresh token in an HttpOnly cookie scoped to
+// /auth/refresh. Native apps have no use for cookies and send
+// "X-Client-Type: native" instead; they get the refresh token in the JSON
+// body and send it back in the body of /auth/refresh. Rotation and reuse
+// detection are the same for both.
+const (
+	clientTypeHeader = "X-Client-Type"
+	nativeClient     = "native"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+func isNativeClient(r *http.Request) bool {
+	return strings.EqualFold(strings.TrimSpace(r.Header.Get(clientTypeHeader)), nativeClient)
+}
+
+// readRefreshToken takes the refresh token from where the client type keeps
+// it. The error is a problem ready to be returned.
+func readRefreshToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if isNativeClient(r) {
+		var req RefreshRequest
+		if err := decodeJSON(w, r, &req); err != nil {
+			return "", err
+		}
+		return req.RefreshToken, nil
+	}
+
+	c, err := r.Cookie("refresh_token")
+	if err != nil || c.Value == "" {
+		return "", unauthorized("missing_refresh_token", "missing refresh token")
+	}
+	return c.Value, nil
+}
+
+// writeTokens answers a login or refresh with an access token and the refresh
+// token, which goes into a cookie or, for native clients, into the body. In
+// cookie mode browsers get the access token as a cookie too, together with
+// a CSRF token, and never see it in the body.
+func (s *Server) writeTokens(w http.ResponseWriter, r *http.Request, accessToken, refreshToken string, refreshExpiresAt time.Time) error {
+	resp := map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(atTTL.Seconds()),
+	}
+
+	switch {
+	case isNativeClient(r):
+		resp["refresh_token"] = refreshToken
+		resp["refresh_expires_in"] = int(time.Until(refreshExpiresAt).Seconds())
+	case s.CookieMode:
+		csrf, err := csrfToken(r)
+		if err != nil {
+			return internalError("failed to create csrf token", err)
+		}
+		setCookie(w, "access_token", accessToken, atTTL)
+		setCookie(w, "refresh_token", refreshToken, time.Until(refreshExpiresAt), "/auth/refresh")
+		setCSRFCookie(w, csrf, time.Until(refreshExpiresAt))
+		delete(resp, "access_token")
+		resp["token_type"] = "Cookie"
+		resp["csrf_token"] = csrf
+	default:
+		setCookie(w, "refresh_token", refreshToken, time.Until(refreshExpiresAt), "/auth/refresh")
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+	return nil
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"time"
+)
+
+// Requests authenticated by cookie are open to cross-site request forgery, so
+// in cookie mode the client also gets a csrf_token cookie it can read and has
+// to echo in the X-CSRF-Token header of every unsafe request. Another site can
+// make the browser send the cookie but cannot read it to set the header.
+const (
+	csrfCookie = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// verifyCSRF is the double-submit check: the header must match the cookie.
+func verifyCSRF(r *http.Request) bool {
+	if isSafeMethod(r.Method) {
+		return true
+	}
+	c, err := r.Cookie(csrfCookie)
+	if err != nil || c.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.Value), []byte(r.Header.Get(csrfHeader))) == 1
+}
+
+// csrfToken returns the client's current CSRF token, or a new one if it has
+// none. The token is kept across refreshes so requests already in flight with
+// the old header do not fail.
+func csrfToken(r *http.Request) (string, error) {
+	if c, err := r.Cookie(csrfCookie); err == nil && c.Value != "" {
+		return c.Value, nil
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// setCSRFCookie is setCookie without HttpOnly, the frontend has to read it.
+func setCSRFCookie(w http.ResponseWriter, token string, ttl time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Expires:  time.Now().Add(ttl),
+		Path:     "/",
+	})
+}
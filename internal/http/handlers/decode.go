@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"debt-manager/internal/i18n"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Request bodies go through decodeJSON, which checks them against the
+// validate tags of the request struct. A body that is not JSON is a 400; one
+// that is, but does not fit the struct or breaks its rules, is a 422 listing
+// every field at fault, described in the language of the request.
+
+const maxBodyBytes = 1 << 20
+
+// maxMoney is the largest amount a numeric(12, 2) column holds.
+const maxMoney = 9_999_999_999.99
+
+var validate = newValidator()
+
+// normalizer is implemented by request types that tidy up their fields, say
+// by trimming them, before the fields are validated.
+type normalizer interface {
+	normalize()
+}
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	// Field errors name fields the way clients send them.
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	for tag, fn := range map[string]validator.Func{
+		"currency":  validateCurrency,
+		"language":  validateLanguage,
+		"money":     validateMoney,
+		"notblank":  validateNotBlank,
+		"safechars": validateSafeChars,
+		"scope":     validateScope,
+	} {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			panic(err)
+		}
+	}
+	if err := i18n.RegisterValidationTranslations(v); err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// validateCurrency accepts the currencies of the currency enum.
+func validateCurrency(fl validator.FieldLevel) bool {
+	return Currency(fl.Field().String()).Valid()
+}
+
+// validateLanguage accepts the supported languages, and the empty string
+// that clears a language setting.
+func validateLanguage(fl validator.FieldLevel) bool {
+	lang := fl.Field().String()
+	return lang == "" || i18n.Supported(lang)
+}
+
+// validateMoney accepts amounts that fit a numeric(12, 2) column without
+// rounding.
+func validateMoney(fl validator.FieldLevel) bool {
+	f := fl.Field().Float()
+	if math.IsNaN(f) || math.Abs(f) > maxMoney {
+		return false
+	}
+	cents := f * 100
+	return math.Abs(cents-math.Round(cents)) < 1e-6
+}
+
+func validateNotBlank(fl validator.FieldLevel) bool {
+	return strings.TrimSpace(fl.Field().String()) != ""
+}
+
+// validateSafeChars keeps names and passwords free of characters that cause
+// trouble in URLs, file names and shells.
+func validateSafeChars(fl validator.FieldLevel) bool {
+	return !containsRestrictedChars(fl.Field().String())
+}
+
+func validateScope(fl validator.FieldLevel) bool {
+	return knownScopes[fl.Field().String()]
+}
+
+// decodeJSON reads a JSON request body into dst and validates it. The error
+// is always a problem ready to be returned. An empty body decodes as an empty
+// object, so endpoints whose fields are all optional need not get one.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	defer r.Body.Close()
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err == nil {
+		if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+			return badRequest("invalid_json", "request body must be a single JSON value")
+		}
+	} else if !errors.Is(err, io.EOF) {
+		return decodeProblem(err)
+	}
+
+	if n, ok := dst.(normalizer); ok {
+		n.normalize()
+	}
+	if err := validate.Struct(dst); err != nil {
+		var verrs validator.ValidationErrors
+		if errors.As(err, &verrs) {
+			return invalid(fieldErrors(verrs, requestLanguage(r.Context()))...)
+		}
+		return internalError("failed to validate request", err)
+	}
+	return nil
+}
+
+// decodeProblem tells a body that is not JSON (400) from one that does not
+// fit the request struct (422).
+func decodeProblem(err error) error {
+	var (
+		maxErr    *http.MaxBytesError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &maxErr):
+		return newProblem(ErrPayloadTooLarge, "body_too_large", "request body must not be larger than {0} bytes").with(strconv.FormatInt(maxErr.Limit, 10))
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return badRequest("invalid_json", "invalid JSON body")
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			return badRequest("invalid_json", "request body must be a JSON object")
+		}
+		return invalid(fieldError(field, "invalid_type", "{0} must be of type {1}", field, jsonType(typeErr.Type)))
+	}
+
+	// DisallowUnknownFields has no error type of its own.
+	if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field := strings.Trim(name, `"`)
+		return invalid(fieldError(field, "unknown_field", "unknown field {0}", field))
+	}
+	// Types with their own UnmarshalJSON, like uuid.UUID and time.Time,
+	// report bad values with their own errors.
+	return badRequest("invalid_json", "invalid JSON body: {0}").with(err.Error())
+}
+
+// jsonType names a Go type the way a JSON client would think of it.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func fieldErrors(verrs validator.ValidationErrors, lang string) []FieldError {
+	trans := i18n.Translator(lang)
+	errs := make([]FieldError, 0, len(verrs))
+	for _, fe := range verrs {
+		// The namespace starts with the name of the request struct.
+		_, field, _ := strings.Cut(fe.Namespace(), ".")
+		errs = append(errs, fieldError(field, fe.Tag(), fe.Translate(trans)))
+	}
+	return errs
+}
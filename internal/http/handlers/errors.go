@@ -2,6 +2,6 @@ package handlers
 
 import "net/http"
 
-func (s *Server) NotFoundHandler(w http.ResponseWriter, r *http.Request) {
-	writeError(w, http.StatusNotFound, "endpoint not found")
+func (s *Server) NotFoundHandler(w http.ResponseWriter, r *http.Request) error {
+	return notFound("endpoint_not_found", "endpoint not found")
 }
@@ -8,16 +8,12 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/argon2"
 )
 
-func decodeJSON(r *http.Request, dst any) error {
-	defer r.Body.Close()
-	return json.NewDecoder(r.Body).Decode(dst)
-}
-
 func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -26,9 +22,8 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	}
 }
 
-func writeError(w http.ResponseWriter, status int, msg string) {
-	writeJSON(w, status, map[string]string{"error": msg})
-}
+// PasswordAlgo is stored next to every hash made by HashPassword.
+const PasswordAlgo = "argon2id"
 
 type Argon2Params struct {
 	Time    uint32
@@ -38,7 +33,7 @@ type Argon2Params struct {
 	SaltLen uint32
 }
 
-var defalutA2 = Argon2Params{
+var DefaultArgon2 = Argon2Params{
 	Time:    2,
 	Memory:  64 * 1024,
 	Threads: 1,
@@ -46,33 +41,113 @@ var defalutA2 = Argon2Params{
 	SaltLen: 16,
 }
 
-func HashPassword(password string) (string, error) {
-	salt := make([]byte, defalutA2.SaltLen)
+// legacyArgon2 are the parameters of hashes stored as salt$hash, before the
+// parameters were part of the hash. They must never change.
+var legacyArgon2 = Argon2Params{
+	Time:    2,
+	Memory:  64 * 1024,
+	Threads: 1,
+	KeyLen:  32,
+	SaltLen: 16,
+}
+
+// HashPassword returns an argon2id hash in PHC string format:
+// $argon2id$v=19$m=65536,t=2,p=1$salt$hash
+func HashPassword(password string, p Argon2Params) (string, error) {
+	salt := make([]byte, p.SaltLen)
 	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
-	hash := argon2.IDKey([]byte(password), salt, defalutA2.Time, defalutA2.Memory, defalutA2.Threads, defalutA2.KeyLen)
-	return base64.RawStdEncoding.EncodeToString(salt) + "$" + base64.RawStdEncoding.EncodeToString(hash), nil
-
+	hash := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Time, p.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	), nil
 }
 
-func VerifyPassword(password, encodedHash string) (bool, error) {
-	parts := strings.SplitN(encodedHash, "$", 2)
-	if len(parts) != 2 {
-		return false, fmt.Errorf("invalid hash format")
+// decodeHash splits a stored hash into its parameters, salt and hash. Legacy
+// salt$hash values get legacyArgon2.
+func decodeHash(encodedHash string) (Argon2Params, []byte, []byte, error) {
+	p := legacyArgon2
+	var encSalt, encHash string
+
+	if strings.HasPrefix(encodedHash, "$") {
+		parts := strings.Split(encodedHash, "$")
+		if len(parts) != 6 || parts[1] != "argon2id" {
+			return p, nil, nil, fmt.Errorf("invalid hash format")
+		}
+		var version int
+		if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+			return p, nil, nil, fmt.Errorf("unsupported argon2 version")
+		}
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads); err != nil {
+			return p, nil, nil, fmt.Errorf("invalid hash parameters: %w", err)
+		}
+		encSalt, encHash = parts[4], parts[5]
+	} else {
+		parts := strings.SplitN(encodedHash, "$", 2)
+		if len(parts) != 2 {
+			return p, nil, nil, fmt.Errorf("invalid hash format")
+		}
+		encSalt, encHash = parts[0], parts[1]
 	}
-	salt, err := base64.RawStdEncoding.DecodeString(parts[0])
+
+	salt, err := base64.RawStdEncoding.DecodeString(encSalt)
 	if err != nil {
-		return false, err
+		return p, nil, nil, err
 	}
-	hash, err := base64.RawStdEncoding.DecodeString(parts[1])
+	hash, err := base64.RawStdEncoding.DecodeString(encHash)
+	if err != nil {
+		return p, nil, nil, err
+	}
+	p.SaltLen = uint32(len(salt))
+	p.KeyLen = uint32(len(hash))
+	return p, salt, hash, nil
+}
+
+func VerifyPassword(password, encodedHash string) (bool, error) {
+	p, salt, hash, err := decodeHash(encodedHash)
 	if err != nil {
 		return false, err
 	}
-	computedHash := argon2.IDKey([]byte(password), salt, defalutA2.Time, defalutA2.Memory, defalutA2.Threads, defalutA2.KeyLen)
+	computedHash := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
 	return subtle.ConstantTimeCompare(hash, computedHash) == 1, nil
 }
 
+// NeedsRehash reports whether a verified hash should be replaced by one made
+// with the current parameters: it is in the legacy format, was made by
+// another algorithm or with other parameters.
+func NeedsRehash(encodedHash, algo string, current Argon2Params) bool {
+	if algo != PasswordAlgo || !strings.HasPrefix(encodedHash, "$") {
+		return true
+	}
+	p, _, _, err := decodeHash(encodedHash)
+	return err != nil || p != current
+}
+
+// dummyHashes holds one dummyHash per set of parameters.
+var dummyHashes sync.Map
+
+// dummyHash returns a hash of a random password, made with the given
+// parameters. A login for an account that does not exist, or has no password,
+// is checked against it, so it takes as long as a wrong password would.
+func dummyHash(p Argon2Params) string {
+	if h, ok := dummyHashes.Load(p); ok {
+		return h.(string)
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	h, err := HashPassword(base64.RawStdEncoding.EncodeToString(b), p)
+	if err != nil {
+		return ""
+	}
+	stored, _ := dummyHashes.LoadOrStore(p, h)
+	return stored.(string)
+}
+
 func setCookie(w http.ResponseWriter, name, value string, ttl time.Duration, path ...string) {
 	p := "/"
 	if len(path) > 0 {
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// testArgon2 keeps the tests fast; only hashes made with it are checked
+// against it.
+var testArgon2 = Argon2Params{Time: 1, Memory: 1024, Threads: 1, KeyLen: 32, SaltLen: 16}
+
+// legacyHash makes a hash the way passwords were stored before PHC strings.
+func legacyHash(password string) string {
+	salt := []byte("0123456789abcdef")
+	p := legacyArgon2
+	hash := argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+	return base64.RawStdEncoding.EncodeToString(salt) + "$" + base64.RawStdEncoding.EncodeToString(hash)
+}
+
+func TestVerifyPassword(t *testing.T) {
+	current, err := HashPassword("correct horse", testArgon2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := legacyHash("correct horse")
+
+	tests := []struct {
+		name     string
+		hash     string
+		password string
+		want     bool
+		wantErr  bool
+	}{
+		{name: "PHC hash", hash: current, password: "correct horse", want: true},
+		{name: "PHC hash, wrong password", hash: current, password: "battery staple"},
+		{name: "legacy hash", hash: legacy, password: "correct horse", want: true},
+		{name: "legacy hash, wrong password", hash: legacy, password: "battery staple"},
+		{name: "garbage", hash: "not a hash", password: "correct horse", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyPassword(tt.password, tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyPassword error = %v; want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyPassword = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeHash(t *testing.T) {
+	salt := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	hash := base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+
+	tests := []struct {
+		name    string
+		hash    string
+		want    Argon2Params
+		wantErr bool
+	}{
+		{
+			name: "PHC",
+			hash: "$argon2id$v=19$m=1024,t=3,p=4$" + salt + "$" + hash,
+			want: Argon2Params{Time: 3, Memory: 1024, Threads: 4, KeyLen: 32, SaltLen: 16},
+		},
+		{name: "legacy", hash: salt + "$" + hash, want: legacyArgon2},
+		{name: "other algorithm", hash: "$argon2i$v=19$m=1024,t=3,p=4$" + salt + "$" + hash, wantErr: true},
+		{name: "other version", hash: "$argon2id$v=16$m=1024,t=3,p=4$" + salt + "$" + hash, wantErr: true},
+		{name: "bad parameters", hash: "$argon2id$v=19$m=x,t=3,p=4$" + salt + "$" + hash, wantErr: true},
+		{name: "missing hash", hash: "$argon2id$v=19$m=1024,t=3,p=4$" + salt, wantErr: true},
+		{name: "bad salt", hash: "$argon2id$v=19$m=1024,t=3,p=4$!!$" + hash, wantErr: true},
+		{name: "legacy without separator", hash: salt, wantErr: true},
+		{name: "legacy bad hash", hash: salt + "$!!", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, _, err := decodeHash(tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeHash error = %v; want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("decodeHash params = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	current, err := HashPassword("correct horse", testArgon2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weaker := testArgon2
+	weaker.Memory /= 2
+	outdated, err := HashPassword("correct horse", weaker)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		hash string
+		algo string
+		want bool
+	}{
+		{name: "current parameters", hash: current, algo: PasswordAlgo, want: false},
+		{name: "other parameters", hash: outdated, algo: PasswordAlgo, want: true},
+		{name: "legacy format", hash: legacyHash("correct horse"), algo: PasswordAlgo, want: true},
+		{name: "other algorithm", hash: current, algo: "bcrypt", want: true},
+		{name: "malformed PHC", hash: "$argon2id$v=19$m=x", algo: PasswordAlgo, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NeedsRehash(tt.hash, tt.algo, testArgon2); got != tt.want {
+				t.Errorf("NeedsRehash = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDummyHash(t *testing.T) {
+	h := dummyHash(testArgon2)
+	if h == "" {
+		t.Fatal("dummyHash returned no hash")
+	}
+	if again := dummyHash(testArgon2); again != h {
+		t.Errorf("dummyHash = %q, then %q; want the same hash", h, again)
+	}
+	if NeedsRehash(h, PasswordAlgo, testArgon2) {
+		t.Errorf("dummyHash is not made with the given parameters")
+	}
+	ok, err := VerifyPassword("", h)
+	if err != nil || ok {
+		t.Errorf("VerifyPassword against dummyHash = %v, %v; want false, nil", ok, err)
+	}
+}
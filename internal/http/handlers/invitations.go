@@ -14,6 +14,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -51,32 +52,22 @@ func generateInvitationLink(hash string) (string, error) {
 	return base.String(), nil
 }
 
-func (s *Server) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateInvitation(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	listId, err := uuid.Parse(chi.URLParam(r, "list_id"))
 	PGListId := pgtype.UUID{Bytes: listId, Valid: true}
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid list ID")
-		return
+		return badRequest("invalid_list_id", "invalid list ID")
 	}
 
 	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
-		_, err := q.GetListByID(ctx, PGListId)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				writeError(w, http.StatusNotFound, "list not found")
-				return nil
-			}
-			writeError(w, http.StatusInternalServerError, "failed to retrieve list")
-			log.Println("failed to retrieve list:", err)
+		if _, err := requireListRole(ctx, q, PGListId, db.ListRoleAdmin); err != nil {
 			return err
 		}
 
 		invitationHash, err := generateInvitationHash()
 		if err != nil {
-			log.Println("failed to generate invitation hash:", err)
-			writeError(w, http.StatusInternalServerError, "failed to generate invitation hash")
-			return err
+			return internalError("failed to generate invitation hash", err)
 		}
 
 		createdBy := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
@@ -87,33 +78,26 @@ func (s *Server) CreateInvitation(w http.ResponseWriter, r *http.Request) {
 			ExpiresAt:       pgtype.Timestamptz{Time: time.Now().Add(2 * time.Hour), Valid: true},
 		})
 		if err != nil {
-			log.Println("failed to create invitation:", err)
-			writeError(w, http.StatusInternalServerError, "failed to create invitation")
-			return err
+			return internalError("failed to create invitation", err)
 		}
 
 		invitationLink, err := generateInvitationLink(invitationHash)
 		if err != nil {
-			log.Println("failed to generate invitation link:", err)
-			writeError(w, http.StatusInternalServerError, "failed to generate invitation link")
-			return err
+			return internalError("failed to generate invitation link", err)
 		}
 		writeJSON(w, http.StatusCreated, map[string]string{
 			"invitation_link": invitationLink,
 		})
 		return nil
 	})
-	if err != nil {
-		log.Println("transaction error:", err)
-	}
+	return err
 }
 
-func (s *Server) GetAllInvitationsForList(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetAllInvitationsForList(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	list_id, err := uuid.Parse(chi.URLParam(r, "list_id"))
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid list ID")
-		return
+		return badRequest("invalid_list_id", "invalid list ID")
 	}
 
 	PGListId := pgtype.UUID{Bytes: list_id, Valid: true}
@@ -121,12 +105,9 @@ func (s *Server) GetAllInvitationsForList(w http.ResponseWriter, r *http.Request
 		invitations, err := q.GetAllInvitationsForList(ctx, PGListId)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				writeError(w, http.StatusNotFound, "list not found")
-				return nil
+				return notFound("list_not_found", "list not found")
 			}
-			writeError(w, http.StatusInternalServerError, "failed to retrieve invitations")
-			log.Println("failed to retrieve invitations:", err)
-			return err
+			return internalError("failed to retrieve invitations", err)
 		}
 
 
@@ -134,13 +115,11 @@ func (s *Server) GetAllInvitationsForList(w http.ResponseWriter, r *http.Request
 		for i, invitation := range invitations {
 			invitedByUser, err := q.GetUserByID(ctx, invitation.CreatedBy)
 			if err != nil {
-				writeError(w, http.StatusInternalServerError, "failed to retrieve user")
-				log.Println("failed to retrieve user:", err)
+				return internalError("failed to retrieve user", err)
 			}
 			listTitle, err := q.GetListByID(ctx, invitation.InvitedToListID)
 			if err != nil {
-				writeError(w, http.StatusInternalServerError, "failed to retrieve list")
-				log.Println("failed to retrieve list:", err)
+				return internalError("failed to retrieve list", err)
 			}
 
 			responses[i] = InvitationResponse{
@@ -157,38 +136,33 @@ func (s *Server) GetAllInvitationsForList(w http.ResponseWriter, r *http.Request
 		writeJSON(w, http.StatusOK, responses)
 		return nil
 	})
+	return err
 }
 
-func (s *Server) GetInvitationByHash(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetInvitationByHash(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	hash := chi.URLParam(r, "hash")
 	if hash == "" {
-		writeError(w, http.StatusBadRequest, "invalid invitation hash")
-		return
+		return badRequest("invalid_invitation_hash", "invalid invitation hash")
 	}
 
 	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
 		invitation, err := q.GetInvitationByHash(ctx, hash)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				writeError(w, http.StatusNotFound, "invitation not found")
-				return nil
+				return notFound("invitation_not_found", "invitation not found")
 			}
-			writeError(w, http.StatusInternalServerError, "failed to retrieve invitation")
-			log.Println("failed to retrieve invitation:", err)
-			return err
+			return internalError("failed to retrieve invitation", err)
 		}
 
 		list, err := q.GetListByID(ctx, invitation.InvitedToListID)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to retrieve list")
-			log.Println("failed to retrieve list:", err)
+			return internalError("failed to retrieve list", err)
 		}
 
 		invitedByUser, err := q.GetUserByID(ctx, invitation.CreatedBy)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to retrieve user")
-			log.Println("failed to retrieve user:", err)
+			return internalError("failed to retrieve user", err)
 		}
 
 		revokedAt := invitation.RevokedAt.Time.Format("2006-01-02T15:04:05Z07:00")
@@ -206,70 +180,67 @@ func (s *Server) GetInvitationByHash(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusOK, response)
 		return nil
 	})
-	if err != nil {
-		log.Println("transaction error:", err)
-	}
+	return err
 }
 
-func (s *Server) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+func (s *Server) RevokeInvitation(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	invitationId, err := uuid.Parse(chi.URLParam(r, "invitation_id"))
 	PGInvitationId := pgtype.UUID{Bytes: invitationId, Valid: true}
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid invitation ID")
-		return
+		return badRequest("invalid_invitation_id", "invalid invitation ID")
 	}
 
 	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
-		err := q.RevokeInvitationByID(ctx, PGInvitationId)
+		invitation, err := q.GetInvitationByID(ctx, PGInvitationId)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				writeError(w, http.StatusNotFound, "invitation not found")
-				return nil
+				return notFound("invitation_not_found", "invitation not found")
 			}
-			writeError(w, http.StatusInternalServerError, "failed to delete invitation")
-			log.Println("failed to delete invitation:", err)
+			return internalError("failed to retrieve invitation", err)
+		}
+		if _, err := requireListRole(ctx, q, invitation.InvitedToListID, db.ListRoleAdmin); err != nil {
 			return err
 		}
 
+		err = q.RevokeInvitationByID(ctx, PGInvitationId)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound("invitation_not_found", "invitation not found")
+			}
+			return internalError("failed to delete invitation", err)
+		}
+
 		w.WriteHeader(http.StatusNoContent)
 		return nil
 	})
-	if err != nil {
-		log.Println("transaction error:", err)
-	}
+	return err
 }
 
-func (s *Server) GetInvitationByID(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetInvitationByID(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	invitationId, err := uuid.Parse(chi.URLParam(r, "invitation_id"))
 	PGInvitationId := pgtype.UUID{Bytes: invitationId, Valid: true}
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid invitation ID")
-		return
+		return badRequest("invalid_invitation_id", "invalid invitation ID")
 	}
 	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
 		invitation, err := q.GetInvitationByID(ctx, PGInvitationId)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				writeError(w, http.StatusNotFound, "invitation not found")
-				return nil
+				return notFound("invitation_not_found", "invitation not found")
 			}
-			writeError(w, http.StatusInternalServerError, "failed to retrieve invitation")
-			log.Println("failed to retrieve invitation:", err)
-			return err
+			return internalError("failed to retrieve invitation", err)
 		}
 
 		list, err := q.GetListByID(ctx, invitation.InvitedToListID)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to retrieve list")
-			log.Println("failed to retrieve list:", err)
+			return internalError("failed to retrieve list", err)
 		}
 
 		invitedByUser, err := q.GetUserByID(ctx, invitation.CreatedBy)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to retrieve user")
-			log.Println("failed to retrieve user:", err)
+			return internalError("failed to retrieve user", err)
 		}
 
 		revokedAt := invitation.RevokedAt.Time.Format("2006-01-02T15:04:05Z07:00")
@@ -287,4 +258,60 @@ func (s *Server) GetInvitationByID(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusOK, response)
 		return nil
 	})
+	return err
+}
+
+type AcceptInvitationRequest struct {
+	// PlaceholderID optionally claims one of the list's placeholder members,
+	// moving its payments and deposits over to the accepting user.
+	PlaceholderID *uuid.UUID `json:"placeholder_id,omitempty"`
+}
+
+func (s *Server) AcceptInvitation(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	hash := chi.URLParam(r, "hash")
+	if hash == "" {
+		return badRequest("invalid_invitation_hash", "invalid invitation hash")
+	}
+
+	// The body is optional: an empty one just joins the list.
+	var req AcceptInvitationRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	placeholderID := pgtype.UUID{Valid: false}
+	if req.PlaceholderID != nil {
+		placeholderID = pgtype.UUID{Bytes: *req.PlaceholderID, Valid: true}
+	}
+
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		listID, err := q.AcceptInvitation(ctx, db.AcceptInvitationParams{
+			Hash:          hash,
+			PlaceholderID: placeholderID,
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				switch pgErr.Message {
+				case "invitation_not_found":
+					return notFound("invitation_not_found", "invitation not found")
+				case "invitation_not_usable":
+					return gone("invitation_not_usable", "invitation expired, revoked or already used")
+				case "placeholder_not_claimable":
+					return conflict("placeholder_cannot_be_claimed", "placeholder cannot be claimed")
+				}
+			}
+			return internalError("failed to accept invitation", err)
+		}
+
+		list, err := q.GetListByID(ctx, listID)
+		if err != nil {
+			return internalError("failed to retrieve list", err)
+		}
+
+		writeJSON(w, http.StatusOK, newListResponse(list))
+		return nil
+	})
+	return err
 }
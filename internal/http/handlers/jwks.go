@@ -0,0 +1,12 @@
+package handlers
+
+import "net/http"
+
+// GetJWKS publishes the public halves of the signing keys so other services
+// can verify our access tokens without sharing a secret. The same keys sign
+// other tokens too: verifiers must also check the "debt-manager-api" audience.
+func (s *Server) GetJWKS(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, s.Keys.JWKS())
+	return nil
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"debt-manager/internal/contextkeys"
+	"debt-manager/internal/db"
+	"debt-manager/internal/i18n"
+	"debt-manager/internal/mail"
+	"net/http"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Localize settles the language of the response from the Accept-Language
+// header. When the header names none of the supported languages, Auth falls
+// back to the user's own language, and anonymous requests get English.
+func Localize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Language")
+		if lang, ok := i18n.Negotiate(r.Header.Get("Accept-Language")); ok {
+			r = r.WithContext(context.WithValue(r.Context(), contextkeys.Language{}, lang))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func requestLanguage(ctx context.Context) string {
+	if lang, ok := ctx.Value(contextkeys.Language{}).(string); ok {
+		return lang
+	}
+	return i18n.English
+}
+
+// withUserLanguage answers in the user's own language a request that did not
+// ask for one it could get.
+func withUserLanguage(ctx context.Context, q *db.Queries, userID pgtype.UUID) context.Context {
+	if _, ok := ctx.Value(contextkeys.Language{}).(string); ok {
+		return ctx
+	}
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil || !user.Language.Valid {
+		return ctx
+	}
+	return context.WithValue(ctx, contextkeys.Language{}, user.Language.String)
+}
+
+// mailLanguage is the language of mail to user: their own, or else the one
+// of the request that sends it.
+func mailLanguage(ctx context.Context, user db.AppUsersSafe) string {
+	if user.Language.Valid {
+		return user.Language.String
+	}
+	return requestLanguage(ctx)
+}
+
+// localizedMail renders the named mail of the i18n catalogs in lang.
+func localizedMail(lang, to, name string, params ...string) mail.Message {
+	subject, body := i18n.Mail(lang, name, params...)
+	return mail.Message{To: to, Subject: subject, Body: body}
+}
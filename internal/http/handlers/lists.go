@@ -1,12 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"debt-manager/internal/contextkeys"
 	"debt-manager/internal/db"
 	"errors"
-	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -14,8 +15,8 @@ import (
 )
 
 type CreateListRequest struct {
-	Title    string
-	Currency string
+	Title    string `json:"title" validate:"required,notblank,max=100"`
+	Currency string `json:"currency" validate:"required,currency"`
 }
 
 type Currency string
@@ -33,6 +34,27 @@ type ListResponse struct {
 	Title    string    `json:"title"`
 	Currency string    `json:"currency"`
 	CreatedAt string    `json:"created_at"`
+	Version  int32     `json:"version"`
+	ClosedAt *string   `json:"closed_at,omitempty"`
+}
+
+func newListResponse(list db.List) ListResponse {
+	resp := ListResponse{
+		ID:        list.ID.Bytes,
+		Title:     list.Title,
+		Currency:  string(list.Currency),
+		CreatedAt: list.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+		Version:   list.Version,
+	}
+	if list.ClosedAt.Valid {
+		closedAt := list.ClosedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+		resp.ClosedAt = &closedAt
+	}
+	return resp
+}
+
+func listETag(list db.List) string {
+	return resourceETag(list.ID.Bytes, list.Version)
 }
 
 func (c Currency) Valid() bool {
@@ -44,21 +66,10 @@ func (c Currency) Valid() bool {
 	}
 }
 
-func (s *Server) CreateList(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateList(w http.ResponseWriter, r *http.Request) error {
 	var req CreateListRequest
-	if err := decodeJSON(r, &req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
-		return
-	}
-
-	if req.Title == "" {
-		writeError(w, http.StatusBadRequest, "title cannot be empty")
-		return
-	}
-
-	if !Currency(req.Currency).Valid() {
-		writeError(w, http.StatusBadRequest, "currency not valid")
-		return
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
 	}
 
 	ctx := r.Context()
@@ -70,9 +81,7 @@ func (s *Server) CreateList(w http.ResponseWriter, r *http.Request) {
 			Currency: db.Currency(req.Currency),
 		})
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to create list")
-			log.Println("failed to create list:", err)
-			return err
+			return internalError("failed to create list", err)
 		}
 
 		var userID = ctx.Value(contextkeys.UserID{}).(uuid.UUID)
@@ -80,130 +89,104 @@ func (s *Server) CreateList(w http.ResponseWriter, r *http.Request) {
 		_, err = q.CreateUserListRelation(r.Context(), db.CreateUserListRelationParams{
 			UserID: pgtype.UUID{Bytes: userID, Valid: true},
 			ListID: newListID,
+			Role:   db.ListRoleOwner,
 		})
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to create user-list relation")
-			log.Println("failed to create user-list relation:", err)
-			return err
+			return internalError("failed to create user-list relation", err)
 		}
 
 		list, err := q.GetListByID(r.Context(), newListID)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to retrieve created list")
-			log.Println("failed to retrieve created list:", err)
-			return err
+			return internalError("failed to retrieve created list", err)
 		}
 
-		writeJSON(w, http.StatusOK, ListResponse{
-			ID:        list.ID.Bytes,
-			Title:     list.Title,
-			Currency:  string(list.Currency),
-			CreatedAt: list.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
-		})
+		w.Header().Set("ETag", listETag(list))
+		writeJSON(w, http.StatusOK, newListResponse(list))
 		return nil
 	})
 
-	if err != nil {
-		log.Println("transaction failed:", err)
-		return
-	}
+	return err
 
 }
 
-func (s *Server) GetLists(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetLists(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
+	// Closed lists are archived and only listed on request.
+	includeArchived, _ := strconv.ParseBool(r.URL.Query().Get("archived"))
+
 	var lists []db.List
 	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
 		var err error
-		lists, err = q.GetAllLists(ctx)
+		lists, err = q.GetAllLists(ctx, includeArchived)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to retrieve lists")
-			log.Println("failed to retrieve lists:", err)
-			return err
+			return internalError("failed to retrieve lists", err)
 		}
 		return nil
 	})
-
 	if err != nil {
-		log.Println("transaction failed:", err)
-		return
+		return err
 	}
 
 	responses := make([]ListResponse, len(lists))
+	tags := make([]string, len(lists))
 	for i, list := range lists {
-		responses[i] = ListResponse{
-			ID:        list.ID.Bytes,
-			Title:     list.Title,
-			Currency:  string(list.Currency),
-			CreatedAt: list.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
-		}
+		responses[i] = newListResponse(list)
+		tags[i] = listETag(list)
 	}
 
+	if notModified(w, r, collectionETag(tags)) {
+		return nil
+	}
 	writeJSON(w, http.StatusOK, responses)
+	return nil
 }
 
-func (s *Server) GetListByID(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetListByID(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	id, err := uuid.Parse(chi.URLParam(r, "list_id"))
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid list ID")
-		return
+		return badRequest("invalid_list_id", "invalid list ID")
 	}
 	PGID := pgtype.UUID{Bytes: id, Valid: true}
 	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
 		list, err := q.GetListByID(ctx, PGID)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				writeError(w, http.StatusNotFound, "list not found")
-				return nil
+				return notFound("list_not_found", "list not found")
 			}
-			writeError(w, http.StatusInternalServerError, "failed to retrieve list")
-			log.Println("failed to retrieve list:", err)
-			return err
+			return internalError("failed to retrieve list", err)
 		}
 
-		writeJSON(w, http.StatusOK, ListResponse{
-			ID:        list.ID.Bytes,
-			Title:     list.Title,
-			Currency:  string(list.Currency),
-			CreatedAt: list.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
-		})
+		if notModified(w, r, listETag(list)) {
+			return nil
+		}
+		writeJSON(w, http.StatusOK, newListResponse(list))
 		return nil
 	})
-	if err != nil {
-		log.Println("transaction failed:", err)
-		return
-	}
+	return err
 }
 
 type UpdateListRequest struct {
-	Title 	 *string   `json:"title,omitempty"`
-	Currency *Currency `json:"currency,omitempty"`
+	Title 	 *string   `json:"title,omitempty" validate:"omitnil,notblank,max=100"`
+	Currency *Currency `json:"currency,omitempty" validate:"omitnil,currency"`
 }
 
-func (s *Server) UpdateList(w http.ResponseWriter, r *http.Request) {
+func (s *Server) UpdateList(w http.ResponseWriter, r *http.Request) error {
 
 	ctx := r.Context()
 	id, err := uuid.Parse(chi.URLParam(r, "list_id"))
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid list ID")
-		return
-	}
-
-	var req UpdateListRequest
-	if err := decodeJSON(r, &req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
-		return
+		return badRequest("invalid_list_id", "invalid list ID")
 	}
 
-	if req.Title != nil && *req.Title == "" {
-		writeError(w, http.StatusBadRequest, "title cannot be empty")
-		return
+	expectedVersion, err := ifMatchVersion(r, id)
+	if err != nil {
+		return preconditionFailed("list_has_been_modified", "list has been modified")
 	}
 
-	if req.Currency != nil && !req.Currency.Valid() {
-		writeError(w, http.StatusBadRequest, "currency not valid")
-		return
+	var req UpdateListRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
 	}
 
 	var title pgtype.Text
@@ -222,64 +205,89 @@ func (s *Server) UpdateList(w http.ResponseWriter, r *http.Request) {
 
 	PGID := pgtype.UUID{Bytes: id, Valid: true}
 	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
-		err := q.UpdateList(ctx, db.UpdateListParams{
-			ID:       PGID,
-			Title:  	title,
-			Currency: currency,
+		if _, err := requireListRole(ctx, q, PGID, db.ListRoleAdmin); err != nil {
+			return err
+		}
+		if err := requireOpenList(ctx, q, PGID); err != nil {
+			return err
+		}
+
+		list, err := q.UpdateList(ctx, db.UpdateListParams{
+			ID:              PGID,
+			Title:           title,
+			Currency:        currency,
+			ExpectedVersion: expectedVersion,
 		})
 
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				writeError(w, http.StatusNotFound, "list not found")
-				return nil
+				return listMissOrConflict(ctx, q, PGID)
 			}
-			writeError(w, http.StatusInternalServerError, "failed to retrieve list")
-			log.Println("failed to retrieve list:", err)
-			return err
-		}
-
-		list, err := q.GetListByID(ctx, PGID)
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, "failed to retrieve list")
-			log.Println("failed to retrieve list:", err)
-			return err
+			return internalError("failed to update list", err)
 		}
 
-		writeJSON(w, http.StatusOK, ListResponse{
-			ID:        list.ID.Bytes,
-			Title:     list.Title,
-			Currency:  string(list.Currency),
-			CreatedAt: list.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
-		})
+		w.Header().Set("ETag", listETag(list))
+		writeJSON(w, http.StatusOK, newListResponse(list))
 		return nil
 	})
+	return err
 }
 
-func (s *Server) DeleteList(w http.ResponseWriter, r *http.Request) {
+// listMissOrConflict is used when a conditional write on a list matched no
+// row: the list either does not exist (404) or its version moved on (412).
+func listMissOrConflict(ctx context.Context, q *db.Queries, id pgtype.UUID) error {
+	if _, err := q.GetListByID(ctx, id); err != nil {
+		return notFound("list_not_found", "list not found")
+	}
+	return preconditionFailed("list_has_been_modified", "list has been modified")
+}
+
+// requireOpenList fails with a 409 problem when the list has been closed.
+// Closed lists are read-only apart from settlement deposits.
+func requireOpenList(ctx context.Context, q *db.Queries, listID pgtype.UUID) error {
+	list, err := q.GetListByID(ctx, listID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return notFound("list_not_found", "list not found")
+		}
+		return internalError("failed to retrieve list", err)
+	}
+	if list.ClosedAt.Valid {
+		return conflict("list_closed", "list is closed")
+	}
+	return nil
+}
+
+func (s *Server) DeleteList(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	id, err := uuid.Parse(chi.URLParam(r, "list_id"))
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid list ID")
-		return
+		return badRequest("invalid_list_id", "invalid list ID")
+	}
+	expectedVersion, err := ifMatchVersion(r, id)
+	if err != nil {
+		return preconditionFailed("list_has_been_modified", "list has been modified")
 	}
+
 	PGID := pgtype.UUID{Bytes: id, Valid: true}
 	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
-		_, err := q.DeleteList(ctx, PGID)
+		if _, err := requireListRole(ctx, q, PGID, db.ListRoleOwner); err != nil {
+			return err
+		}
+
+		_, err := q.DeleteList(ctx, db.DeleteListParams{
+			ID:              PGID,
+			ExpectedVersion: expectedVersion,
+		})
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
-				writeError(w, http.StatusNotFound, "list not found")
-				return nil
+				return listMissOrConflict(ctx, q, PGID)
 			}
-			writeError(w, http.StatusInternalServerError, "failed to delete list")
-			log.Println("failed to delete list:", err)
-			return err
+			return internalError("failed to delete list", err)
 		}
 
 		w.WriteHeader(http.StatusNoContent)
 		return nil
 	})
-	if err != nil {
-		log.Println("transaction failed:", err)
-		return
-	}
+	return err
 }
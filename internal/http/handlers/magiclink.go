@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"debt-manager/internal/db"
+	"debt-manager/internal/mail"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// A magic link logs in whoever opens it, so besides being short-lived and
+// single-use it only works in the browser that asked for it: that browser
+// gets a nonce cookie, and the link is bound to the nonce's hash.
+const (
+	magicLinkTTL    = 15 * time.Minute
+	magicLinkCookie = "magic_link_nonce"
+	magicLinkPath   = "/auth/magic-link"
+	// magicLinkPage is the frontend page that logs in with POST
+	// /auth/magic-link/verify, from the browser holding the nonce cookie.
+	magicLinkPage = "/magic-link"
+)
+
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required"`
+}
+
+func (req *MagicLinkRequest) normalize() {
+	req.Email = strings.TrimSpace(req.Email)
+}
+
+type VerifyMagicLinkRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// RequestMagicLink mails a login link to the account behind the given email.
+// Like ForgotPassword it answers 202 whether or not such an account exists,
+// and the browser gets a nonce cookie either way.
+func (s *Server) RequestMagicLink(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	var req MagicLinkRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	nonce, nonceHash, err := createHashedToken()
+	if err != nil {
+		return internalError("failed to create login link", err)
+	}
+	setCookie(w, magicLinkCookie, nonce, magicLinkTTL, magicLinkPath)
+
+	if !s.allowAccount(ctx, accountKey("magic", req.Email), accountMailLimit) {
+		log.Println("magic link not sent: rate limited")
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+
+	// As in ForgotPassword, the lookup and the mail happen after the answer.
+	w.WriteHeader(http.StatusAccepted)
+	go s.sendMagicLink(context.WithoutCancel(ctx), req.Email, nonceHash)
+	return nil
+}
+
+// sendMagicLink mails a login link bound to the nonce to the account with the
+// email, if there is one. The mail goes out once the token is committed.
+func (s *Server) sendMagicLink(ctx context.Context, email string, nonceHash []byte) {
+	var msg mail.Message
+	err := s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		user, err := q.GetUserByEmail(ctx, email)
+		if err != nil {
+			return err
+		}
+
+		raw, hash, err := createHashedToken()
+		if err != nil {
+			return err
+		}
+
+		err = q.CreateMagicLinkToken(ctx, db.CreateMagicLinkTokenParams{
+			UserID:    user.ID,
+			Email:     user.Email,
+			TokenHash: hash,
+			NonceHash: nonceHash,
+			ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(magicLinkTTL), Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		link := s.accountLink(magicLinkPage, raw)
+		msg = localizedMail(mailLanguage(ctx, user), user.Email, "magic_link", user.Username, link)
+		return nil
+	})
+	if err == nil {
+		err = s.Mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		log.Println("magic link not sent:", err)
+	}
+}
+
+// VerifyMagicLink exchanges a login link for a session. Users with two-factor
+// authentication still get their challenge; the link only stands in for the
+// password.
+func (s *Server) VerifyMagicLink(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	var req VerifyMagicLinkRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	nonce, err := r.Cookie(magicLinkCookie)
+	if err != nil || nonce.Value == "" {
+		return forbidden("magic_link_wrong_browser", "open the link in the browser you requested it from")
+	}
+
+	tokenHash := sha256.Sum256([]byte(req.Token))
+	nonceHash := sha256.Sum256([]byte(nonce.Value))
+	var alert *mail.Message
+	err = s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		userID, err := q.ConsumeMagicLink(ctx, db.ConsumeMagicLinkParams{
+			TokenHash: tokenHash[:],
+			NonceHash: nonceHash[:],
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				switch pgErr.Message {
+				case "magic_link_not_found":
+					return notFound("invalid_token", "invalid token")
+				case "magic_link_not_usable":
+					return gone("token_not_usable", "token expired or already used")
+				case "magic_link_wrong_browser":
+					return forbidden("magic_link_wrong_browser", "open the link in the browser you requested it from")
+				}
+			}
+			return internalError("failed to log in", err)
+		}
+
+		user, err := q.GetUserByID(ctx, userID)
+		if err != nil {
+			return internalError("failed to retrieve user", err)
+		}
+
+		clearCookie(w, magicLinkCookie, magicLinkPath)
+		alert, err = s.continueLogin(user, w, r, q)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.sendLoginAlert(ctx, alert)
+	return nil
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"debt-manager/internal/contextkeys"
+	"debt-manager/internal/db"
+	"debt-manager/internal/mail"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	emailChangeTTL = 24 * time.Hour
+	// confirmEmailPage is the frontend page that confirms an email change
+	// with POST /me/email/confirm.
+	confirmEmailPage = "/account/email/confirm"
+)
+
+type MeResponse struct {
+	ID                uuid.UUID `json:"id"`
+	Username          string    `json:"username"`
+	Email             string    `json:"email"`
+	EmailVerifiedAt   *string   `json:"email_verified_at,omitempty"`
+	DisplayName       *string   `json:"display_name,omitempty"`
+	Language          *string   `json:"language,omitempty"`
+	CreatedAt         string    `json:"created_at"`
+	PasswordChangedAt string    `json:"password_changed_at"`
+	LastLoginAt       *string   `json:"last_login_at,omitempty"`
+}
+
+func newMeResponse(user db.AppUsersSafe) MeResponse {
+	resp := MeResponse{
+		ID:                user.ID.Bytes,
+		Username:          user.Username,
+		Email:             user.Email,
+		CreatedAt:         user.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+		PasswordChangedAt: user.PasswordChangedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if user.DisplayName.Valid {
+		resp.DisplayName = &user.DisplayName.String
+	}
+	if user.Language.Valid {
+		resp.Language = &user.Language.String
+	}
+	if user.EmailVerifiedAt.Valid {
+		emailVerifiedAt := user.EmailVerifiedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+		resp.EmailVerifiedAt = &emailVerifiedAt
+	}
+	if user.LastLoginAt.Valid {
+		lastLoginAt := user.LastLoginAt.Time.Format("2006-01-02T15:04:05Z07:00")
+		resp.LastLoginAt = &lastLoginAt
+	}
+	return resp
+}
+
+type UpdateMeRequest struct {
+	Username *string `json:"username,omitempty" validate:"omitnil,notblank,max=50,safechars"`
+	// An empty display name clears it.
+	DisplayName *string `json:"display_name,omitempty" validate:"omitnil,max=100"`
+	// Language is the language of mail, and of responses to requests that
+	// do not ask for one. An empty language follows the request.
+	Language *string `json:"language,omitempty" validate:"omitnil,language"`
+}
+
+func (req *UpdateMeRequest) normalize() {
+	if req.DisplayName != nil {
+		*req.DisplayName = strings.TrimSpace(*req.DisplayName)
+	}
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8,max=128,safechars"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+func (req *ChangeEmailRequest) normalize() {
+	req.NewEmail = strings.TrimSpace(req.NewEmail)
+}
+
+type ConfirmEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// accountLink builds a link to a page of the frontend that carries a one-time
+// token for it to POST to the API.
+func (s *Server) accountLink(page, token string) string {
+	link := *s.AppURL
+	link.Path = strings.TrimSuffix(link.Path, "/") + page
+	link.RawQuery = url.Values{"token": {token}}.Encode()
+	return link.String()
+}
+
+// checkPassword verifies the caller's password; used to re-authenticate before
+// sensitive account changes.
+func checkPassword(r *http.Request, q *db.Queries, user db.AppUsersSafe, password string) (bool, error) {
+	secrets, err := q.GetLoginSecretsByEmail(r.Context(), user.Email)
+	if err != nil {
+		return false, err
+	}
+	return VerifyPassword(password, secrets.PasswordHash.String)
+}
+
+func (s *Server) GetMe(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		user, err := q.GetUserByID(ctx, pgtype.UUID{Bytes: userID, Valid: true})
+		if err != nil {
+			return internalError("failed to retrieve user", err)
+		}
+
+		writeJSON(w, http.StatusOK, newMeResponse(user))
+		return nil
+	})
+	return err
+}
+
+func (s *Server) UpdateMe(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+
+	var req UpdateMeRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	username := pgtype.Text{Valid: false}
+	if req.Username != nil {
+		username = pgtype.Text{String: *req.Username, Valid: true}
+	}
+
+	displayName := pgtype.Text{Valid: false}
+	if req.DisplayName != nil {
+		displayName = pgtype.Text{String: *req.DisplayName, Valid: true}
+	}
+
+	language := pgtype.Text{Valid: false}
+	if req.Language != nil {
+		language = pgtype.Text{String: *req.Language, Valid: true}
+	}
+
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		err := q.UpdateProfile(ctx, db.UpdateProfileParams{
+			Username:    username,
+			DisplayName: displayName,
+			Language:    language,
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Message == "username_taken" {
+				return conflict("username_already_taken", "username already taken")
+			}
+			return internalError("failed to update profile", err)
+		}
+
+		user, err := q.GetUserByID(ctx, pgtype.UUID{Bytes: userID, Valid: true})
+		if err != nil {
+			return internalError("failed to retrieve user", err)
+		}
+
+		writeJSON(w, http.StatusOK, newMeResponse(user))
+		return nil
+	})
+	return err
+}
+
+func (s *Server) ChangePassword(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+	sessionID, err := uuid.Parse(ctx.Value(contextkeys.SessionID{}).(string))
+	if err != nil {
+		return unauthorized("invalid_access_token", "unauthorized")
+	}
+
+	var req ChangePasswordRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	pgUserID := pgtype.UUID{Bytes: userID, Valid: true}
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		user, err := q.GetUserByID(ctx, pgUserID)
+		if err != nil {
+			return internalError("failed to retrieve user", err)
+		}
+
+		ok, err := checkPassword(r, q, user, req.CurrentPassword)
+		if err != nil || !ok {
+			return forbidden("wrong_password", "current password is incorrect")
+		}
+
+		hash, err := HashPassword(req.NewPassword, s.Argon2)
+		if err != nil {
+			return internalError("failed to hash password", err)
+		}
+
+		err = q.UpdateUserPassword(ctx, db.UpdateUserPasswordParams{
+			UserID:          pgUserID,
+			NewPasswordHash: hash,
+			NewPasswordAlgo: PasswordAlgo,
+		})
+		if err != nil {
+			return internalError("failed to update password", err)
+		}
+
+		// Everyone else who knew the old password is signed out, and the
+		// tokens they could have made with it stop working; the session that
+		// made the change stays.
+		err = q.RevokeOtherSessions(ctx, db.RevokeOtherSessionsParams{
+			UserID: pgUserID,
+			ID:     pgtype.UUID{Bytes: sessionID, Valid: true},
+		})
+		if err != nil {
+			return internalError("failed to revoke sessions", err)
+		}
+		if err := q.RevokeAllPersonalAccessTokens(ctx, pgUserID); err != nil {
+			return internalError("failed to revoke access tokens", err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	return err
+}
+
+func (s *Server) ChangeEmail(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+
+	var req ChangeEmailRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	pgUserID := pgtype.UUID{Bytes: userID, Valid: true}
+	// Both mails go out once the request is committed, so the link cannot
+	// arrive before its token exists.
+	var confirm, notice mail.Message
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		user, err := q.GetUserByID(ctx, pgUserID)
+		if err != nil {
+			return internalError("failed to retrieve user", err)
+		}
+
+		ok, err := checkPassword(r, q, user, req.Password)
+		if err != nil || !ok {
+			return forbidden("wrong_password", "password is incorrect")
+		}
+
+		if strings.EqualFold(user.Email, req.NewEmail) {
+			return badRequest("email_unchanged", "new email is the same as the current one")
+		}
+
+		// The confirmation would fail on the address anyway; no point in
+		// mailing a link that cannot work.
+		_, err = q.GetUserByEmail(ctx, req.NewEmail)
+		if err == nil {
+			return conflict("email_already_registered", "email already registered")
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return internalError("failed to check email", err)
+		}
+
+		raw, hash, err := createHashedToken()
+		if err != nil {
+			return internalError("failed to create token", err)
+		}
+
+		_, err = q.CreateEmailChangeRequest(ctx, db.CreateEmailChangeRequestParams{
+			UserID:    pgUserID,
+			NewEmail:  req.NewEmail,
+			TokenHash: hash,
+			ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(emailChangeTTL), Valid: true},
+		})
+		if err != nil {
+			return internalError("failed to create email change request", err)
+		}
+
+		link := s.accountLink(confirmEmailPage, raw)
+
+		lang := mailLanguage(ctx, user)
+		confirm = localizedMail(lang, req.NewEmail, "confirm_email_change", user.Username, link)
+		// Let the current address know, in case this was not the owner.
+		notice = localizedMail(lang, user.Email, "email_change_notice", user.Username, req.NewEmail)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.Mailer.Send(ctx, confirm); err != nil {
+		return internalError("failed to send confirmation email", err)
+	}
+	if err := s.Mailer.Send(ctx, notice); err != nil {
+		log.Println("failed to send email change notice:", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
+
+func (s *Server) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+
+	var req ConfirmEmailRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256([]byte(req.Token))
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		_, err := q.ConfirmEmailChange(ctx, hash[:])
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				switch pgErr.Message {
+				case "email_change_not_found":
+					return notFound("invalid_token", "invalid token")
+				case "email_change_not_usable":
+					return gone("token_not_usable", "token expired or already used")
+				case "email_already_registered":
+					return conflict("email_already_registered", "email already registered")
+				}
+			}
+			return internalError("failed to change email", err)
+		}
+
+		user, err := q.GetUserByID(ctx, pgtype.UUID{Bytes: userID, Valid: true})
+		if err != nil {
+			return internalError("failed to retrieve user", err)
+		}
+
+		writeJSON(w, http.StatusOK, newMeResponse(user))
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"debt-manager/internal/contextkeys"
+	"debt-manager/internal/db"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=viewer member admin owner"`
+}
+
+type TransferOwnershipRequest struct {
+	UserID uuid.UUID `json:"user_id" validate:"required"`
+}
+
+type MemberResponse struct {
+	UserID uuid.UUID `json:"user_id"`
+	ListID uuid.UUID `json:"list_id"`
+	Role   string    `json:"role"`
+}
+
+func newMemberResponse(m db.UsersList) MemberResponse {
+	return MemberResponse{
+		UserID: m.UserID.Bytes,
+		ListID: m.ListID.Bytes,
+		Role:   string(m.Role),
+	}
+}
+
+func (s *Server) UpdateMemberRole(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	listID, err := uuid.Parse(chi.URLParam(r, "list_id"))
+	if err != nil {
+		return badRequest("invalid_list_id", "invalid list ID")
+	}
+	userID, err := uuid.Parse(chi.URLParam(r, "user_id"))
+	if err != nil {
+		return badRequest("invalid_user_id", "invalid user ID")
+	}
+
+	var req UpdateMemberRoleRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	role := db.ListRole(req.Role)
+	if role == db.ListRoleOwner {
+		return badRequest("use_ownership_transfer", "use the ownership transfer to make someone owner")
+	}
+
+	pgListID := pgtype.UUID{Bytes: listID, Valid: true}
+	pgUserID := pgtype.UUID{Bytes: userID, Valid: true}
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		if _, err := requireListRole(ctx, q, pgListID, db.ListRoleAdmin); err != nil {
+			return err
+		}
+
+		member, err := q.GetListMember(ctx, db.GetListMemberParams{
+			ListID: pgListID,
+			UserID: pgUserID,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound("member_not_found", "member not found")
+			}
+			return internalError("failed to retrieve member", err)
+		}
+		if member.Role == db.ListRoleOwner {
+			return forbidden("use_ownership_transfer", "the owner's role only changes through an ownership transfer")
+		}
+
+		member, err = q.UpdateMemberRole(ctx, db.UpdateMemberRoleParams{
+			ListID: pgListID,
+			UserID: pgUserID,
+			Role:   role,
+		})
+		if err != nil {
+			return internalError("failed to update member role", err)
+		}
+
+		writeJSON(w, http.StatusOK, newMemberResponse(member))
+		return nil
+	})
+	return err
+}
+
+func (s *Server) TransferListOwnership(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	listID, err := uuid.Parse(chi.URLParam(r, "list_id"))
+	if err != nil {
+		return badRequest("invalid_list_id", "invalid list ID")
+	}
+
+	var req TransferOwnershipRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	pgListID := pgtype.UUID{Bytes: listID, Valid: true}
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		if _, err := requireListRole(ctx, q, pgListID, db.ListRoleOwner); err != nil {
+			return err
+		}
+
+		err := q.TransferListOwnership(ctx, db.TransferListOwnershipParams{
+			ListID:   pgListID,
+			NewOwner: pgtype.UUID{Bytes: req.UserID, Valid: true},
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Message == "member_not_found" {
+				return notFound("member_not_found", "member not found")
+			}
+			return internalError("failed to transfer ownership", err)
+		}
+
+		members, err := q.GetUsersFromList(ctx, pgListID)
+		if err != nil {
+			return internalError("failed to fetch users from list", err)
+		}
+
+		resp := make([]MemberResponse, 0, len(members))
+		for _, m := range members {
+			if m.LeftAt.Valid {
+				continue
+			}
+			resp = append(resp, MemberResponse{
+				UserID: m.ID.Bytes,
+				ListID: listID,
+				Role:   string(m.Role),
+			})
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return nil
+	})
+	return err
+}
+
+// outstandingBalance is what a member is still owed (positive) or owes
+// (negative) in a list. Balances are rounded to cents already.
+func outstandingBalance(ctx context.Context, q *db.Queries, listID pgtype.UUID, userID uuid.UUID) (float64, error) {
+	balances, err := getBalancesByListID(q, ctx, listID)
+	if err != nil {
+		return 0, err
+	}
+	return balances[userID], nil
+}
+
+// forceRequested reports whether ?force=true was passed to skip the
+// outstanding-balance safeguard.
+func forceRequested(r *http.Request) bool {
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+	return force
+}
+
+// checkSettled refuses (409) to let a member go while they still owe or are
+// owed money, unless the caller forces it.
+func checkSettled(r *http.Request, q *db.Queries, listID pgtype.UUID, userID uuid.UUID) error {
+	if forceRequested(r) {
+		return nil
+	}
+
+	balance, err := outstandingBalance(r.Context(), q, listID, userID)
+	if err != nil {
+		return internalError("failed to fetch net balances", err)
+	}
+	if math.Abs(balance) >= 0.005 {
+		return conflict("outstanding_balance", "member has an outstanding balance of {0}; settle up first or pass force=true").with(fmt.Sprintf("%.2f", balance))
+	}
+	return nil
+}
+
+func (s *Server) LeaveList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	listID, err := uuid.Parse(chi.URLParam(r, "list_id"))
+	if err != nil {
+		return badRequest("invalid_list_id", "invalid list ID")
+	}
+	pgListID := pgtype.UUID{Bytes: listID, Valid: true}
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		if _, err := requireListRole(ctx, q, pgListID, db.ListRoleViewer); err != nil {
+			return err
+		}
+		if err := checkSettled(r, q, pgListID, userID); err != nil {
+			return err
+		}
+
+		if err := q.LeaveList(ctx, pgListID); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Message == "owner_cannot_leave" {
+				return conflict("owner_cannot_leave", "the owner has to transfer ownership before leaving")
+			}
+			return internalError("failed to leave list", err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	return err
+}
+
+func (s *Server) RemoveListMember(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	listID, err := uuid.Parse(chi.URLParam(r, "list_id"))
+	if err != nil {
+		return badRequest("invalid_list_id", "invalid list ID")
+	}
+	userID, err := uuid.Parse(chi.URLParam(r, "user_id"))
+	if err != nil {
+		return badRequest("invalid_user_id", "invalid user ID")
+	}
+	pgListID := pgtype.UUID{Bytes: listID, Valid: true}
+
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		if _, err := requireListRole(ctx, q, pgListID, db.ListRoleAdmin); err != nil {
+			return err
+		}
+		if err := checkSettled(r, q, pgListID, userID); err != nil {
+			return err
+		}
+
+		err := q.RemoveListMember(ctx, db.RemoveListMemberParams{
+			ListID: pgListID,
+			UserID: pgtype.UUID{Bytes: userID, Valid: true},
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				switch pgErr.Message {
+				case "member_not_found":
+					return notFound("member_not_found", "member not found")
+				case "owner_cannot_leave":
+					return forbidden("owner_cannot_be_removed", "the owner cannot be removed from the list")
+				}
+			}
+			return internalError("failed to remove member", err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	return err
+}
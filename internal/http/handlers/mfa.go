@@ -0,0 +1,396 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"debt-manager/internal/contextkeys"
+	"debt-manager/internal/db"
+	"debt-manager/internal/mail"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	totpIssuer        = "Debt Manager"
+	totpPeriod        = 30
+	recoveryCodeCount = 10
+	mfaChallengeTTL   = 5 * time.Minute
+	// Audience of the challenge token handed out by Login. Auth rejects it,
+	// it is only good for POST /auth/mfa.
+	mfaAudience = "mfa"
+)
+
+var totpOpts = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+type EnrollTOTPRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+type EnrollTOTPResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRPNG      string `json:"qr_png"`
+}
+
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type DisableTOTPRequest struct {
+	Password     string `json:"password" validate:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MFARequest struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// totpStep returns the time step a code belongs to if it is valid at now,
+// allowing one step of clock drift either way.
+func totpStep(secret, code string, now time.Time) (int64, bool) {
+	for _, drift := range []int64{0, -1, 1} {
+		t := now.Add(time.Duration(drift*totpPeriod) * time.Second)
+		expected, err := totp.GenerateCodeCustom(secret, t, totpOpts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return t.Unix() / totpPeriod, true
+		}
+	}
+	return 0, false
+}
+
+// normalizeRecoveryCode makes codes typed by hand match the stored hash.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// createRecoveryCodes replaces the user's recovery codes with a new set and
+// returns them; they cannot be shown again.
+func createRecoveryCodes(ctx context.Context, q *db.Queries, userID pgtype.UUID) ([]string, error) {
+	if err := q.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(enc.EncodeToString(b))[:10]
+		hash := sha256.Sum256([]byte(raw))
+		err := q.CreateRecoveryCode(ctx, db.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: hash[:],
+		})
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+	}
+	return codes, nil
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused recovery
+// code. Both are spent on success.
+func checkSecondFactor(ctx context.Context, q *db.Queries, mfa db.AppUserMfa, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := totpStep(mfa.TotpSecret, strings.TrimSpace(code), time.Now())
+		if !ok {
+			return false, nil
+		}
+		rows, err := q.UseMFAStep(ctx, db.UseMFAStepParams{
+			UserID:       mfa.UserID,
+			LastUsedStep: pgtype.Int8{Int64: step, Valid: true},
+		})
+		return rows == 1, err
+	}
+
+	if recoveryCode != "" {
+		hash := sha256.Sum256([]byte(normalizeRecoveryCode(recoveryCode)))
+		rows, err := q.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{
+			UserID:   mfa.UserID,
+			CodeHash: hash[:],
+		})
+		return rows == 1, err
+	}
+
+	return false, nil
+}
+
+// startMFAChallenge answers a login with a short-lived token instead of a
+// session. The token proves the password was right and is redeemed together
+// with a second factor at POST /auth/mfa.
+func (s *Server) startMFAChallenge(user db.AppUsersSafe, w http.ResponseWriter) error {
+	signed, err := s.makeAccessToken(&Claims{
+		UserID: user.ID.String(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{mfaAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "debt-manager",
+			Subject:   fmt.Sprint(user.ID),
+		},
+	})
+	if err != nil {
+		return internalError("failed to create mfa token", err)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"mfa_required": true,
+		"mfa_token":    signed,
+		"expires_in":   int(mfaChallengeTTL.Seconds()),
+	})
+	return nil
+}
+
+func (s *Server) EnrollTOTP(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+	pgUserID := pgtype.UUID{Bytes: userID, Valid: true}
+
+	var req EnrollTOTPRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		user, err := q.GetUserByID(ctx, pgUserID)
+		if err != nil {
+			return internalError("failed to retrieve user", err)
+		}
+
+		ok, err := checkPassword(r, q, user, req.Password)
+		if err != nil || !ok {
+			return forbidden("wrong_password", "password is incorrect")
+		}
+
+		key, err := totp.Generate(totp.GenerateOpts{
+			Issuer:      totpIssuer,
+			AccountName: user.Email,
+			Period:      totpPeriod,
+			Digits:      totpOpts.Digits,
+			Algorithm:   totpOpts.Algorithm,
+		})
+		if err != nil {
+			return internalError("failed to generate secret", err)
+		}
+
+		_, err = q.StartMFAEnrollment(ctx, db.StartMFAEnrollmentParams{
+			UserID:     pgUserID,
+			TotpSecret: key.Secret(),
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return conflict("mfa_already_enabled", "two-factor authentication already enabled")
+			}
+			return internalError("failed to start enrollment", err)
+		}
+
+		img, err := key.Image(256, 256)
+		if err != nil {
+			return internalError("failed to render QR code", err)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return internalError("failed to render QR code", err)
+		}
+
+		writeJSON(w, http.StatusOK, EnrollTOTPResponse{
+			Secret:     key.Secret(),
+			OTPAuthURI: key.URL(),
+			QRPNG:      "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+		})
+		return nil
+	})
+	return err
+}
+
+func (s *Server) ConfirmTOTP(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+	pgUserID := pgtype.UUID{Bytes: userID, Valid: true}
+
+	var req ConfirmTOTPRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		mfa, err := q.GetUserMFA(ctx, pgUserID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound("mfa_enrollment_not_found", "no two-factor enrollment in progress")
+			}
+			return internalError("failed to retrieve enrollment", err)
+		}
+		if mfa.ConfirmedAt.Valid {
+			return conflict("mfa_already_enabled", "two-factor authentication already enabled")
+		}
+
+		step, ok := totpStep(mfa.TotpSecret, strings.TrimSpace(req.Code), time.Now())
+		if !ok {
+			return badRequest("invalid_code", "invalid code")
+		}
+
+		_, err = q.ConfirmMFAEnrollment(ctx, db.ConfirmMFAEnrollmentParams{
+			UserID:       pgUserID,
+			LastUsedStep: pgtype.Int8{Int64: step, Valid: true},
+		})
+		if err != nil {
+			return internalError("failed to enable two-factor authentication", err)
+		}
+
+		codes, err := createRecoveryCodes(ctx, q, pgUserID)
+		if err != nil {
+			return internalError("failed to create recovery codes", err)
+		}
+
+		writeJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+		return nil
+	})
+	return err
+}
+
+func (s *Server) DisableTOTP(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+	pgUserID := pgtype.UUID{Bytes: userID, Valid: true}
+
+	var req DisableTOTPRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		user, err := q.GetUserByID(ctx, pgUserID)
+		if err != nil {
+			return internalError("failed to retrieve user", err)
+		}
+
+		ok, err := checkPassword(r, q, user, req.Password)
+		if err != nil || !ok {
+			return forbidden("wrong_password", "password is incorrect")
+		}
+
+		mfa, err := q.GetUserMFA(ctx, pgUserID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound("mfa_not_enabled", "two-factor authentication is not enabled")
+			}
+			return internalError("failed to retrieve two-factor settings", err)
+		}
+
+		// An unconfirmed enrollment can be dropped with the password alone.
+		if mfa.ConfirmedAt.Valid {
+			ok, err := checkSecondFactor(ctx, q, mfa, req.Code, req.RecoveryCode)
+			if err != nil {
+				return internalError("failed to check code", err)
+			}
+			if !ok {
+				return forbidden("invalid_code", "invalid code")
+			}
+		}
+
+		if err := q.DeleteUserMFA(ctx, pgUserID); err != nil {
+			return internalError("failed to disable two-factor authentication", err)
+		}
+		if err := q.DeleteRecoveryCodes(ctx, pgUserID); err != nil {
+			return internalError("failed to disable two-factor authentication", err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	return err
+}
+
+func (s *Server) CompleteMFA(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	var req MFARequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	token, err := s.Keys.Parse(req.MFAToken, &Claims{})
+	if err != nil || !token.Valid {
+		return unauthorized("invalid_mfa_token", "invalid or expired mfa token")
+	}
+	claims := token.Claims.(*Claims)
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil || !claims.VerifyAudience(mfaAudience, true) {
+		return unauthorized("invalid_mfa_token", "invalid or expired mfa token")
+	}
+	pgUserID := pgtype.UUID{Bytes: userID, Valid: true}
+
+	key := "mfa:account:" + userID.String()
+	if err := s.throttleAccount(ctx, key, accountMFALimit); err != nil {
+		return err
+	}
+
+	var alert *mail.Message
+	err = s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		mfa, err := q.GetUserMFA(ctx, pgUserID)
+		if err != nil || !mfa.ConfirmedAt.Valid {
+			return unauthorized("invalid_mfa_token", "invalid or expired mfa token")
+		}
+
+		ok, err := checkSecondFactor(ctx, q, mfa, req.Code, req.RecoveryCode)
+		if err != nil {
+			return internalError("failed to check code", err)
+		}
+		if !ok {
+			s.failAccount(ctx, key)
+			s.recordFailedLogin(r, pgUserID)
+			return unauthorized("invalid_code", "invalid code")
+		}
+		s.succeedAccount(ctx, key)
+
+		user, err := q.GetUserByID(ctx, pgUserID)
+		if err != nil {
+			return internalError("failed to retrieve user", err)
+		}
+
+		alert, err = s.completeLogin(user, w, r, q)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.sendLoginAlert(ctx, alert)
+	return nil
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"crypto/sha256"
 	"debt-manager/internal/contextkeys"
 	"debt-manager/internal/db"
 	"errors"
@@ -11,7 +12,7 @@ import (
 	"strings"
 	"time"
 
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
@@ -38,46 +39,179 @@ func (s *Server) Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tokenStr, err := readAccessTokenFromCookieOrHeader(r)
 		if err != nil {
-			writeError(w, http.StatusUnauthorized, "missing or invalid access token")
+			writeProblem(w, r, unauthorized("invalid_access_token", "missing or invalid access token"))
 			return
 		}
 
-		token, err := jwt.ParseWithClaims(
-			tokenStr,
-			&Claims{},
-			func(token *jwt.Token) (interface{}, error) {
-				return s.HS256PrivateKey, nil
-			},
-			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
-		)
+		// A token from the cookie was sent by the browser on its own, maybe
+		// for another site; only the frontend can also send the CSRF header.
+		if r.Header.Get("Authorization") == "" && !verifyCSRF(r) {
+			writeProblem(w, r, forbidden("invalid_csrf_token", "invalid csrf token"))
+			return
+		}
+
+		if strings.HasPrefix(tokenStr, patPrefix) {
+			s.authenticateToken(w, r, next, tokenStr)
+			return
+		}
+
+		token, err := s.Keys.Parse(tokenStr, &Claims{})
 		if err != nil || !token.Valid {
 			log.Println("Error parsing token or invalid token:", err)
-			writeError(w, http.StatusUnauthorized, "unauthorized")
+			writeProblem(w, r, unauthorized("invalid_access_token", "unauthorized"))
 			return
 		}
 
 		claims := token.Claims.(*Claims)
 
-		s.Tx.WithTx(r.Context(), func(q *db.Queries) error {
-			session, err := q.GetSessionByID(
-				r.Context(),
-				pgtype.UUID{Bytes: uuid.MustParse(claims.SessionID), Valid: true},
-			)
+		// MFA challenge and OIDC state tokens are signed with the same keys;
+		// only the audience tells access tokens apart.
+		sessionID, err := uuid.Parse(claims.SessionID)
+		if err != nil || !claims.VerifyAudience(accessAudience, true) {
+			writeProblem(w, r, unauthorized("invalid_access_token", "unauthorized"))
+			return
+		}
 
-			if err != nil || session.RevokedAt.Valid || time.Now().After(session.ExpiresAt.Time) {
-				log.Println("Error getting session or invalid session:", err)
-				writeError(w, http.StatusUnauthorized, "unauthorized")
+		ctx := r.Context()
+		var session db.AppSession
+		err = s.Tx.WithTx(ctx, func(q *db.Queries) error {
+			var err error
+			session, err = q.GetSessionByID(ctx, pgtype.UUID{Bytes: sessionID, Valid: true})
+			if err != nil {
 				return err
 			}
-
-			ctx := context.WithValue(r.Context(), contextkeys.UserID{}, uuid.MustParse(claims.UserID))
-			ctx = context.WithValue(ctx, contextkeys.SessionID{}, claims.SessionID)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			if session.RevokedAt.Valid || time.Now().After(session.ExpiresAt.Time) {
+				return errors.New("session revoked or expired")
+			}
+			if err := q.TouchSession(ctx, session.ID); err != nil {
+				log.Println("failed to update session last use:", err)
+			}
+			ctx = withUserLanguage(ctx, q, session.UserID)
 			return nil
 		})
+		if err != nil {
+			log.Println("Error getting session or invalid session:", err)
+			writeProblem(w, r, unauthorized("invalid_access_token", "unauthorized"))
+			return
+		}
+
+		// The session row stays unlocked while the request runs, so handlers
+		// such as Logout can update it from their own transaction.
+		ctx = context.WithValue(ctx, contextkeys.UserID{}, uuid.MustParse(claims.UserID))
+		ctx = context.WithValue(ctx, contextkeys.SessionID{}, claims.SessionID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticateToken is the personal access token half of Auth. Token requests
+// carry a TokenGrant instead of a session ID.
+func (s *Server) authenticateToken(w http.ResponseWriter, r *http.Request, next http.Handler, tokenStr string) {
+	hash := sha256.Sum256([]byte(tokenStr))
+
+	ctx := r.Context()
+	var token db.AppPersonalAccessToken
+	err := s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		var err error
+		token, err = q.GetPersonalAccessTokenByHash(r.Context(), hash[:])
+		if err != nil {
+			return err
+		}
+		if token.RevokedAt.Valid || (token.ExpiresAt.Valid && time.Now().After(token.ExpiresAt.Time)) {
+			return errors.New("token revoked or expired")
+		}
+		if err := q.TouchPersonalAccessToken(r.Context(), token.ID); err != nil {
+			log.Println("failed to update token last use:", err)
+		}
+		ctx = withUserLanguage(ctx, q, token.UserID)
+		return nil
+	})
+	if err != nil {
+		log.Println("Error getting token or invalid token:", err)
+		writeProblem(w, r, unauthorized("invalid_access_token", "unauthorized"))
+		return
+	}
+
+	grant := TokenGrant{TokenID: token.ID.Bytes, Scopes: token.Scopes}
+	for _, id := range token.ListIds {
+		grant.ListIDs = append(grant.ListIDs, id.Bytes)
+	}
+
+	ctx = context.WithValue(ctx, contextkeys.UserID{}, uuid.UUID(token.UserID.Bytes))
+	ctx = context.WithValue(ctx, contextkeys.TokenGrant{}, grant)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequireSession keeps personal access tokens out of routes that manage the
+// account itself, including the tokens.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(contextkeys.TokenGrant{}).(TokenGrant); ok {
+			writeProblem(w, r, forbidden("session_required", "not available to access tokens"))
+			return
+		}
+		next.ServeHTTP(w, r)
 	})
 }
 
+// RequireScope checks that a personal access token has the scope, and for
+// tokens restricted to some lists, that the route's list is one of them.
+// Routes without a list are off limits to restricted tokens. Session requests
+// pass unchanged.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			grant, ok := r.Context().Value(contextkeys.TokenGrant{}).(TokenGrant)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !grant.hasScope(scope) {
+				writeProblem(w, r, forbidden("insufficient_scope", "token lacks scope {0}").with(scope))
+				return
+			}
+			if !grant.allowsList(chi.URLParam(r, "list_id")) {
+				writeProblem(w, r, forbidden("token_list_forbidden", "token is not valid for this list"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireVerifiedEmail rejects users without a verified email address when the
+// server's EmailPolicy holds back the given action. Must run after Auth.
+func (s *Server) RequireVerifiedEmail(action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !s.EmailPolicy[action] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			userID := r.Context().Value(contextkeys.UserID{}).(uuid.UUID)
+			var verified bool
+			err := s.Tx.WithTx(r.Context(), func(q *db.Queries) error {
+				user, err := q.GetUserByID(r.Context(), pgtype.UUID{Bytes: userID, Valid: true})
+				if err != nil {
+					return err
+				}
+				verified = user.EmailVerifiedAt.Valid
+				return nil
+			})
+			if err != nil {
+				writeProblem(w, r, internalError("failed to retrieve user", err))
+				return
+			}
+			if !verified {
+				writeProblem(w, r, forbidden("email_not_verified", "email address not verified"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 type statusResponseWriter struct {
 	http.ResponseWriter
 	status int
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"debt-manager/internal/db"
+	"debt-manager/internal/mail"
+	"debt-manager/internal/oidc"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStatePath   = "/auth/oidc"
+	oidcStateTTL    = 10 * time.Minute
+	oidcAudience    = "oidc"
+)
+
+// oidcStateClaims travel in a signed cookie between the redirect to the
+// provider and the callback, so no server-side state is needed for a login
+// in progress.
+type oidcStateClaims struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+// oidcUsername picks a username for a new account from what the provider
+// told us, dropping characters usernames may not contain.
+func oidcUsername(ident oidc.Identity) string {
+	candidates := []string{ident.PreferredUsername, ident.Name}
+	if at := strings.Index(ident.Email, "@"); at > 0 {
+		candidates = append(candidates, ident.Email[:at])
+	}
+	for _, c := range candidates {
+		name := strings.Map(func(r rune) rune {
+			if containsRestrictedChars(string(r)) {
+				return -1
+			}
+			return r
+		}, c)
+		if name != "" {
+			return name
+		}
+	}
+	return "user"
+}
+
+func (s *Server) GetOIDCProviders(w http.ResponseWriter, r *http.Request) error {
+	names := make([]string, 0, len(s.OIDCProviders))
+	for name := range s.OIDCProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	writeJSON(w, http.StatusOK, map[string]any{"providers": names})
+	return nil
+}
+
+func (s *Server) StartOIDCLogin(w http.ResponseWriter, r *http.Request) error {
+	provider, ok := s.OIDCProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		return notFound("provider_not_found", "unknown provider")
+	}
+
+	state, _, err := createHashedToken()
+	if err != nil {
+		return internalError("failed to start login", err)
+	}
+	nonce, _, err := createHashedToken()
+	if err != nil {
+		return internalError("failed to start login", err)
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	cookie, err := s.Keys.Sign(oidcStateClaims{
+		Provider: provider.Name(),
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{oidcAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(oidcStateTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "debt-manager",
+		},
+	})
+	if err != nil {
+		return internalError("failed to start login", err)
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		return badGateway("idp_unavailable", "identity provider unavailable")
+	}
+
+	setCookie(w, oidcStateCookie, cookie, oidcStateTTL, oidcStatePath)
+	http.Redirect(w, r, authURL, http.StatusFound)
+	return nil
+}
+
+func (s *Server) OIDCCallback(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	provider, ok := s.OIDCProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		return notFound("provider_not_found", "unknown provider")
+	}
+
+	if e := r.URL.Query().Get("error"); e != "" {
+		return unauthorized("idp_error", "identity provider returned an error: {0}").with(e)
+	}
+
+	c, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		return badRequest("missing_login_state", "missing login state")
+	}
+	clearCookie(w, oidcStateCookie, oidcStatePath)
+
+	token, err := s.Keys.Parse(c.Value, &oidcStateClaims{})
+	if err != nil || !token.Valid {
+		return badRequest("invalid_login_state", "invalid or expired login state")
+	}
+	claims := token.Claims.(*oidcStateClaims)
+	state := r.URL.Query().Get("state")
+	if !claims.VerifyAudience(oidcAudience, true) ||
+		claims.Provider != provider.Name() ||
+		subtle.ConstantTimeCompare([]byte(claims.State), []byte(state)) != 1 {
+		return badRequest("invalid_login_state", "invalid or expired login state")
+	}
+
+	ident, err := provider.Exchange(ctx, r.URL.Query().Get("code"), claims.Nonce, claims.Verifier)
+	if err != nil {
+		return unauthorized("idp_login_failed", "sign in with identity provider failed")
+	}
+
+	// Accounts registered with an unverified address get a verification mail
+	// once the login committed.
+	var (
+		unverifiedUserID pgtype.UUID
+		alert            *mail.Message
+	)
+	err = s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		var email pgtype.Text
+		if ident.Email != "" {
+			email = pgtype.Text{String: ident.Email, Valid: true}
+		}
+
+		identity, err := q.GetUserIdentity(ctx, db.GetUserIdentityParams{
+			Provider: provider.Name(),
+			Subject:  ident.Subject,
+		})
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return internalError("failed to retrieve identity", err)
+		}
+
+		var userID pgtype.UUID
+		switch {
+		case err == nil:
+			if err := q.TouchUserIdentity(ctx, db.TouchUserIdentityParams{ID: identity.ID, Email: email}); err != nil {
+				return internalError("failed to update identity", err)
+			}
+			userID = identity.UserID
+
+		case ident.Email == "":
+			return badRequest("idp_email_missing", "identity provider did not share an email address")
+
+		default:
+			userID, err = s.linkOrRegisterOIDCUser(r, q, provider.Name(), ident)
+			if err != nil {
+				return err
+			}
+			if !ident.EmailVerified {
+				unverifiedUserID = userID
+			}
+		}
+
+		user, err := q.GetUserByID(ctx, userID)
+		if err != nil {
+			return internalError("failed to retrieve user", err)
+		}
+
+		alert, err = s.continueLogin(user, w, r, q)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.sendLoginAlert(ctx, alert)
+	if unverifiedUserID.Valid {
+		s.mailEmailVerification(ctx, unverifiedUserID)
+	}
+	return nil
+}
+
+// linkOrRegisterOIDCUser attaches a first-time identity to the account with
+// the same email, or creates a new account. Linking needs both sides to have
+// verified the address; otherwise whoever registered an address they do not
+// own could take over the real owner's provider login, or the other way round.
+func (s *Server) linkOrRegisterOIDCUser(r *http.Request, q *db.Queries, provider string, ident oidc.Identity) (pgtype.UUID, error) {
+	ctx := r.Context()
+
+	existing, err := q.GetUserByEmail(ctx, ident.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return pgtype.UUID{}, internalError("failed to retrieve user", err)
+	}
+
+	if err == nil {
+		if !ident.EmailVerified || !existing.EmailVerifiedAt.Valid {
+			return pgtype.UUID{}, conflict("email_already_registered", "an account with this email already exists")
+		}
+		_, err := q.CreateUserIdentity(ctx, db.CreateUserIdentityParams{
+			UserID:   existing.ID,
+			Provider: provider,
+			Subject:  ident.Subject,
+			Email:    pgtype.Text{String: ident.Email, Valid: true},
+		})
+		if err != nil {
+			return pgtype.UUID{}, internalError("failed to link identity", err)
+		}
+		return existing.ID, nil
+	}
+
+	userID, err := q.RegisterOIDCUser(ctx, db.RegisterOIDCUserParams{
+		Email:         ident.Email,
+		Username:      oidcUsername(ident),
+		EmailVerified: ident.EmailVerified,
+		Provider:      provider,
+		Subject:       ident.Subject,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Message == "email_already_registered" {
+			return pgtype.UUID{}, conflict("email_already_registered", "an account with this email already exists")
+		}
+		return pgtype.UUID{}, internalError("failed to create user", err)
+	}
+	return userID, nil
+}
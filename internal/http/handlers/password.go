@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"debt-manager/internal/db"
+	"debt-manager/internal/mail"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	passwordResetTTL = time.Hour
+	// resetPasswordPage is the frontend page that asks for the new password
+	// and sends it with POST /auth/password/reset.
+	resetPasswordPage = "/reset-password"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required"`
+}
+
+func (req *ForgotPasswordRequest) normalize() {
+	req.Email = strings.TrimSpace(req.Email)
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8,max=128,safechars"`
+}
+
+// ForgotPassword mails a reset link to the account behind the given email.
+// It answers 202 whether or not such an account exists, so it cannot be used
+// to find out who is registered.
+func (s *Server) ForgotPassword(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	var req ForgotPasswordRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	// Over the limit the request is answered like any other, so the limit
+	// cannot be used to find out whether an address is registered.
+	if !s.allowAccount(ctx, accountKey("forgot", req.Email), accountMailLimit) {
+		log.Println("password reset not sent: rate limited")
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+
+	// The lookup and the mail happen after the answer, so neither can the
+	// response time tell whether the address is registered.
+	w.WriteHeader(http.StatusAccepted)
+	go s.sendPasswordReset(context.WithoutCancel(ctx), req.Email)
+	return nil
+}
+
+// sendPasswordReset mails a reset link to the account with the email, if
+// there is one. The mail goes out once the token is committed.
+func (s *Server) sendPasswordReset(ctx context.Context, email string) {
+	var msg mail.Message
+	err := s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		user, err := q.GetUserByEmail(ctx, email)
+		if err != nil {
+			return err
+		}
+
+		raw, hash, err := createHashedToken()
+		if err != nil {
+			return err
+		}
+
+		err = q.CreatePasswordResetToken(ctx, db.CreatePasswordResetTokenParams{
+			UserID:    user.ID,
+			TokenHash: hash,
+			ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(passwordResetTTL), Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		link := s.accountLink(resetPasswordPage, raw)
+		msg = localizedMail(mailLanguage(ctx, user), user.Email, "reset_password", user.Username, link)
+		return nil
+	})
+	if err == nil {
+		err = s.Mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		log.Println("password reset not sent:", err)
+	}
+}
+
+func (s *Server) ResetPassword(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	var req ResetPasswordRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	hash, err := HashPassword(req.NewPassword, s.Argon2)
+	if err != nil {
+		return internalError("failed to hash password", err)
+	}
+
+	tokenHash := sha256.Sum256([]byte(req.Token))
+	err = s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		userID, err := q.ResetPassword(ctx, db.ResetPasswordParams{
+			TokenHash:    tokenHash[:],
+			PasswordHash: hash,
+			PasswordAlgo: PasswordAlgo,
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				switch pgErr.Message {
+				case "password_reset_not_found":
+					return notFound("invalid_token", "invalid token")
+				case "password_reset_not_usable":
+					return gone("token_not_usable", "token expired or already used")
+				}
+			}
+			return internalError("failed to reset password", err)
+		}
+
+		// Whoever got hold of the old password loses access along with every
+		// other device, and with any token they made with it.
+		if err := q.RevokeAllUserSessions(ctx, userID); err != nil {
+			return internalError("failed to revoke sessions", err)
+		}
+		if err := q.RevokeAllPersonalAccessTokens(ctx, userID); err != nil {
+			return internalError("failed to revoke access tokens", err)
+		}
+
+		clearAuthCookies(w)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	return err
+}
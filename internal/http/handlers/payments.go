@@ -1,27 +1,27 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
+	"debt-manager/internal/contextkeys"
 	"debt-manager/internal/db"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"math"
 	"math/big"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-playground/validator"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// DivisionRequest names who owes a share of a payment: either a user
+// (owe_user_id) or a placeholder member of the list (owe_placeholder_id).
 type DivisionRequest struct {
-	OweUserID uuid.UUID `json:"owe_user_id"`
-	Amount    float64   `json:"amount"`
+	OweUserID        *uuid.UUID `json:"owe_user_id,omitempty" validate:"required_without=OwePlaceholderID,excluded_with=OwePlaceholderID"`
+	OwePlaceholderID *uuid.UUID `json:"owe_placeholder_id,omitempty"`
+	Amount           float64    `json:"amount" validate:"gt=0,money"`
 }
 
 type DivisionResponse struct {
@@ -31,22 +31,25 @@ type DivisionResponse struct {
 }
 
 type PaymentRequest struct {
-	Title       string            `json:"title"`
-	Amount      float64           `json:"amount"`
-	PhotoURL    *string           `json:"photo_url"`
-	PayerUserID uuid.UUID         `json:"payer_user_id"`
-	Divisions   []DivisionRequest `json:"divisions"`
+	Title              string            `json:"title" validate:"max=200"`
+	Amount             float64           `json:"amount" validate:"gt=0,money"`
+	PhotoURL           *string           `json:"photo_url" validate:"omitnil,url,max=2048"`
+	PayerUserID        *uuid.UUID        `json:"payer_user_id,omitempty" validate:"required_without=PayerPlaceholderID,excluded_with=PayerPlaceholderID"`
+	PayerPlaceholderID *uuid.UUID        `json:"payer_placeholder_id,omitempty"`
+	Divisions          []DivisionRequest `json:"divisions" validate:"required,min=1,dive"`
 }
 
 type PaymentResponse struct {
-	ID          uuid.UUID         `json:"id"`
-	Title       string            `json:"title"`
-	Amount      float64           `json:"amount"`
-	PhotoURL    *string           `json:"photo_url,omitempty"`
-	PayerUserID uuid.UUID         `json:"payer_user_id"`
-	Divisions   []DivisionRequest `json:"divisions"`
-	CreatedAt   string            `json:"created_at"`
-	ListID      uuid.UUID         `json:"list_id"`
+	ID                 uuid.UUID         `json:"id"`
+	Title              string            `json:"title"`
+	Amount             float64           `json:"amount"`
+	PhotoURL           *string           `json:"photo_url,omitempty"`
+	PayerUserID        *uuid.UUID        `json:"payer_user_id,omitempty"`
+	PayerPlaceholderID *uuid.UUID        `json:"payer_placeholder_id,omitempty"`
+	Divisions          []DivisionRequest `json:"divisions"`
+	CreatedAt          string            `json:"created_at"`
+	ListID             uuid.UUID         `json:"list_id"`
+	Version            int32             `json:"version"`
 }
 
 type TransactionResponse struct {
@@ -56,54 +59,45 @@ type TransactionResponse struct {
 }
 
 type DepositRequest struct {
-	Amount      float64   `json:"amount"`
-	PayerUserID uuid.UUID `json:"from"`
-	PayeeUserID uuid.UUID `json:"to"`
+	Amount            float64    `json:"amount" validate:"gt=0,money"`
+	PayerUserID       *uuid.UUID `json:"from,omitempty" validate:"required_without=FromPlaceholderID,excluded_with=FromPlaceholderID"`
+	PayeeUserID       *uuid.UUID `json:"to,omitempty" validate:"required_without=ToPlaceholderID,excluded_with=ToPlaceholderID"`
+	FromPlaceholderID *uuid.UUID `json:"from_placeholder_id,omitempty"`
+	ToPlaceholderID   *uuid.UUID `json:"to_placeholder_id,omitempty"`
+	// IsSettlement marks a deposit that pays off a closed list's settlement.
+	IsSettlement bool `json:"is_settlement,omitempty"`
 }
 
-func parseJSONStrict(r io.ReadCloser, dst any) error {
-	defer r.Close()
-
-	b, err := io.ReadAll(r)
-	if err != nil {
-		return fmt.Errorf("read body: %w", err)
-	}
-	if len(bytes.TrimSpace(b)) == 0 {
-		return fmt.Errorf("empty body")
-	}
-
-	dec := json.NewDecoder(bytes.NewReader(b))
-	dec.DisallowUnknownFields()
-	dec.UseNumber()
-
-	if err := dec.Decode(dst); err != nil {
-		return fmt.Errorf("invalid json: %w", err)
-	}
-
-	var extra json.RawMessage
-	if err := dec.Decode(&extra); err != io.EOF {
-		if err == nil {
-			return fmt.Errorf("multiple JSON values in body")
-		}
-		return fmt.Errorf("trailing data: %w", err)
-	}
-
-	return nil
+type DepositResponse struct {
+	ID                uuid.UUID  `json:"id"`
+	From              *uuid.UUID `json:"from,omitempty"`
+	To                *uuid.UUID `json:"to,omitempty"`
+	FromPlaceholderID *uuid.UUID `json:"from_placeholder_id,omitempty"`
+	ToPlaceholderID   *uuid.UUID `json:"to_placeholder_id,omitempty"`
+	Amount            float64    `json:"amount"`
+	CreatedAt         string     `json:"created_at"`
+	ListID            uuid.UUID  `json:"list_id"`
+	Version           int32      `json:"version"`
+	IsSettlement      bool       `json:"is_settlement,omitempty"`
 }
 
-
-var validate = validator.New()
-
-func parseJSON(r io.ReadCloser, dst any) error {
-	defer r.Close()
-
-	if err := parseJSONStrict(r, dst); err != nil {
-		return err
-	}
-	if err := validate.Struct(dst); err != nil {
-		return fmt.Errorf("validation: %w", err)
+func newDepositResponse(d db.Deposit) (DepositResponse, error) {
+	amount, err := floatFromNumeric(d.Amount)
+	if err != nil {
+		return DepositResponse{}, err
 	}
-	return nil
+	return DepositResponse{
+		ID:                d.ID.Bytes,
+		From:              uuidPtr(d.PayerUserID),
+		To:                uuidPtr(d.PayeeUserID),
+		FromPlaceholderID: uuidPtr(d.PayerPlaceholderID),
+		ToPlaceholderID:   uuidPtr(d.PayeePlaceholderID),
+		Amount:            amount,
+		CreatedAt:         d.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+		ListID:            d.ListID.Bytes,
+		Version:           d.Version,
+		IsSettlement:      d.IsSettlement,
+	}, nil
 }
 
 func numericFromFloat(f float64, precission int) pgtype.Numeric {
@@ -124,24 +118,33 @@ func floatFromNumeric(n pgtype.Numeric) (float64, error) {
 	return floatVal.Float64, nil
 }
 
-func (s *Server) CreatePayment(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreatePayment(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	listIDStr := chi.URLParam(r, "list_id")
 	listID, err := uuid.Parse(listIDStr)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid list ID")
-		return
+		return badRequest("invalid_list_id", "invalid list ID")
 	}
 	listPgID := pgtype.UUID{Bytes: listID, Valid: true}
 
 	var req PaymentRequest
-	if err := parseJSON(r.Body, &req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
-		return
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
 	}
+	payerPgID, payerPlaceholderID := participant(req.PayerUserID, req.PayerPlaceholderID)
 
 	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
-		payerPgID := pgtype.UUID{Bytes: req.PayerUserID, Valid: true}
+		if _, err := requireListRole(ctx, q, listPgID, db.ListRoleMember); err != nil {
+			return err
+		}
+		if err := requireOpenList(ctx, q, listPgID); err != nil {
+			return err
+		}
+
+		if err := checkPlaceholder(ctx, q, listPgID, payerPlaceholderID); err != nil {
+			return invalid(fieldError("payer_placeholder_id", "placeholder_not_in_list", "payer placeholder does not belong to this list"))
+		}
+
 		var photoURL pgtype.Text
 		if req.PhotoURL != nil {
 			photoURL = pgtype.Text{String: *req.PhotoURL, Valid: true}
@@ -150,74 +153,78 @@ func (s *Server) CreatePayment(w http.ResponseWriter, r *http.Request) {
 		}
 
 		payment, err := q.CreatePayment(ctx, db.CreatePaymentParams{
-			Title:       pgtype.Text{String: req.Title, Valid: true},
-			PayerUserID: payerPgID,
-			Amount:      numericFromFloat(req.Amount, 2),
-			PhotoUrl:    photoURL,
-			ListID:      listPgID,
+			Title:              pgtype.Text{String: req.Title, Valid: true},
+			PayerUserID:        payerPgID,
+			PayerPlaceholderID: payerPlaceholderID,
+			Amount:             numericFromFloat(req.Amount, 2),
+			PhotoUrl:           photoURL,
+			ListID:             listPgID,
 		})
 
 		if err != nil {
-			log.Println("Error creating payment:", err)
-			writeError(w, http.StatusInternalServerError, "failed to create payment")
-			return err
+			return internalError("failed to create payment", err)
 		}
 
-		divisionsTotal := 0.0
-		for _, division := range req.Divisions {
-			divisionsTotal += division.Amount
-			owePgID := pgtype.UUID{Bytes: division.OweUserID, Valid: true}
+		// Amounts are summed in whole cents: float sums of decimal amounts
+		// are not exact.
+		var divisionsCents int64
+		for i, division := range req.Divisions {
+			divisionsCents += int64(math.Round(division.Amount * 100))
+			owePgID, owePlaceholderID := participant(division.OweUserID, division.OwePlaceholderID)
+			if err := checkPlaceholder(ctx, q, listPgID, owePlaceholderID); err != nil {
+				return invalid(fieldError(fmt.Sprintf("divisions[%d].owe_placeholder_id", i), "placeholder_not_in_list", "division placeholder does not belong to this list"))
+			}
 			_, err := q.CreateDivision(ctx, db.CreateDivisionParams{
-				PaymentID: payment.ID,
-				OweUserID: owePgID,
-				Amount:    numericFromFloat(division.Amount, 2),
+				PaymentID:        payment.ID,
+				OweUserID:        owePgID,
+				OwePlaceholderID: owePlaceholderID,
+				Amount:           numericFromFloat(division.Amount, 2),
 			})
 			if err != nil {
-				log.Println("Error creating division:", err)
-				writeError(w, http.StatusInternalServerError, "failed to create division")
-				return err
+				return internalError("failed to create division", err)
 			}
 		}
 
-		if req.Amount != divisionsTotal {
-			log.Println("Error: payment amount does not match divisions total")
-			writeError(w, http.StatusBadRequest, fmt.Sprintf("payment amount (%.2f) does not match divisions total (%.2f)", req.Amount, divisionsTotal))
-			return errors.New("payment amount does not match divisions total")
+		if int64(math.Round(req.Amount*100)) != divisionsCents {
+			return invalid(fieldError("amount", "divisions_total_mismatch", "payment amount ({0}) does not match divisions total ({1})",
+				fmt.Sprintf("%.2f", req.Amount), fmt.Sprintf("%.2f", float64(divisionsCents)/100)))
 		}
 
+		w.Header().Set("ETag", resourceETag(payment.ID.Bytes, payment.Version))
 		writeJSON(w, http.StatusCreated, PaymentResponse{
-			ID:          payment.ID.Bytes,
-			Title:       payment.Title.String,
-			Amount:      req.Amount,
-			PhotoURL:    req.PhotoURL,
-			PayerUserID: req.PayerUserID,
-			Divisions:   req.Divisions,
-			CreatedAt:   payment.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
-			ListID:      listID,
+			ID:                 payment.ID.Bytes,
+			Title:              payment.Title.String,
+			Amount:             req.Amount,
+			PhotoURL:           req.PhotoURL,
+			PayerUserID:        req.PayerUserID,
+			PayerPlaceholderID: req.PayerPlaceholderID,
+			Divisions:          req.Divisions,
+			CreatedAt:          payment.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+			ListID:             listID,
+			Version:            payment.Version,
 		})
 
 		return nil
 	})
+	return err
 }
 
-func (s *Server) GetAllPaymentsForList(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetAllPaymentsForList(w http.ResponseWriter, r *http.Request) error {
 	listIDStr := chi.URLParam(r, "list_id")
 	listID, err := uuid.Parse(listIDStr)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid list ID")
-		return
+		return badRequest("invalid_list_id", "invalid list ID")
 	}
 
 	pgListID := pgtype.UUID{Bytes: listID, Valid: true}
 	err = s.Tx.WithCtxUserTx(r.Context(), func(q *db.Queries) error {
 		payments, err := q.GetAllPaymentsForList(r.Context(), pgListID)
 		if err != nil {
-			log.Println("Error fetching payments:", err)
-			writeError(w, http.StatusInternalServerError, "failed to fetch payments")
-			return err
+			return internalError("failed to fetch payments", err)
 		}
 
 		var resp []PaymentResponse
+		var tags []string
 		for _, p := range payments {
 			var photoURL *string
 			if p.PhotoUrl.Valid {
@@ -226,73 +233,104 @@ func (s *Server) GetAllPaymentsForList(w http.ResponseWriter, r *http.Request) {
 
 			divisions, err := q.GetDivisionsByPaymentID(r.Context(), p.ID)
 			if err != nil {
-				log.Println("Error fetching divisions:", err)
-				writeError(w, http.StatusInternalServerError, "failed to fetch divisions")
-				return err
+				return internalError("failed to fetch divisions", err)
 			}
 
 			var divisionResponses []DivisionRequest
 			for _, d := range divisions {
 				amountFloat, err := floatFromNumeric(d.Amount)
 				if err != nil {
-					log.Println("Error converting amount:", err)
-					writeError(w, http.StatusInternalServerError, "failed to convert amount")
-					return err
+					return internalError("failed to convert amount", err)
 				}
 				divisionResponses = append(divisionResponses, DivisionRequest{
-					OweUserID: d.OweUserID.Bytes,
-					Amount:    amountFloat,
+					OweUserID:        uuidPtr(d.OweUserID),
+					OwePlaceholderID: uuidPtr(d.OwePlaceholderID),
+					Amount:           amountFloat,
 				})
 			}
 
 			amountFloat, err := floatFromNumeric(p.Amount)
 			if err != nil {
-				log.Println("Error converting amount:", err)
-				writeError(w, http.StatusInternalServerError, "failed to convert amount")
-				return err
+				return internalError("failed to convert amount", err)
 			}
 			resp = append(resp, PaymentResponse{
-				ID:          p.ID.Bytes,
-				Title:       p.Title.String,
-				Amount:      amountFloat,
-				PhotoURL:    photoURL,
-				PayerUserID: p.PayerUserID.Bytes,
-				Divisions:   divisionResponses,
-				CreatedAt:   p.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
-				ListID:      p.ListID.Bytes,
+				ID:                 p.ID.Bytes,
+				Title:              p.Title.String,
+				Amount:             amountFloat,
+				PhotoURL:           photoURL,
+				PayerUserID:        uuidPtr(p.PayerUserID),
+				PayerPlaceholderID: uuidPtr(p.PayerPlaceholderID),
+				Divisions:          divisionResponses,
+				CreatedAt:          p.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+				ListID:             p.ListID.Bytes,
+				Version:            p.Version,
 			})
+			tags = append(tags, resourceETag(p.ID.Bytes, p.Version))
 		}
 
+		if notModified(w, r, collectionETag(tags)) {
+			return nil
+		}
 		writeJSON(w, http.StatusOK, resp)
 
 		return nil
 	})
+	return err
 }
 
-func (s *Server) DeletePaymentByID(w http.ResponseWriter, r *http.Request) {
+func (s *Server) DeletePaymentByID(w http.ResponseWriter, r *http.Request) error {
+	listID, err := uuid.Parse(chi.URLParam(r, "list_id"))
+	if err != nil {
+		return badRequest("invalid_list_id", "invalid list ID")
+	}
 	paymentIDStr := chi.URLParam(r, "payment_id")
 	paymentID, err := uuid.Parse(paymentIDStr)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid payment ID")
-		return
+		return badRequest("invalid_payment_id", "invalid payment ID")
 	}
 	pgPaymentID := pgtype.UUID{Bytes: paymentID, Valid: true}
 
+	expectedVersion, err := ifMatchVersion(r, paymentID)
+	if err != nil {
+		return preconditionFailed("payment_has_been_modified", "payment has been modified")
+	}
+
 	err = s.Tx.WithCtxUserTx(r.Context(), func(q *db.Queries) error {
-		err := q.DeletePaymentByID(r.Context(), pgPaymentID)
+		// The route's list is the one a restricted token was checked for.
+		payment, err := q.GetPaymentByID(r.Context(), pgPaymentID)
+		if err != nil || payment.ListID.Bytes != listID {
+			return notFound("payment_not_found", "payment not found")
+		}
+		role, err := requireListRole(r.Context(), q, payment.ListID, db.ListRoleMember)
 		if err != nil {
-			log.Println("Error deleting payment:", err)
-			writeError(w, http.StatusInternalServerError, "failed to delete payment")
 			return err
 		}
+		if err := requireOpenList(r.Context(), q, payment.ListID); err != nil {
+			return err
+		}
+		userID := r.Context().Value(contextkeys.UserID{}).(uuid.UUID)
+		if !canWriteEntry(role, payment.CreatedBy, userID) {
+			return forbidden("admin_required", "only admins can delete payments entered by others")
+		}
+
+		affected, err := q.DeletePaymentByID(r.Context(), db.DeletePaymentByIDParams{
+			ID:              pgPaymentID,
+			ExpectedVersion: expectedVersion,
+		})
+		if err != nil {
+			return internalError("failed to delete payment", err)
+		}
+		if affected == 0 {
+			return preconditionFailed("payment_has_been_modified", "payment has been modified")
+		}
 		return nil
 	})
-
 	if err != nil {
-		return
+		return err
 	}
 
 	w.WriteHeader(http.StatusNoContent)
+	return nil
 }
 
 func getBalancesFromPayments(p []db.Payment, d []db.Division, dep []db.Deposit) (map[uuid.UUID]float64, error) {
@@ -303,8 +341,9 @@ func getBalancesFromPayments(p []db.Payment, d []db.Division, dep []db.Deposit)
 		if err != nil {
 			return nil, err
 		}
-		balances[payment.PayerUserID.Bytes] += paymentAmount
-		balances[payment.PayerUserID.Bytes] = math.Round(balances[payment.PayerUserID.Bytes]*100) / 100
+		payer := participantKey(payment.PayerUserID, payment.PayerPlaceholderID)
+		balances[payer] += paymentAmount
+		balances[payer] = math.Round(balances[payer]*100) / 100
 	}
 
 	for _, deposit := range dep {
@@ -312,10 +351,12 @@ func getBalancesFromPayments(p []db.Payment, d []db.Division, dep []db.Deposit)
 		if err != nil {
 			return nil, err
 		}
-		balances[deposit.PayeeUserID.Bytes] -= depositAmount
-		balances[deposit.PayerUserID.Bytes] += depositAmount
-		balances[deposit.PayeeUserID.Bytes] = math.Round(balances[deposit.PayeeUserID.Bytes]*100) / 100
-		balances[deposit.PayerUserID.Bytes] = math.Round(balances[deposit.PayerUserID.Bytes]*100) / 100
+		payer := participantKey(deposit.PayerUserID, deposit.PayerPlaceholderID)
+		payee := participantKey(deposit.PayeeUserID, deposit.PayeePlaceholderID)
+		balances[payee] -= depositAmount
+		balances[payer] += depositAmount
+		balances[payee] = math.Round(balances[payee]*100) / 100
+		balances[payer] = math.Round(balances[payer]*100) / 100
 	}
 
 	for _, division := range d {
@@ -323,8 +364,9 @@ func getBalancesFromPayments(p []db.Payment, d []db.Division, dep []db.Deposit)
 		if err != nil {
 			return nil, err
 		}
-		balances[division.OweUserID.Bytes] -= divisionAmount
-		balances[division.OweUserID.Bytes] = math.Round(balances[division.OweUserID.Bytes]*100) / 100
+		owe := participantKey(division.OweUserID, division.OwePlaceholderID)
+		balances[owe] -= divisionAmount
+		balances[owe] = math.Round(balances[owe]*100) / 100
 	}
 
 	return balances, nil
@@ -347,136 +389,255 @@ func getBalancesByListID(q *db.Queries, ctx context.Context, listID pgtype.UUID)
 	return getBalancesFromPayments(payments, divisions, deposits)
 }
 
-func (s *Server) GetNetBalances(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetNetBalances(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	listIDStr := chi.URLParam(r, "list_id")
 	listID, err := uuid.Parse(listIDStr)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid list ID")
-		return
+		return badRequest("invalid_list_id", "invalid list ID")
 	}
 	pgListID := pgtype.UUID{Bytes: listID, Valid: true}
 
 	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
 		balances, err := getBalancesByListID(q, ctx, pgListID)
 		if err != nil {
-			log.Println("Error fetching net balances:", err)
-			writeError(w, http.StatusInternalServerError, "failed to fetch net balances")
-			return err
+			return internalError("failed to fetch net balances", err)
 		}
 
 		writeJSON(w, http.StatusOK, balances)
 		return nil
 	})
+	return err
 }
 
-func (s *Server) GetSugestedTransactions(w http.ResponseWriter, r *http.Request) {
+// suggestTransactions turns net balances into the payments that settle
+// everybody up, greedily matching debtors with creditors.
+func suggestTransactions(balances map[uuid.UUID]float64) []TransactionResponse {
+	var creditors []struct {
+		UserID uuid.UUID
+		Amount float64
+	}
+	var debtors []struct {
+		UserID uuid.UUID
+		Amount float64
+	}
+
+	for userID, balance := range balances {
+		if balance > 0 {
+			creditors = append(creditors, struct {
+				UserID uuid.UUID
+				Amount float64
+			}{UserID: userID, Amount: balance})
+		} else if balance < 0 {
+			debtors = append(debtors, struct {
+				UserID uuid.UUID
+				Amount float64
+			}{UserID: userID, Amount: -balance})
+		}
+	}
+
+	var transactions []TransactionResponse
+	i, j := 0, 0
+	for i < len(debtors) && j < len(creditors) {
+		debtor := &debtors[i]
+		creditor := &creditors[j]
+
+		minAmount := math.Min(debtor.Amount, creditor.Amount)
+		if minAmount > 0 {
+			transactions = append(transactions, TransactionResponse{
+				From:   debtor.UserID,
+				To:     creditor.UserID,
+				Amount: math.Round(minAmount*100) / 100,
+			})
+
+			debtor.Amount -= minAmount
+			creditor.Amount -= minAmount
+		}
+
+		if debtor.Amount == 0 {
+			i++
+		}
+		if creditor.Amount == 0 {
+			j++
+		}
+	}
+	return transactions
+}
+
+func (s *Server) GetSugestedTransactions(w http.ResponseWriter, r *http.Request) error {
 	ctx := r.Context()
 	listIDStr := chi.URLParam(r, "list_id")
 	listID, err := uuid.Parse(listIDStr)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid list ID")
-		return
+		return badRequest("invalid_list_id", "invalid list ID")
 	}
 	pgListID := pgtype.UUID{Bytes: listID, Valid: true}
 
 	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
 		balances, err := getBalancesByListID(q, ctx, pgListID)
 		if err != nil {
-			log.Println("Error fetching net balances:", err)
-			writeError(w, http.StatusInternalServerError, "failed to fetch net balances")
-			return err
+			return internalError("failed to fetch net balances", err)
 		}
 
-		var creditors []struct {
-			UserID uuid.UUID
-			Amount float64
+		transactions := suggestTransactions(balances)
+		writeJSON(w, http.StatusOK, transactions)
+
+		return nil
+	})
+	return err
+}
+
+func (s *Server) CreateDeposit(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	listIDStr := chi.URLParam(r, "list_id")
+	listID, err := uuid.Parse(listIDStr)
+	if err != nil {
+		return badRequest("invalid_list_id", "invalid list ID")
+	}
+	pgListID := pgtype.UUID{Bytes: listID, Valid: true}
+
+	var req DepositRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+	log.Println("Deposit request:", req)
+
+	payer, payerPlaceholder := participant(req.PayerUserID, req.FromPlaceholderID)
+	payee, payeePlaceholder := participant(req.PayeeUserID, req.ToPlaceholderID)
+
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		if _, err := requireListRole(ctx, q, pgListID, db.ListRoleMember); err != nil {
+			return err
 		}
-		var debtors []struct {
-			UserID uuid.UUID
-			Amount float64
+		// Closed lists only accept the deposits that settle them.
+		amount := numericFromFloat(req.Amount, 2)
+		if req.IsSettlement {
+			err := requireOutstandingSettlement(ctx, q, pgListID,
+				participantKey(payer, payerPlaceholder),
+				participantKey(payee, payeePlaceholder),
+				amount,
+			)
+			if err != nil {
+				return err
+			}
+		} else if err := requireOpenList(ctx, q, pgListID); err != nil {
+			return err
 		}
 
-		for userID, balance := range balances {
-			if balance > 0 {
-				creditors = append(creditors, struct {
-					UserID uuid.UUID
-					Amount float64
-				}{UserID: userID, Amount: balance})
-			} else if balance < 0 {
-				debtors = append(debtors, struct {
-					UserID uuid.UUID
-					Amount float64
-				}{UserID: userID, Amount: -balance})
+		for _, placeholderID := range []pgtype.UUID{payerPlaceholder, payeePlaceholder} {
+			if err := checkPlaceholder(ctx, q, pgListID, placeholderID); err != nil {
+				return badRequest("placeholder_not_in_list", "placeholder does not belong to this list")
 			}
 		}
 
-		var transactions []TransactionResponse
-		i, j := 0, 0
-		for i < len(debtors) && j < len(creditors) {
-			debtor := &debtors[i]
-			creditor := &creditors[j]
+		deposit, err := q.CreateDeposit(ctx, db.CreateDepositParams{
+			ListID:             pgListID,
+			Amount:             amount,
+			PayerUserID:        payer,
+			PayeeUserID:        payee,
+			PayerPlaceholderID: payerPlaceholder,
+			PayeePlaceholderID: payeePlaceholder,
+			IsSettlement:       req.IsSettlement,
+		})
+		if err != nil {
+			return internalError("failed to create deposit", err)
+		}
 
-			minAmount := math.Min(debtor.Amount, creditor.Amount)
-			if minAmount > 0 {
-				transactions = append(transactions, TransactionResponse{
-					From:   debtor.UserID,
-					To:     creditor.UserID,
-					Amount: math.Round(minAmount*100) / 100,
-				})
+		resp, err := newDepositResponse(deposit)
+		if err != nil {
+			return internalError("failed to convert amount", err)
+		}
+		w.Header().Set("ETag", resourceETag(deposit.ID.Bytes, deposit.Version))
+		writeJSON(w, http.StatusCreated, resp)
 
-				debtor.Amount -= minAmount
-				creditor.Amount -= minAmount
-			}
+		return nil
+	})
+	return err
+}
 
-			if debtor.Amount == 0 {
-				i++
-			}
-			if creditor.Amount == 0 {
-				j++
+func (s *Server) GetAllDepositsForList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	listID, err := uuid.Parse(chi.URLParam(r, "list_id"))
+	if err != nil {
+		return badRequest("invalid_list_id", "invalid list ID")
+	}
+	pgListID := pgtype.UUID{Bytes: listID, Valid: true}
+
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		deposits, err := q.GetAllDepositsForListID(ctx, pgListID)
+		if err != nil {
+			return internalError("failed to fetch deposits", err)
+		}
+
+		resp := make([]DepositResponse, len(deposits))
+		tags := make([]string, len(deposits))
+		for i, d := range deposits {
+			resp[i], err = newDepositResponse(d)
+			if err != nil {
+				return internalError("failed to convert amount", err)
 			}
+			tags[i] = resourceETag(d.ID.Bytes, d.Version)
 		}
-		writeJSON(w, http.StatusOK, transactions)
 
+		if notModified(w, r, collectionETag(tags)) {
+			return nil
+		}
+		writeJSON(w, http.StatusOK, resp)
 		return nil
 	})
+	return err
 }
 
-func (s *Server) CreateDeposit(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-	listIDStr := chi.URLParam(r, "list_id")
-	listID, err := uuid.Parse(listIDStr)
+func (s *Server) DeleteDepositByID(w http.ResponseWriter, r *http.Request) error {
+	listID, err := uuid.Parse(chi.URLParam(r, "list_id"))
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid list ID")
-		return
+		return badRequest("invalid_list_id", "invalid list ID")
 	}
-	pgListID := pgtype.UUID{Bytes: listID, Valid: true}
+	depositID, err := uuid.Parse(chi.URLParam(r, "deposit_id"))
+	if err != nil {
+		return badRequest("invalid_deposit_id", "invalid deposit ID")
+	}
+	pgDepositID := pgtype.UUID{Bytes: depositID, Valid: true}
 
-	var req DepositRequest
-	if err := parseJSON(r.Body, &req); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid request body")
-		return
+	expectedVersion, err := ifMatchVersion(r, depositID)
+	if err != nil {
+		return preconditionFailed("deposit_has_been_modified", "deposit has been modified")
 	}
-	log.Println("Deposit request:", req)
 
-	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
-		deposit, err := q.CreateDeposit(ctx, db.CreateDepositParams{
-			ListID:      pgListID,
-			Amount:      numericFromFloat(req.Amount, 2),
-			PayerUserID: pgtype.UUID{Bytes: req.PayerUserID, Valid: true},
-			PayeeUserID: pgtype.UUID{Bytes: req.PayeeUserID, Valid: true},
-		})
+	err = s.Tx.WithCtxUserTx(r.Context(), func(q *db.Queries) error {
+		// The route's list is the one a restricted token was checked for.
+		deposit, err := q.GetDepositByID(r.Context(), pgDepositID)
+		if err != nil || deposit.ListID.Bytes != listID {
+			return notFound("deposit_not_found", "deposit not found")
+		}
+		role, err := requireListRole(r.Context(), q, deposit.ListID, db.ListRoleMember)
 		if err != nil {
-			log.Println("Error creating deposit:", err)
-			writeError(w, http.StatusInternalServerError, "failed to create deposit")
 			return err
 		}
-		writeJSON(w, http.StatusCreated, TransactionResponse{
-			From:   deposit.PayerUserID.Bytes,
-			To:     deposit.PayeeUserID.Bytes,
-			Amount: req.Amount,
-		})
+		if err := requireOpenList(r.Context(), q, deposit.ListID); err != nil {
+			return err
+		}
+		userID := r.Context().Value(contextkeys.UserID{}).(uuid.UUID)
+		if !canWriteEntry(role, deposit.CreatedBy, userID) {
+			return forbidden("admin_required", "only admins can delete deposits entered by others")
+		}
 
+		affected, err := q.DeleteDepositByID(r.Context(), db.DeleteDepositByIDParams{
+			ID:              pgDepositID,
+			ExpectedVersion: expectedVersion,
+		})
+		if err != nil {
+			return internalError("failed to delete deposit", err)
+		}
+		if affected == 0 {
+			return preconditionFailed("deposit_has_been_modified", "deposit has been modified")
+		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
 }
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"context"
+	"debt-manager/internal/db"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type CreatePlaceholderRequest struct {
+	DisplayName string `json:"display_name" validate:"required,max=100"`
+}
+
+func (req *CreatePlaceholderRequest) normalize() {
+	req.DisplayName = strings.TrimSpace(req.DisplayName)
+}
+
+type PlaceholderResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	ListID      uuid.UUID  `json:"list_id"`
+	DisplayName string     `json:"display_name"`
+	CreatedAt   string     `json:"created_at"`
+	ClaimedBy   *uuid.UUID `json:"claimed_by,omitempty"`
+}
+
+func newPlaceholderResponse(p db.ListPlaceholder) PlaceholderResponse {
+	resp := PlaceholderResponse{
+		ID:          p.ID.Bytes,
+		ListID:      p.ListID.Bytes,
+		DisplayName: p.DisplayName,
+		CreatedAt:   p.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if p.ClaimedBy.Valid {
+		claimedBy := uuid.UUID(p.ClaimedBy.Bytes)
+		resp.ClaimedBy = &claimedBy
+	}
+	return resp
+}
+
+// participant turns the (user, placeholder) pair of a request into the two
+// nullable columns stored in payments, divisions and deposits. The request's
+// validate tags make sure exactly one of them is set.
+func participant(userID, placeholderID *uuid.UUID) (pgtype.UUID, pgtype.UUID) {
+	if userID != nil {
+		return pgtype.UUID{Bytes: *userID, Valid: true}, pgtype.UUID{Valid: false}
+	}
+	return pgtype.UUID{Valid: false}, pgtype.UUID{Bytes: *placeholderID, Valid: true}
+}
+
+// participantKey is the ID a participant is known by in balances and suggested
+// transactions: the user ID, or the placeholder ID for guests.
+func participantKey(userID, placeholderID pgtype.UUID) uuid.UUID {
+	if placeholderID.Valid {
+		return placeholderID.Bytes
+	}
+	return userID.Bytes
+}
+
+func uuidPtr(id pgtype.UUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	u := uuid.UUID(id.Bytes)
+	return &u
+}
+
+// checkPlaceholder makes sure a placeholder referenced by a payment or deposit
+// belongs to that list and has not been claimed (and merged) by a user yet.
+func checkPlaceholder(ctx context.Context, q *db.Queries, listID pgtype.UUID, placeholderID pgtype.UUID) error {
+	if !placeholderID.Valid {
+		return nil
+	}
+	p, err := q.GetPlaceholderByID(ctx, placeholderID)
+	if err != nil {
+		return err
+	}
+	if p.ListID != listID || p.ClaimedBy.Valid {
+		return errors.New("placeholder is not usable in this list")
+	}
+	return nil
+}
+
+func (s *Server) CreatePlaceholder(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	listID, err := uuid.Parse(chi.URLParam(r, "list_id"))
+	if err != nil {
+		return badRequest("invalid_list_id", "invalid list ID")
+	}
+	pgListID := pgtype.UUID{Bytes: listID, Valid: true}
+
+	var req CreatePlaceholderRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		if _, err := requireListRole(ctx, q, pgListID, db.ListRoleMember); err != nil {
+			return err
+		}
+		if err := requireOpenList(ctx, q, pgListID); err != nil {
+			return err
+		}
+
+		placeholder, err := q.CreatePlaceholder(ctx, db.CreatePlaceholderParams{
+			ListID:      pgListID,
+			DisplayName: req.DisplayName,
+		})
+		if err != nil {
+			return internalError("failed to create placeholder", err)
+		}
+
+		writeJSON(w, http.StatusCreated, newPlaceholderResponse(placeholder))
+		return nil
+	})
+	return err
+}
+
+func (s *Server) GetPlaceholdersForList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	listID, err := uuid.Parse(chi.URLParam(r, "list_id"))
+	if err != nil {
+		return badRequest("invalid_list_id", "invalid list ID")
+	}
+	pgListID := pgtype.UUID{Bytes: listID, Valid: true}
+
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		placeholders, err := q.GetPlaceholdersForList(ctx, pgListID)
+		if err != nil {
+			return internalError("failed to retrieve placeholders", err)
+		}
+
+		resp := make([]PlaceholderResponse, len(placeholders))
+		for i, p := range placeholders {
+			resp[i] = newPlaceholderResponse(p)
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return nil
+	})
+	return err
+}
+
+func (s *Server) DeletePlaceholder(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	listID, err := uuid.Parse(chi.URLParam(r, "list_id"))
+	if err != nil {
+		return badRequest("invalid_list_id", "invalid list ID")
+	}
+	placeholderID, err := uuid.Parse(chi.URLParam(r, "placeholder_id"))
+	if err != nil {
+		return badRequest("invalid_placeholder_id", "invalid placeholder ID")
+	}
+
+	pgListID := pgtype.UUID{Bytes: listID, Valid: true}
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		if _, err := requireListRole(ctx, q, pgListID, db.ListRoleMember); err != nil {
+			return err
+		}
+		if err := requireOpenList(ctx, q, pgListID); err != nil {
+			return err
+		}
+
+		affected, err := q.DeletePlaceholder(ctx, db.DeletePlaceholderParams{
+			ID:     pgtype.UUID{Bytes: placeholderID, Valid: true},
+			ListID: pgListID,
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+				return conflict("placeholder_in_use", "placeholder still has payments or deposits")
+			}
+			return internalError("failed to delete placeholder", err)
+		}
+		if affected == 0 {
+			return notFound("placeholder_not_found", "placeholder not found")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	return err
+}
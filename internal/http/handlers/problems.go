@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"debt-manager/internal/i18n"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Errors are answered as RFC 7807 problem details. Handlers return a
+// *Problem, or any other error for a 500, and Handle writes it. The code of a
+// problem is stable for clients to match on; the detail is for humans and may
+// change. Titles and details are written in English and translated into the
+// language of the request when they are written.
+
+// Kind is the class of a problem. Each kind has its own HTTP status and type
+// URI, and errors.Is matches a problem against its kind.
+type Kind struct {
+	Status int
+	Slug   string
+	Title  string
+}
+
+func (k *Kind) Error() string {
+	return k.Title
+}
+
+var (
+	ErrBadRequest         = &Kind{http.StatusBadRequest, "bad-request", "Bad Request"}
+	ErrUnauthorized       = &Kind{http.StatusUnauthorized, "unauthorized", "Unauthorized"}
+	ErrForbidden          = &Kind{http.StatusForbidden, "forbidden", "Forbidden"}
+	ErrNotFound           = &Kind{http.StatusNotFound, "not-found", "Not Found"}
+	ErrConflict           = &Kind{http.StatusConflict, "conflict", "Conflict"}
+	ErrGone               = &Kind{http.StatusGone, "gone", "Gone"}
+	ErrPreconditionFailed = &Kind{http.StatusPreconditionFailed, "precondition-failed", "Precondition Failed"}
+	ErrPayloadTooLarge    = &Kind{http.StatusRequestEntityTooLarge, "payload-too-large", "Payload Too Large"}
+	ErrValidation         = &Kind{http.StatusUnprocessableEntity, "validation-failed", "Validation Failed"}
+	ErrTooManyRequests    = &Kind{http.StatusTooManyRequests, "too-many-requests", "Too Many Requests"}
+	ErrInternal           = &Kind{http.StatusInternalServerError, "internal", "Internal Server Error"}
+	ErrBadGateway         = &Kind{http.StatusBadGateway, "bad-gateway", "Bad Gateway"}
+)
+
+// FieldError is one invalid field of a request body.
+type FieldError struct {
+	Field  string `json:"field"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+	// Args fill the {0}-style placeholders of Detail.
+	Args []string `json:"-"`
+}
+
+func fieldError(field, code, detail string, args ...string) FieldError {
+	return FieldError{Field: field, Code: code, Detail: detail, Args: args}
+}
+
+type Problem struct {
+	Kind   *Kind
+	Code   string
+	Detail string
+	// Args fill the {0}-style placeholders of Detail.
+	Args   []string
+	Errors []FieldError
+	// RetryAfter is sent in the Retry-After header.
+	RetryAfter time.Duration
+
+	cause error
+}
+
+func (p *Problem) Error() string {
+	detail := i18n.Message(i18n.English, p.Detail, p.Args...)
+	if p.cause != nil {
+		return detail + ": " + p.cause.Error()
+	}
+	return detail
+}
+
+func (p *Problem) Unwrap() []error {
+	if p.cause != nil {
+		return []error{p.Kind, p.cause}
+	}
+	return []error{p.Kind}
+}
+
+func newProblem(kind *Kind, code, detail string) *Problem {
+	return &Problem{Kind: kind, Code: code, Detail: detail}
+}
+
+// with sets the values of the placeholders in the detail.
+func (p *Problem) with(args ...string) *Problem {
+	p.Args = args
+	return p
+}
+
+func badRequest(code, detail string) *Problem {
+	return newProblem(ErrBadRequest, code, detail)
+}
+
+func unauthorized(code, detail string) *Problem {
+	return newProblem(ErrUnauthorized, code, detail)
+}
+
+func forbidden(code, detail string) *Problem {
+	return newProblem(ErrForbidden, code, detail)
+}
+
+func notFound(code, detail string) *Problem {
+	return newProblem(ErrNotFound, code, detail)
+}
+
+func conflict(code, detail string) *Problem {
+	return newProblem(ErrConflict, code, detail)
+}
+
+func gone(code, detail string) *Problem {
+	return newProblem(ErrGone, code, detail)
+}
+
+func preconditionFailed(code, detail string) *Problem {
+	return newProblem(ErrPreconditionFailed, code, detail)
+}
+
+func badGateway(code, detail string) *Problem {
+	return newProblem(ErrBadGateway, code, detail)
+}
+
+func tooManyRequests(code, detail string, wait time.Duration) *Problem {
+	p := newProblem(ErrTooManyRequests, code, detail)
+	p.RetryAfter = wait
+	return p
+}
+
+// invalid reports field-level validation failures.
+func invalid(errs ...FieldError) *Problem {
+	p := newProblem(ErrValidation, "validation_failed", "the request has invalid fields")
+	p.Errors = errs
+	return p
+}
+
+// internalError hides cause from the client; it is logged when the problem
+// is written.
+func internalError(detail string, cause error) *Problem {
+	p := newProblem(ErrInternal, "internal_error", detail)
+	p.cause = cause
+	return p
+}
+
+type problemBody struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// writeProblem answers with err as a problem. Errors that are not problems
+// become a 500 without details. Server errors are logged.
+func writeProblem(w http.ResponseWriter, r *http.Request, err error) {
+	var p *Problem
+	if !errors.As(err, &p) {
+		p = internalError("internal server error", err)
+	}
+	if p.Kind.Status >= 500 {
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+
+	lang := requestLanguage(r.Context())
+	var errs []FieldError
+	for _, fe := range p.Errors {
+		fe.Detail = i18n.Message(lang, fe.Detail, fe.Args...)
+		errs = append(errs, fe)
+	}
+
+	if p.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(p.RetryAfter.Seconds()))))
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("Content-Language", lang)
+	w.WriteHeader(p.Kind.Status)
+	_ = json.NewEncoder(w).Encode(problemBody{
+		Type:      "/problems/" + p.Kind.Slug,
+		Title:     i18n.Message(lang, p.Kind.Title),
+		Status:    p.Kind.Status,
+		Detail:    i18n.Message(lang, p.Detail, p.Args...),
+		Instance:  r.URL.Path,
+		Code:      p.Code,
+		RequestID: middleware.GetReqID(r.Context()),
+		Errors:    errs,
+	})
+}
+
+// HandlerFunc is a handler that returns its error instead of writing it.
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Handle adapts a HandlerFunc to the router. An error after the response was
+// written, typically a failed commit, can only be logged.
+func Handle(h HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tw := &trackingWriter{ResponseWriter: w}
+		err := h(tw, r)
+		if err == nil {
+			return
+		}
+		if tw.wrote {
+			log.Printf("%s %s: failed after responding: %v", r.Method, r.URL.Path, err)
+			return
+		}
+		writeProblem(w, r, err)
+	}
+}
+
+type trackingWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (w *trackingWriter) WriteHeader(status int) {
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *trackingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"debt-manager/internal/ratelimit"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Per-account buckets, on top of the per-IP limits set up in the router, so
+// that spreading guesses over many addresses does not help.
+var (
+	accountLoginLimit = ratelimit.Limit{Burst: 10, Every: time.Minute}
+	accountMailLimit  = ratelimit.Limit{Burst: 3, Every: 20 * time.Minute}
+	accountMFALimit   = ratelimit.Limit{Burst: 10, Every: time.Minute}
+)
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// accountKey normalises an email so that case and stray spaces do not give an
+// attacker fresh buckets.
+func accountKey(prefix, email string) string {
+	return prefix + ":account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// RateLimit throttles a route per client IP. Limiter errors let the request
+// through; an outage of the limit store should not take logins down with it.
+func (s *Server) RateLimit(name string, limit ratelimit.Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.Limiter == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			wait, err := s.Limiter.Allow(r.Context(), name+":ip:"+clientIP(r), limit)
+			if err != nil {
+				log.Println("rate limit check failed:", err)
+			} else if wait > 0 {
+				writeProblem(w, r, tooManyRequests("rate_limited", "too many requests", wait))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// throttleAccount checks the key's lockout and bucket, returning a 429
+// problem if the request has to stop.
+func (s *Server) throttleAccount(ctx context.Context, key string, limit ratelimit.Limit) error {
+	if s.Limiter == nil {
+		return nil
+	}
+
+	locked, err := s.Limiter.LockedFor(ctx, key)
+	if err != nil {
+		log.Println("lockout check failed:", err)
+	} else if locked > 0 {
+		return tooManyRequests("account_locked", "too many failed attempts, try again later", locked)
+	}
+
+	wait, err := s.Limiter.Allow(ctx, key, limit)
+	if err != nil {
+		log.Println("rate limit check failed:", err)
+	} else if wait > 0 {
+		return tooManyRequests("rate_limited", "too many requests", wait)
+	}
+	return nil
+}
+
+// allowAccount takes a token from the key's bucket without writing a
+// response, for handlers that must not reveal they were throttled.
+func (s *Server) allowAccount(ctx context.Context, key string, limit ratelimit.Limit) bool {
+	if s.Limiter == nil {
+		return true
+	}
+	wait, err := s.Limiter.Allow(ctx, key, limit)
+	if err != nil {
+		log.Println("rate limit check failed:", err)
+		return true
+	}
+	return wait == 0
+}
+
+// failAccount counts a failed attempt towards the key's lockout.
+func (s *Server) failAccount(ctx context.Context, key string) {
+	if s.Limiter == nil {
+		return
+	}
+	if locked, err := s.Limiter.Fail(ctx, key); err != nil {
+		log.Println("failed to record failed attempt:", err)
+	} else if locked > 0 {
+		log.Printf("%s locked for %s", key, locked)
+	}
+}
+
+// succeedAccount clears the key's failures.
+func (s *Server) succeedAccount(ctx context.Context, key string) {
+	if s.Limiter == nil {
+		return
+	}
+	if err := s.Limiter.Succeed(ctx, key); err != nil {
+		log.Println("failed to clear failed attempts:", err)
+	}
+}
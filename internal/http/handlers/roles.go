@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"debt-manager/internal/db"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// listRoleRank orders roles the same way the list_role enum does in Postgres.
+var listRoleRank = map[db.ListRole]int{
+	db.ListRoleViewer: 1,
+	db.ListRoleMember: 2,
+	db.ListRoleAdmin:  3,
+	db.ListRoleOwner:  4,
+}
+
+func hasListRole(role, min db.ListRole) bool {
+	return listRoleRank[role] >= listRoleRank[min]
+}
+
+// requireListRole looks up the caller's role in a list and fails with a 404
+// problem when they are not a member, or a 403 when their role is below min.
+// RLS enforces the same rules; checking up front just gives clients a clear
+// status code.
+func requireListRole(ctx context.Context, q *db.Queries, listID pgtype.UUID, min db.ListRole) (db.ListRole, error) {
+	role, err := q.GetMyListRole(ctx, listID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", notFound("list_not_found", "list not found")
+		}
+		return "", internalError("failed to retrieve list role", err)
+	}
+
+	if !hasListRole(role, min) {
+		return role, forbidden("role_required", "this action requires the {0} role").with(string(min))
+	}
+	return role, nil
+}
+
+// canWriteEntry mirrors app.can_write_entry: admins change any payment or
+// deposit, members only the ones they entered themselves.
+func canWriteEntry(role db.ListRole, createdBy pgtype.UUID, userID uuid.UUID) bool {
+	if hasListRole(role, db.ListRoleAdmin) {
+		return true
+	}
+	return hasListRole(role, db.ListRoleMember) && createdBy.Valid && createdBy.Bytes == userID
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"debt-manager/internal/contextkeys"
+	"debt-manager/internal/db"
+	"debt-manager/internal/i18n"
+	"debt-manager/internal/mail"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	eventLoginSucceeded = "login_succeeded"
+	eventLoginFailed    = "login_failed"
+
+	// Browsers are told apart by a long-lived random cookie, native clients
+	// by an ID they choose and send in a header.
+	deviceCookie    = "device_id"
+	deviceCookieTTL = 400 * 24 * time.Hour
+	deviceIDHeader  = "X-Device-ID"
+
+	deviceAlertTTL = 7 * 24 * time.Hour
+	// notMePage is the frontend page of the alert's "this wasn't me" link,
+	// which revokes the session with POST /auth/security/not-me.
+	notMePage = "/security/not-me"
+
+	defaultSecurityEventsLimit = 50
+	maxSecurityEventsLimit     = 200
+)
+
+type SecurityEventResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	Kind      string     `json:"kind"`
+	SessionID *uuid.UUID `json:"session_id,omitempty"`
+	IP        *string    `json:"ip,omitempty"`
+	UserAgent *string    `json:"user_agent,omitempty"`
+	Browser   *string    `json:"browser,omitempty"`
+	OS        *string    `json:"os,omitempty"`
+	NewDevice bool       `json:"new_device"`
+	CreatedAt string     `json:"created_at"`
+}
+
+type RevokeAlertedSessionRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func newSecurityEventResponse(e db.AppSecurityEvent) SecurityEventResponse {
+	resp := SecurityEventResponse{
+		ID:        e.ID.Bytes,
+		Kind:      e.Kind,
+		NewDevice: e.NewDevice,
+		CreatedAt: e.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if e.SessionID.Valid {
+		sessionID := uuid.UUID(e.SessionID.Bytes)
+		resp.SessionID = &sessionID
+	}
+	if e.Ip.Valid {
+		resp.IP = &e.Ip.String
+	}
+	if e.UserAgent.Valid {
+		resp.UserAgent = &e.UserAgent.String
+	}
+	if e.Browser.Valid {
+		resp.Browser = &e.Browser.String
+	}
+	if e.Os.Valid {
+		resp.OS = &e.Os.String
+	}
+	return resp
+}
+
+// parseUserAgent names the browser and operating system in a User-Agent
+// header, without versions. It knows the common ones only; anything else is
+// left empty. Order matters, most browsers claim to be several others.
+func parseUserAgent(ua string) (browser, os string) {
+	switch {
+	case strings.Contains(ua, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(ua, "Firefox/"), strings.Contains(ua, "FxiOS/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "Chrome/"), strings.Contains(ua, "CriOS/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "Safari/"):
+		browser = "Safari"
+	case strings.HasPrefix(ua, "curl/"):
+		browser = "curl"
+	}
+
+	switch {
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"):
+		os = "iOS"
+	case strings.Contains(ua, "Android"):
+		os = "Android"
+	case strings.Contains(ua, "Windows"):
+		os = "Windows"
+	case strings.Contains(ua, "CrOS"):
+		os = "ChromeOS"
+	case strings.Contains(ua, "Macintosh"), strings.Contains(ua, "Mac OS X"):
+		os = "macOS"
+	case strings.Contains(ua, "Linux"):
+		os = "Linux"
+	}
+	return browser, os
+}
+
+// deviceID returns the ID of the device a request comes from, giving browsers
+// without one a new cookie. Browser updates do not change the fingerprint
+// built from it, as it leaves out versions.
+func deviceID(w http.ResponseWriter, r *http.Request) (string, error) {
+	if isNativeClient(r) {
+		return r.Header.Get(deviceIDHeader), nil
+	}
+	if c, err := r.Cookie(deviceCookie); err == nil && c.Value != "" {
+		return c.Value, nil
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := base64.RawURLEncoding.EncodeToString(b)
+	setCookie(w, deviceCookie, id, deviceCookieTTL)
+	return id, nil
+}
+
+func deviceFingerprint(id, browser, os string) []byte {
+	h := sha256.Sum256([]byte(browser + "|" + os + "|" + id))
+	return h[:]
+}
+
+func optionalText(s string) pgtype.Text {
+	return pgtype.Text{String: s, Valid: s != ""}
+}
+
+// recordLogin adds a new session to the user's login history and, when it
+// comes from a device the user has not logged in from before, returns an
+// alert with a link to revoke it, to be mailed by sendLoginAlert once the
+// login is committed. The very first device of an account is taken as known.
+func (s *Server) recordLogin(w http.ResponseWriter, r *http.Request, q *db.Queries, user db.AppUsersSafe, sessionID pgtype.UUID) (*mail.Message, error) {
+	ctx := r.Context()
+	browser, os := parseUserAgent(r.UserAgent())
+
+	id, err := deviceID(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	known, err := q.CountKnownDevices(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	inserted, err := q.TouchKnownDevice(ctx, db.TouchKnownDeviceParams{
+		UserID:      user.ID,
+		Fingerprint: deviceFingerprint(id, browser, os),
+	})
+	if err != nil {
+		return nil, err
+	}
+	newDevice := inserted && known > 0
+
+	err = q.CreateSecurityEvent(ctx, db.CreateSecurityEventParams{
+		UserID:    user.ID,
+		Kind:      eventLoginSucceeded,
+		SessionID: sessionID,
+		Ip:        optionalText(clientIP(r)),
+		UserAgent: optionalText(r.UserAgent()),
+		Browser:   optionalText(browser),
+		Os:        optionalText(os),
+		NewDevice: newDevice,
+	})
+	if err != nil || !newDevice {
+		return nil, err
+	}
+
+	raw, hash, err := createHashedToken()
+	if err != nil {
+		return nil, err
+	}
+	err = q.CreateDeviceAlert(ctx, db.CreateDeviceAlertParams{
+		UserID:    user.ID,
+		SessionID: sessionID,
+		TokenHash: hash,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(deviceAlertTTL), Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	link := s.accountLink(notMePage, raw)
+
+	lang := mailLanguage(ctx, user)
+	device := i18n.Message(lang, "an unknown device")
+	if browser != "" && os != "" {
+		device = i18n.Message(lang, "{0} on {1}", browser, os)
+	} else if browser+os != "" {
+		device = browser + os
+	}
+	msg := localizedMail(lang, user.Email, "new_login", user.Username, device, clientIP(r), link)
+	return &msg, nil
+}
+
+// sendLoginAlert mails the new device alert of a committed login, if there is
+// one. It goes out in the background so a slow mail server does not hold up
+// the login.
+func (s *Server) sendLoginAlert(ctx context.Context, msg *mail.Message) {
+	if msg == nil {
+		return
+	}
+	go func() {
+		if err := s.Mailer.Send(context.WithoutCancel(ctx), *msg); err != nil {
+			log.Println("new device alert not sent:", err)
+		}
+	}()
+}
+
+// recordFailedLogin adds a failed attempt to the user's login history. It
+// runs in a transaction of its own, as the one of the failed login is rolled
+// back.
+func (s *Server) recordFailedLogin(r *http.Request, userID pgtype.UUID) {
+	ctx := r.Context()
+	browser, os := parseUserAgent(r.UserAgent())
+
+	err := s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		return q.CreateSecurityEvent(ctx, db.CreateSecurityEventParams{
+			UserID:    userID,
+			Kind:      eventLoginFailed,
+			Ip:        optionalText(clientIP(r)),
+			UserAgent: optionalText(r.UserAgent()),
+			Browser:   optionalText(browser),
+			Os:        optionalText(os),
+		})
+	})
+	if err != nil {
+		log.Println("failed to record failed login:", err)
+	}
+}
+
+func (s *Server) GetSecurityEvents(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+
+	limit := defaultSecurityEventsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSecurityEventsLimit {
+			return badRequest("invalid_limit", "limit must be between 1 and {0}").with(strconv.Itoa(maxSecurityEventsLimit))
+		}
+		limit = n
+	}
+
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		events, err := q.GetSecurityEventsForUser(ctx, db.GetSecurityEventsForUserParams{
+			UserID: pgtype.UUID{Bytes: userID, Valid: true},
+			Limit:  int32(limit),
+		})
+		if err != nil {
+			return internalError("failed to retrieve security events", err)
+		}
+
+		resp := make([]SecurityEventResponse, 0, len(events))
+		for _, e := range events {
+			resp = append(resp, newSecurityEventResponse(e))
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+		return nil
+	})
+	return err
+}
+
+// RevokeAlertedSession is the "this wasn't me" link of a new device alert.
+// The token is all the proof needed: whoever got the alert owns the mailbox.
+func (s *Server) RevokeAlertedSession(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	var req RevokeAlertedSessionRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256([]byte(req.Token))
+	err := s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		sessionID, err := q.UseDeviceAlert(ctx, hash[:])
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound("invalid_token", "invalid or expired token")
+			}
+			return internalError("failed to revoke session", err)
+		}
+
+		if err := q.RevokeWholeSession(ctx, sessionID); err != nil {
+			return internalError("failed to revoke session", err)
+		}
+		if err := q.RevokeAllTokensInSession(ctx, sessionID); err != nil {
+			return internalError("failed to revoke session", err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	return err
+}
@@ -2,9 +2,34 @@ package handlers
 
 import (
 	"debt-manager/internal/db"
+	"debt-manager/internal/jwtkeys"
+	"debt-manager/internal/mail"
+	"debt-manager/internal/oidc"
+	"debt-manager/internal/ratelimit"
+	"net/url"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
 )
 
 type Server struct {
 	Tx *db.TxRunner
-	HS256PrivateKey []byte
+	Keys *jwtkeys.Set
+	Argon2 Argon2Params
+	// RefreshGrace is how long a replaced refresh token still yields its
+	// successor instead of revoking the session.
+	RefreshGrace time.Duration
+	// CookieMode hands browsers their access token as an HttpOnly cookie
+	// guarded by a CSRF token instead of in the response body.
+	CookieMode bool
+	Mailer mail.Sender
+	EmailPolicy EmailPolicy
+	OIDCProviders map[string]*oidc.Provider
+	Limiter *ratelimit.Limiter
+	// WebAuthn backs passkeys. Its relying party defaults to the API's own
+	// host, so it is always set.
+	WebAuthn *webauthn.WebAuthn
+	// AppURL is the frontend. Links in mail open its pages, which POST the
+	// token to the API.
+	AppURL *url.URL
 }
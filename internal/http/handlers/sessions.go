@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"debt-manager/internal/contextkeys"
+	"debt-manager/internal/db"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  *string   `json:"user_agent,omitempty"`
+	IP         *string   `json:"ip,omitempty"`
+	CreatedAt  string    `json:"created_at"`
+	LastUsedAt *string   `json:"last_used_at,omitempty"`
+	ExpiresAt  string    `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+func newSessionResponse(session db.AppSession, currentID uuid.UUID) SessionResponse {
+	resp := SessionResponse{
+		ID:        session.ID.Bytes,
+		CreatedAt: session.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+		ExpiresAt: session.ExpiresAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+		Current:   uuid.UUID(session.ID.Bytes) == currentID,
+	}
+	if session.UserAgent.Valid {
+		resp.UserAgent = &session.UserAgent.String
+	}
+	if session.Ip.Valid {
+		resp.IP = &session.Ip.String
+	}
+	if session.LastUsedAt.Valid {
+		lastUsedAt := session.LastUsedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+		resp.LastUsedAt = &lastUsedAt
+	}
+	return resp
+}
+
+// clearAuthCookies drops the cookies set by createSession and Refresh.
+func clearAuthCookies(w http.ResponseWriter) {
+	clearCookie(w, "access_token")
+	clearCookie(w, "refresh_token", "/auth/refresh")
+	clearCookie(w, csrfCookie)
+}
+
+func (s *Server) Logout(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	sessionID, err := uuid.Parse(ctx.Value(contextkeys.SessionID{}).(string))
+	if err != nil {
+		return unauthorized("invalid_access_token", "unauthorized")
+	}
+	pgSessionID := pgtype.UUID{Bytes: sessionID, Valid: true}
+
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		if err := q.RevokeWholeSession(ctx, pgSessionID); err != nil {
+			return internalError("failed to revoke session", err)
+		}
+		if err := q.RevokeAllTokensInSession(ctx, pgSessionID); err != nil {
+			return internalError("failed to revoke session", err)
+		}
+
+		clearAuthCookies(w)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	return err
+}
+
+func (s *Server) LogoutAll(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		if err := q.RevokeAllUserSessions(ctx, pgtype.UUID{Bytes: userID, Valid: true}); err != nil {
+			return internalError("failed to revoke sessions", err)
+		}
+
+		clearAuthCookies(w)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	return err
+}
+
+func (s *Server) GetMySessions(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+	currentID, _ := uuid.Parse(ctx.Value(contextkeys.SessionID{}).(string))
+
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		sessions, err := q.GetActiveSessionsForUser(ctx, pgtype.UUID{Bytes: userID, Valid: true})
+		if err != nil {
+			return internalError("failed to retrieve sessions", err)
+		}
+
+		resp := make([]SessionResponse, 0, len(sessions))
+		for _, session := range sessions {
+			resp = append(resp, newSessionResponse(session, currentID))
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+		return nil
+	})
+	return err
+}
+
+func (s *Server) RevokeMySession(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+	sessionID, err := uuid.Parse(chi.URLParam(r, "session_id"))
+	if err != nil {
+		return badRequest("invalid_session_id", "invalid session ID")
+	}
+	currentID, _ := uuid.Parse(ctx.Value(contextkeys.SessionID{}).(string))
+
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		rows, err := q.RevokeUserSession(ctx, db.RevokeUserSessionParams{
+			ID:     pgtype.UUID{Bytes: sessionID, Valid: true},
+			UserID: pgtype.UUID{Bytes: userID, Valid: true},
+		})
+		if err != nil {
+			return internalError("failed to revoke session", err)
+		}
+		if rows == 0 {
+			return notFound("session_not_found", "session not found")
+		}
+
+		// Revoking the device the request came from is a logout.
+		if sessionID == currentID {
+			clearAuthCookies(w)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"debt-manager/internal/db"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// SettlementResponse is the snapshot taken when a list was closed: the final
+// balances and the transactions that settle them.
+type SettlementResponse struct {
+	ListID       uuid.UUID             `json:"list_id"`
+	CreatedAt    string                `json:"created_at"`
+	Balances     map[uuid.UUID]float64 `json:"balances"`
+	Transactions []TransactionResponse `json:"transactions"`
+}
+
+func newSettlementResponse(settlement db.ListSettlement) (SettlementResponse, error) {
+	resp := SettlementResponse{
+		ListID:    settlement.ListID.Bytes,
+		CreatedAt: settlement.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if err := json.Unmarshal(settlement.Balances, &resp.Balances); err != nil {
+		return SettlementResponse{}, err
+	}
+	if err := json.Unmarshal(settlement.Transactions, &resp.Transactions); err != nil {
+		return SettlementResponse{}, err
+	}
+	return resp, nil
+}
+
+func (s *Server) CloseList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	id, err := uuid.Parse(chi.URLParam(r, "list_id"))
+	if err != nil {
+		return badRequest("invalid_list_id", "invalid list ID")
+	}
+	PGID := pgtype.UUID{Bytes: id, Valid: true}
+
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		if _, err := requireListRole(ctx, q, PGID, db.ListRoleOwner); err != nil {
+			return err
+		}
+		if err := requireOpenList(ctx, q, PGID); err != nil {
+			return err
+		}
+
+		balances, err := getBalancesByListID(q, ctx, PGID)
+		if err != nil {
+			return internalError("failed to fetch net balances", err)
+		}
+		transactions := suggestTransactions(balances)
+		if transactions == nil {
+			transactions = []TransactionResponse{}
+		}
+
+		balancesJSON, err := json.Marshal(balances)
+		if err != nil {
+			return internalError("failed to store settlement", err)
+		}
+		transactionsJSON, err := json.Marshal(transactions)
+		if err != nil {
+			return internalError("failed to store settlement", err)
+		}
+
+		list, err := q.CloseList(ctx, PGID)
+		if err != nil {
+			return internalError("failed to close list", err)
+		}
+
+		settlement, err := q.CreateListSettlement(ctx, db.CreateListSettlementParams{
+			ListID:       PGID,
+			Balances:     balancesJSON,
+			Transactions: transactionsJSON,
+		})
+		if err != nil {
+			return internalError("failed to store settlement", err)
+		}
+
+		resp, err := newSettlementResponse(settlement)
+		if err != nil {
+			return internalError("failed to read settlement", err)
+		}
+
+		w.Header().Set("ETag", listETag(list))
+		writeJSON(w, http.StatusOK, resp)
+		return nil
+	})
+	return err
+}
+
+func (s *Server) ReopenList(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	id, err := uuid.Parse(chi.URLParam(r, "list_id"))
+	if err != nil {
+		return badRequest("invalid_list_id", "invalid list ID")
+	}
+	PGID := pgtype.UUID{Bytes: id, Valid: true}
+
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		if _, err := requireListRole(ctx, q, PGID, db.ListRoleOwner); err != nil {
+			return err
+		}
+
+		list, err := q.ReopenList(ctx, PGID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return conflict("list_not_closed", "list is not closed")
+			}
+			return internalError("failed to reopen list", err)
+		}
+
+		w.Header().Set("ETag", listETag(list))
+		writeJSON(w, http.StatusOK, newListResponse(list))
+		return nil
+	})
+	return err
+}
+
+func (s *Server) GetListSettlement(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	id, err := uuid.Parse(chi.URLParam(r, "list_id"))
+	if err != nil {
+		return badRequest("invalid_list_id", "invalid list ID")
+	}
+	PGID := pgtype.UUID{Bytes: id, Valid: true}
+
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		settlement, err := q.GetLatestListSettlement(ctx, PGID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return notFound("settlement_not_found", "list has no settlement")
+			}
+			return internalError("failed to retrieve settlement", err)
+		}
+
+		resp, err := newSettlementResponse(settlement)
+		if err != nil {
+			return internalError("failed to read settlement", err)
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+		return nil
+	})
+	return err
+}
+
+// requireOutstandingSettlement lets a settlement deposit into a closed list
+// only when it pays a transaction of the list's settlement that is still
+// owed. The deposits_open_insert policy enforces the same.
+func requireOutstandingSettlement(ctx context.Context, q *db.Queries, listID pgtype.UUID, payer, payee uuid.UUID, amount pgtype.Numeric) error {
+	list, err := q.GetListByID(ctx, listID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return notFound("list_not_found", "list not found")
+		}
+		return internalError("failed to retrieve list", err)
+	}
+	if !list.ClosedAt.Valid {
+		return conflict("list_not_closed", "list is not closed")
+	}
+
+	outstanding, err := q.SettlementOutstanding(ctx, db.SettlementOutstandingParams{
+		ListID:  listID,
+		PayerID: pgtype.UUID{Bytes: payer, Valid: true},
+		PayeeID: pgtype.UUID{Bytes: payee, Valid: true},
+		Amount:  amount,
+	})
+	if err != nil {
+		return internalError("failed to check settlement", err)
+	}
+	if !outstanding {
+		return conflict("settlement_not_outstanding", "deposit does not pay an outstanding settlement transaction")
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"debt-manager/internal/contextkeys"
+	"debt-manager/internal/db"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Personal access tokens start with patPrefix so Auth can tell them from
+// session JWTs, and so leaked tokens are easy to spot in logs and scanners.
+const patPrefix = "dmpat_"
+
+const (
+	ScopeListsRead     = "lists:read"
+	ScopeListsWrite    = "lists:write"
+	ScopePaymentsRead  = "payments:read"
+	ScopePaymentsWrite = "payments:write"
+)
+
+var knownScopes = map[string]bool{
+	ScopeListsRead:     true,
+	ScopeListsWrite:    true,
+	ScopePaymentsRead:  true,
+	ScopePaymentsWrite: true,
+}
+
+// TokenGrant is what the personal access token of a request allows. Auth puts
+// it in the context; requests with a session have none.
+type TokenGrant struct {
+	TokenID uuid.UUID
+	Scopes  []string
+	ListIDs []uuid.UUID
+}
+
+func (g TokenGrant) hasScope(scope string) bool {
+	for _, s := range g.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsList reports whether the token may touch the list. Unrestricted
+// tokens may touch all of the user's lists.
+func (g TokenGrant) allowsList(listID string) bool {
+	if len(g.ListIDs) == 0 {
+		return true
+	}
+	id, err := uuid.Parse(listID)
+	if err != nil {
+		return false
+	}
+	for _, l := range g.ListIDs {
+		if l == id {
+			return true
+		}
+	}
+	return false
+}
+
+type CreateTokenRequest struct {
+	Name      string      `json:"name" validate:"required,max=100"`
+	Scopes    []string    `json:"scopes" validate:"required,min=1,dive,scope"`
+	ListIDs   []uuid.UUID `json:"list_ids"`
+	ExpiresAt *time.Time  `json:"expires_at" validate:"omitnil,gt"`
+}
+
+func (req *CreateTokenRequest) normalize() {
+	req.Name = strings.TrimSpace(req.Name)
+}
+
+type TokenResponse struct {
+	ID         uuid.UUID   `json:"id"`
+	Name       string      `json:"name"`
+	Token      string      `json:"token,omitempty"`
+	Scopes     []string    `json:"scopes"`
+	ListIDs    []uuid.UUID `json:"list_ids"`
+	CreatedAt  string      `json:"created_at"`
+	ExpiresAt  *string     `json:"expires_at,omitempty"`
+	LastUsedAt *string     `json:"last_used_at,omitempty"`
+}
+
+func newTokenResponse(token db.AppPersonalAccessToken) TokenResponse {
+	resp := TokenResponse{
+		ID:        token.ID.Bytes,
+		Name:      token.Name,
+		Scopes:    token.Scopes,
+		ListIDs:   make([]uuid.UUID, 0, len(token.ListIds)),
+		CreatedAt: token.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	for _, id := range token.ListIds {
+		resp.ListIDs = append(resp.ListIDs, id.Bytes)
+	}
+	if token.ExpiresAt.Valid {
+		expiresAt := token.ExpiresAt.Time.Format("2006-01-02T15:04:05Z07:00")
+		resp.ExpiresAt = &expiresAt
+	}
+	if token.LastUsedAt.Valid {
+		lastUsedAt := token.LastUsedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+		resp.LastUsedAt = &lastUsedAt
+	}
+	return resp
+}
+
+func (s *Server) CreatePersonalAccessToken(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+
+	var req CreateTokenRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		listIDs := make([]pgtype.UUID, 0, len(req.ListIDs))
+		for _, id := range req.ListIDs {
+			listID := pgtype.UUID{Bytes: id, Valid: true}
+			if _, err := q.GetMyListRole(ctx, listID); err != nil {
+				return badRequest("list_not_member", "not a member of list {0}").with(id.String())
+			}
+			listIDs = append(listIDs, listID)
+		}
+
+		secret, _, err := createHashedToken()
+		if err != nil {
+			return internalError("failed to create token", err)
+		}
+		raw := patPrefix + secret
+		hash := sha256.Sum256([]byte(raw))
+
+		var expiresAt pgtype.Timestamptz
+		if req.ExpiresAt != nil {
+			expiresAt = pgtype.Timestamptz{Time: *req.ExpiresAt, Valid: true}
+		}
+
+		token, err := q.CreatePersonalAccessToken(ctx, db.CreatePersonalAccessTokenParams{
+			UserID:    pgtype.UUID{Bytes: userID, Valid: true},
+			Name:      req.Name,
+			TokenHash: hash[:],
+			Scopes:    req.Scopes,
+			ListIds:   listIDs,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			return internalError("failed to store token", err)
+		}
+
+		// The token itself is only ever shown here.
+		resp := newTokenResponse(token)
+		resp.Token = raw
+		writeJSON(w, http.StatusCreated, resp)
+		return nil
+	})
+	return err
+}
+
+func (s *Server) GetPersonalAccessTokens(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		tokens, err := q.GetPersonalAccessTokensForUser(ctx, pgtype.UUID{Bytes: userID, Valid: true})
+		if err != nil {
+			return internalError("failed to retrieve tokens", err)
+		}
+
+		resp := make([]TokenResponse, 0, len(tokens))
+		for _, token := range tokens {
+			resp = append(resp, newTokenResponse(token))
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+		return nil
+	})
+	return err
+}
+
+func (s *Server) RevokePersonalAccessToken(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+	tokenID, err := uuid.Parse(chi.URLParam(r, "token_id"))
+	if err != nil {
+		return badRequest("invalid_token_id", "invalid token ID")
+	}
+
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		rows, err := q.RevokePersonalAccessToken(ctx, db.RevokePersonalAccessTokenParams{
+			ID:     pgtype.UUID{Bytes: tokenID, Valid: true},
+			UserID: pgtype.UUID{Bytes: userID, Valid: true},
+		})
+		if err != nil {
+			return internalError("failed to revoke token", err)
+		}
+		if rows == 0 {
+			return notFound("token_not_found", "token not found")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	return err
+}
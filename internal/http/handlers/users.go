@@ -3,7 +3,6 @@ package handlers
 import (
 	"debt-manager/internal/contextkeys"
 	"debt-manager/internal/db"
-	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -12,46 +11,62 @@ import (
 )
 
 type UserResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Email     string    `json:"email"`
-	Username  string    `json:"username"`
-	CreatedAt string    `json:"created_at"`
-	ItsYou    bool      `json:"its_you,omitempty"`
+	ID          uuid.UUID `json:"id"`
+	Email       string    `json:"email"`
+	Username    string    `json:"username"`
+	DisplayName *string   `json:"display_name,omitempty"`
+	CreatedAt   string    `json:"created_at"`
+	ItsYou      bool      `json:"its_you,omitempty"`
+	Role        string    `json:"role,omitempty"`
+	// FormerMember is set for people who left or were removed from the list;
+	// they are still listed because their payments remain attributed to them.
+	FormerMember bool    `json:"former_member,omitempty"`
+	LeftAt       *string `json:"left_at,omitempty"`
 }
 
-func (s *Server) GetUsersFromList(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetUsersFromList(w http.ResponseWriter, r *http.Request) error {
 	listIDStr := chi.URLParam(r, "list_id")
 	listID, err := uuid.Parse(listIDStr)
 	listPgID := pgtype.UUID{Bytes: listID, Valid: true}
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid list ID")
-		return
+		return badRequest("invalid_list_id", "invalid list ID")
 	}
 
 	ctx := r.Context()
 	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
 		users, err := q.GetUsersFromList(ctx, listPgID)
 		if err != nil {
-			log.Println("Error fetching users from list:", err)
-			writeError(w, http.StatusInternalServerError, "failed to fetch users from list")
-			return err
+			return internalError("failed to fetch users from list", err)
 		}
 
 		var resp []UserResponse
 		for _, user := range users {
 			var contextUserID uuid.UUID = ctx.Value(contextkeys.UserID{}).(uuid.UUID)
 			itsYou := user.ID.Bytes == contextUserID
+			var displayName *string
+			if user.DisplayName.Valid {
+				displayName = &user.DisplayName.String
+			}
+			var leftAt *string
+			if user.LeftAt.Valid {
+				formatted := user.LeftAt.Time.Format("2006-01-02T15:04:05Z07:00")
+				leftAt = &formatted
+			}
 			resp = append(resp, UserResponse{
-				ID:        user.ID.Bytes,
-				Email:     user.Email,
-				Username:  user.Username,
-				CreatedAt: user.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
-				ItsYou:    itsYou,
+				ID:           user.ID.Bytes,
+				Email:        user.Email,
+				Username:     user.Username,
+				DisplayName:  displayName,
+				CreatedAt:    user.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+				ItsYou:       itsYou,
+				Role:         string(user.Role),
+				FormerMember: user.LeftAt.Valid,
+				LeftAt:       leftAt,
 			})
 		}
 		writeJSON(w, http.StatusOK, resp)
 
 		return nil
 	})
-
+	return err
 }
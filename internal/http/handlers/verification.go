@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"debt-manager/internal/contextkeys"
+	"debt-manager/internal/db"
+	"debt-manager/internal/mail"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	emailVerificationTTL = 48 * time.Hour
+	// A new verification mail can be requested once per interval.
+	emailVerificationResendInterval = time.Minute
+	// verifyEmailPage is the frontend page that verifies the address with
+	// POST /auth/verify-email.
+	verifyEmailPage = "/verify-email"
+)
+
+// Actions an EmailPolicy can hold back until the user has verified their email.
+const (
+	ActionCreateList       = "create_list"
+	ActionCreateInvitation = "create_invitation"
+	ActionAcceptInvitation = "accept_invitation"
+	ActionCreatePayment    = "create_payment"
+)
+
+var emailPolicyActions = map[string]bool{
+	ActionCreateList:       true,
+	ActionCreateInvitation: true,
+	ActionAcceptInvitation: true,
+	ActionCreatePayment:    true,
+}
+
+// EmailPolicy is the set of actions that need a verified email address.
+type EmailPolicy map[string]bool
+
+func NewEmailPolicy(actions []string) (EmailPolicy, error) {
+	policy := EmailPolicy{}
+	for _, action := range actions {
+		if !emailPolicyActions[action] {
+			return nil, fmt.Errorf("unknown action %q", action)
+		}
+		policy[action] = true
+	}
+	return policy, nil
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// createEmailVerification stores a verification token for the user's current
+// address and returns the mail with its link. Callers send it once the token
+// is committed.
+func (s *Server) createEmailVerification(ctx context.Context, q *db.Queries, user db.AppUsersSafe) (mail.Message, error) {
+	raw, hash, err := createHashedToken()
+	if err != nil {
+		return mail.Message{}, err
+	}
+
+	err = q.CreateEmailVerificationToken(ctx, db.CreateEmailVerificationTokenParams{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: hash,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(emailVerificationTTL), Valid: true},
+	})
+	if err != nil {
+		return mail.Message{}, err
+	}
+
+	link := s.accountLink(verifyEmailPage, raw)
+	return localizedMail(mailLanguage(ctx, user), user.Email, "verify_email", user.Username, link), nil
+}
+
+// mailEmailVerification sends the first verification mail of an account once
+// it is committed, in a transaction of its own. A failed mail does not fail
+// what created the account; the user can ask for another one.
+func (s *Server) mailEmailVerification(ctx context.Context, userID pgtype.UUID) {
+	var msg mail.Message
+	err := s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		user, err := q.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		msg, err = s.createEmailVerification(ctx, q, user)
+		return err
+	})
+	if err == nil {
+		err = s.Mailer.Send(ctx, msg)
+	}
+	if err != nil {
+		log.Println("failed to send verification email:", err)
+	}
+}
+
+func (s *Server) VerifyEmail(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	var req VerifyEmailRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256([]byte(req.Token))
+	err := s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		_, err := q.VerifyEmail(ctx, hash[:])
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				switch pgErr.Message {
+				case "email_verification_not_found":
+					return notFound("invalid_token", "invalid token")
+				case "email_verification_not_usable":
+					return gone("token_not_usable", "token expired or already used")
+				}
+			}
+			return internalError("failed to verify email", err)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	return err
+}
+
+func (s *Server) ResendEmailVerification(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+	pgUserID := pgtype.UUID{Bytes: userID, Valid: true}
+
+	var msg mail.Message
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		user, err := q.GetUserByID(ctx, pgUserID)
+		if err != nil {
+			return internalError("failed to retrieve user", err)
+		}
+
+		if user.EmailVerifiedAt.Valid {
+			return conflict("email_already_verified", "email already verified")
+		}
+
+		last, err := q.GetLatestEmailVerificationToken(ctx, pgUserID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return internalError("failed to check previous verification", err)
+		}
+		if err == nil {
+			if wait := time.Until(last.CreatedAt.Time.Add(emailVerificationResendInterval)); wait > 0 {
+				return tooManyRequests("verification_email_throttled", "verification email was sent recently", wait)
+			}
+		}
+
+		msg, err = s.createEmailVerification(ctx, q, user)
+		if err != nil {
+			return internalError("failed to create verification", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.Mailer.Send(ctx, msg); err != nil {
+		return internalError("failed to send verification email", err)
+	}
+	w.WriteHeader(http.StatusAccepted)
+	return nil
+}
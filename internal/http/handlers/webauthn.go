@@ -0,0 +1,494 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"debt-manager/internal/contextkeys"
+	"debt-manager/internal/db"
+	"debt-manager/internal/mail"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Passkeys are registered by a signed-in user and then log in on their own,
+// in place of email and password. Both ceremonies take two requests: start
+// returns the options for navigator.credentials.create/get and a challenge
+// ID, finish sends back the challenge ID with the authenticator's response.
+const (
+	webauthnChallengeTTL = 5 * time.Minute
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+)
+
+var errInvalidChallenge = errors.New("invalid or expired challenge")
+
+type WebAuthnStartResponse struct {
+	ChallengeID string `json:"challenge_id"`
+	Options     any    `json:"options"`
+}
+
+type FinishWebAuthnRegistrationRequest struct {
+	ChallengeID string          `json:"challenge_id" validate:"required"`
+	Name        string          `json:"name" validate:"max=100"`
+	Credential  json.RawMessage `json:"credential" validate:"required"`
+}
+
+func (req *FinishWebAuthnRegistrationRequest) normalize() {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		req.Name = "Passkey"
+	}
+}
+
+type StartWebAuthnLoginRequest struct {
+	Email string `json:"email"`
+}
+
+func (req *StartWebAuthnLoginRequest) normalize() {
+	req.Email = strings.TrimSpace(req.Email)
+}
+
+type FinishWebAuthnLoginRequest struct {
+	ChallengeID string          `json:"challenge_id" validate:"required"`
+	Credential  json.RawMessage `json:"credential" validate:"required"`
+}
+
+type WebAuthnCredentialResponse struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Transports []string `json:"transports"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt *string  `json:"last_used_at"`
+}
+
+func newWebAuthnCredentialResponse(c db.AppWebauthnCredential) WebAuthnCredentialResponse {
+	resp := WebAuthnCredentialResponse{
+		ID:         c.ID.String(),
+		Name:       c.Name,
+		Transports: c.Transports,
+		CreatedAt:  c.CreatedAt.Time.Format("2006-01-02T15:04:05Z07:00"),
+	}
+	if resp.Transports == nil {
+		resp.Transports = []string{}
+	}
+	if c.LastUsedAt.Valid {
+		lastUsedAt := c.LastUsedAt.Time.Format("2006-01-02T15:04:05Z07:00")
+		resp.LastUsedAt = &lastUsedAt
+	}
+	return resp
+}
+
+// webauthnUser is a user with their passkeys, as the webauthn package wants
+// them. The user handle is the user's ID.
+type webauthnUser struct {
+	user  db.AppUsersSafe
+	creds []db.AppWebauthnCredential
+}
+
+func (u webauthnUser) WebAuthnID() []byte {
+	return u.user.ID.Bytes[:]
+}
+
+func (u webauthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u webauthnUser) WebAuthnDisplayName() string {
+	if u.user.DisplayName.Valid && u.user.DisplayName.String != "" {
+		return u.user.DisplayName.String
+	}
+	return u.user.Username
+}
+
+func (u webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	creds := make([]webauthn.Credential, 0, len(u.creds))
+	for _, c := range u.creds {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(c.Transports))
+		for _, t := range c.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+		creds = append(creds, webauthn.Credential{
+			ID:              c.CredentialID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(c.Flags)),
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.Aaguid,
+				SignCount: uint32(c.SignCount),
+			},
+		})
+	}
+	return creds
+}
+
+// credential returns the stored row of a credential the webauthn package
+// verified.
+func (u webauthnUser) credential(id []byte) (db.AppWebauthnCredential, bool) {
+	for _, c := range u.creds {
+		if bytes.Equal(c.CredentialID, id) {
+			return c, true
+		}
+	}
+	return db.AppWebauthnCredential{}, false
+}
+
+func loadWebAuthnUser(ctx context.Context, q *db.Queries, userID pgtype.UUID) (webauthnUser, error) {
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		return webauthnUser{}, err
+	}
+	creds, err := q.GetWebAuthnCredentialsForUser(ctx, userID)
+	if err != nil {
+		return webauthnUser{}, err
+	}
+	return webauthnUser{user: user, creds: creds}, nil
+}
+
+func saveWebAuthnChallenge(ctx context.Context, q *db.Queries, userID pgtype.UUID, ceremony string, session *webauthn.SessionData) (pgtype.UUID, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return pgtype.UUID{}, err
+	}
+	return q.CreateWebAuthnChallenge(ctx, db.CreateWebAuthnChallengeParams{
+		UserID:    userID,
+		Ceremony:  ceremony,
+		Session:   data,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(webauthnChallengeTTL), Valid: true},
+	})
+}
+
+// takeWebAuthnChallenge spends a challenge in its own transaction, so that a
+// failed verification cannot be retried against the same challenge.
+func (s *Server) takeWebAuthnChallenge(ctx context.Context, id, ceremony string) (db.AppWebauthnChallenge, webauthn.SessionData, error) {
+	var session webauthn.SessionData
+	challengeID, err := uuid.Parse(id)
+	if err != nil {
+		return db.AppWebauthnChallenge{}, session, errInvalidChallenge
+	}
+
+	var challenge db.AppWebauthnChallenge
+	err = s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		var err error
+		challenge, err = q.TakeWebAuthnChallenge(ctx, db.TakeWebAuthnChallengeParams{
+			ID:       pgtype.UUID{Bytes: challengeID, Valid: true},
+			Ceremony: ceremony,
+		})
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && challenge.ExpiresAt.Time.Before(time.Now())) {
+		return challenge, session, errInvalidChallenge
+	}
+	if err != nil {
+		return challenge, session, err
+	}
+
+	if err := json.Unmarshal(challenge.Session, &session); err != nil {
+		return challenge, session, err
+	}
+	return challenge, session, nil
+}
+
+// requireUserVerification makes authenticators check the user with a PIN or
+// biometric, not only their presence: a passkey login skips the MFA challenge.
+func requireUserVerification(options *protocol.PublicKeyCredentialCreationOptions) {
+	options.AuthenticatorSelection.UserVerification = protocol.VerificationRequired
+}
+
+func (s *Server) StartWebAuthnRegistration(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+	pgUserID := pgtype.UUID{Bytes: userID, Valid: true}
+
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		u, err := loadWebAuthnUser(ctx, q, pgUserID)
+		if err != nil {
+			return internalError("failed to retrieve user", err)
+		}
+
+		creation, session, err := s.WebAuthn.BeginRegistration(u,
+			webauthn.WithExclusions(webauthn.Credentials(u.WebAuthnCredentials()).CredentialDescriptors()),
+			webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+			requireUserVerification,
+		)
+		if err != nil {
+			return internalError("failed to start registration", err)
+		}
+
+		challengeID, err := saveWebAuthnChallenge(ctx, q, pgUserID, ceremonyRegistration, session)
+		if err != nil {
+			return internalError("failed to start registration", err)
+		}
+
+		writeJSON(w, http.StatusOK, WebAuthnStartResponse{
+			ChallengeID: challengeID.String(),
+			Options:     creation,
+		})
+		return nil
+	})
+	return err
+}
+
+func (s *Server) FinishWebAuthnRegistration(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+	pgUserID := pgtype.UUID{Bytes: userID, Valid: true}
+
+	var req FinishWebAuthnRegistrationRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	challenge, session, err := s.takeWebAuthnChallenge(ctx, req.ChallengeID, ceremonyRegistration)
+	if err == nil && challenge.UserID != pgUserID {
+		err = errInvalidChallenge
+	}
+	if err != nil {
+		if errors.Is(err, errInvalidChallenge) {
+			return badRequest("invalid_challenge", err.Error())
+		}
+		return internalError("failed to finish registration", err)
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(req.Credential)
+	if err != nil {
+		return badRequest("invalid_credential", "invalid credential")
+	}
+
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		u, err := loadWebAuthnUser(ctx, q, pgUserID)
+		if err != nil {
+			return internalError("failed to retrieve user", err)
+		}
+
+		cred, err := s.WebAuthn.CreateCredential(u, session, parsed)
+		if err != nil {
+			return badRequest("passkey_verification_failed", "passkey verification failed")
+		}
+
+		transports := make([]string, 0, len(cred.Transport))
+		for _, t := range cred.Transport {
+			transports = append(transports, string(t))
+		}
+
+		row, err := q.CreateWebAuthnCredential(ctx, db.CreateWebAuthnCredentialParams{
+			UserID:          pgUserID,
+			Name:            req.Name,
+			CredentialID:    cred.ID,
+			PublicKey:       cred.PublicKey,
+			AttestationType: cred.AttestationType,
+			Transports:      transports,
+			Aaguid:          cred.Authenticator.AAGUID,
+			SignCount:       int64(cred.Authenticator.SignCount),
+			Flags:           int16(cred.Flags.ProtocolValue()),
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+				return conflict("passkey_already_registered", "passkey already registered")
+			}
+			return internalError("failed to store passkey", err)
+		}
+
+		writeJSON(w, http.StatusCreated, newWebAuthnCredentialResponse(row))
+		return nil
+	})
+	return err
+}
+
+func (s *Server) GetWebAuthnCredentials(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+
+	err := s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		creds, err := q.GetWebAuthnCredentialsForUser(ctx, pgtype.UUID{Bytes: userID, Valid: true})
+		if err != nil {
+			return internalError("failed to retrieve passkeys", err)
+		}
+
+		resp := make([]WebAuthnCredentialResponse, 0, len(creds))
+		for _, c := range creds {
+			resp = append(resp, newWebAuthnCredentialResponse(c))
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+		return nil
+	})
+	return err
+}
+
+func (s *Server) DeleteWebAuthnCredential(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+	userID := ctx.Value(contextkeys.UserID{}).(uuid.UUID)
+	credentialID, err := uuid.Parse(chi.URLParam(r, "credential_id"))
+	if err != nil {
+		return badRequest("invalid_passkey_id", "invalid passkey ID")
+	}
+
+	err = s.Tx.WithCtxUserTx(ctx, func(q *db.Queries) error {
+		rows, err := q.DeleteWebAuthnCredential(ctx, db.DeleteWebAuthnCredentialParams{
+			ID:     pgtype.UUID{Bytes: credentialID, Valid: true},
+			UserID: pgtype.UUID{Bytes: userID, Valid: true},
+		})
+		if err != nil {
+			return internalError("failed to delete passkey", err)
+		}
+		if rows == 0 {
+			return notFound("passkey_not_found", "passkey not found")
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	})
+	return err
+}
+
+// StartWebAuthnLogin offers the passkeys of the given email, or, without one,
+// lets the authenticator pick a discoverable passkey. Unknown emails are
+// treated like no email so the response does not tell whether an account
+// exists.
+func (s *Server) StartWebAuthnLogin(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	var req StartWebAuthnLoginRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	err := s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		var (
+			assertion *protocol.CredentialAssertion
+			session   *webauthn.SessionData
+			userID    pgtype.UUID
+			err       error
+		)
+
+		var u webauthnUser
+		if req.Email != "" {
+			user, err := q.GetUserByEmail(ctx, req.Email)
+			if err == nil {
+				u, err = loadWebAuthnUser(ctx, q, user.ID)
+			}
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return internalError("failed to start login", err)
+			}
+		}
+
+		if len(u.creds) > 0 {
+			userID = u.user.ID
+			assertion, session, err = s.WebAuthn.BeginLogin(u, webauthn.WithUserVerification(protocol.VerificationRequired))
+		} else {
+			assertion, session, err = s.WebAuthn.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+		}
+		if err != nil {
+			return internalError("failed to start login", err)
+		}
+
+		challengeID, err := saveWebAuthnChallenge(ctx, q, userID, ceremonyLogin, session)
+		if err != nil {
+			return internalError("failed to start login", err)
+		}
+
+		writeJSON(w, http.StatusOK, WebAuthnStartResponse{
+			ChallengeID: challengeID.String(),
+			Options:     assertion,
+		})
+		return nil
+	})
+	return err
+}
+
+// FinishWebAuthnLogin verifies the assertion and signs the user in. A passkey
+// is both factors in one, so there is no MFA challenge after it: the login
+// ceremony requires user verification, which ValidateLogin enforces.
+func (s *Server) FinishWebAuthnLogin(w http.ResponseWriter, r *http.Request) error {
+	ctx := r.Context()
+
+	var req FinishWebAuthnLoginRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		return err
+	}
+
+	challenge, session, err := s.takeWebAuthnChallenge(ctx, req.ChallengeID, ceremonyLogin)
+	if err != nil {
+		if errors.Is(err, errInvalidChallenge) {
+			return badRequest("invalid_challenge", err.Error())
+		}
+		return internalError("failed to finish login", err)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(req.Credential)
+	if err != nil {
+		return badRequest("invalid_credential", "invalid credential")
+	}
+
+	var alert *mail.Message
+	err = s.Tx.WithTx(ctx, func(q *db.Queries) error {
+		var (
+			u    webauthnUser
+			cred *webauthn.Credential
+			err  error
+		)
+		if challenge.UserID.Valid {
+			u, err = loadWebAuthnUser(ctx, q, challenge.UserID)
+			if err == nil {
+				cred, err = s.WebAuthn.ValidateLogin(u, session, parsed)
+			}
+		} else {
+			var user webauthn.User
+			user, cred, err = s.WebAuthn.ValidatePasskeyLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+				userID, err := uuid.FromBytes(userHandle)
+				if err != nil {
+					return nil, err
+				}
+				return loadWebAuthnUser(ctx, q, pgtype.UUID{Bytes: userID, Valid: true})
+			}, session, parsed)
+			if err == nil {
+				u = user.(webauthnUser)
+			}
+		}
+		if err != nil {
+			return unauthorized("passkey_verification_failed", "passkey verification failed")
+		}
+
+		row, ok := u.credential(cred.ID)
+		if !ok {
+			return unauthorized("passkey_verification_failed", "passkey verification failed")
+		}
+		// A counter that went backwards means a second copy of the key is in
+		// use somewhere.
+		if cred.Authenticator.CloneWarning {
+			log.Println("passkey sign count went backwards, possible clone:", row.ID)
+			return unauthorized("passkey_verification_failed", "passkey verification failed")
+		}
+
+		err = q.UseWebAuthnCredential(ctx, db.UseWebAuthnCredentialParams{
+			ID:        row.ID,
+			SignCount: int64(cred.Authenticator.SignCount),
+		})
+		if err != nil {
+			return internalError("failed to log in", err)
+		}
+
+		alert, err = s.completeLogin(u.user, w, r, q)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.sendLoginAlert(ctx, alert)
+	return nil
+}
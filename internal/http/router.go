@@ -2,54 +2,155 @@ package http
 
 import (
 	"debt-manager/internal/http/handlers"
+	"debt-manager/internal/ratelimit"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
 )
 
-func NewMux(s *handlers.Server) *chi.Mux {
+// CORS lets a frontend on another origin call the API. No origins means no
+// CORS headers at all.
+type CORS struct {
+	AllowedOrigins   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+func NewMux(s *handlers.Server, c CORS) *chi.Mux {
 	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
+	r.Use(handlers.Localize)
+	if len(c.AllowedOrigins) > 0 {
+		r.Use(cors.Handler(cors.Options{
+			AllowedOrigins:   c.AllowedOrigins,
+			AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders:   []string{"Authorization", "Content-Type", "If-Match", "If-None-Match", "X-CSRF-Token", "X-Client-Type", "X-Device-ID"},
+			ExposedHeaders:   []string{"ETag", "Retry-After"},
+			AllowCredentials: c.AllowCredentials,
+			MaxAge:           int(c.MaxAge.Seconds()),
+		}))
+	}
+
+	h := handlers.Handle
+	r.NotFound(h(s.NotFoundHandler))
 
 	// public
-	r.Post("/auth/signup", s.SignUp)
-	r.Post("/auth/login", s.Login)
-	r.Post("/auth/refresh", s.Refresh)
+	r.Get("/.well-known/jwks.json", h(s.GetJWKS))
+	r.With(s.RateLimit("signup", ratelimit.Limit{Burst: 5, Every: time.Minute})).Post("/auth/signup", h(s.SignUp))
+	r.With(s.RateLimit("login", ratelimit.Limit{Burst: 10, Every: 6 * time.Second})).Post("/auth/login", h(s.Login))
+	r.With(s.RateLimit("mfa", ratelimit.Limit{Burst: 10, Every: 6 * time.Second})).Post("/auth/mfa", h(s.CompleteMFA))
+	r.Get("/auth/oidc/providers", h(s.GetOIDCProviders))
+	r.Get("/auth/oidc/{provider}/start", h(s.StartOIDCLogin))
+	r.Get("/auth/oidc/{provider}/callback", h(s.OIDCCallback))
+	webauthnLimit := s.RateLimit("webauthn", ratelimit.Limit{Burst: 10, Every: 6 * time.Second})
+	r.With(webauthnLimit).Post("/auth/webauthn/login/start", h(s.StartWebAuthnLogin))
+	r.With(webauthnLimit).Post("/auth/webauthn/login/finish", h(s.FinishWebAuthnLogin))
+	r.With(s.RateLimit("refresh", ratelimit.Limit{Burst: 30, Every: 2 * time.Second})).Post("/auth/refresh", h(s.Refresh))
+	r.With(s.RateLimit("forgot", ratelimit.Limit{Burst: 5, Every: time.Minute})).Post("/auth/password/forgot", h(s.ForgotPassword))
+	r.With(s.RateLimit("reset", ratelimit.Limit{Burst: 10, Every: 6 * time.Second})).Post("/auth/password/reset", h(s.ResetPassword))
+	r.With(s.RateLimit("verify", ratelimit.Limit{Burst: 10, Every: 6 * time.Second})).Post("/auth/verify-email", h(s.VerifyEmail))
+	r.With(s.RateLimit("magic-link", ratelimit.Limit{Burst: 5, Every: time.Minute})).Post("/auth/magic-link", h(s.RequestMagicLink))
+	r.With(s.RateLimit("not-me", ratelimit.Limit{Burst: 10, Every: 6 * time.Second})).Post("/auth/security/not-me", h(s.RevokeAlertedSession))
+	r.With(s.RateLimit("magic-link-verify", ratelimit.Limit{Burst: 10, Every: 6 * time.Second})).Post("/auth/magic-link/verify", h(s.VerifyMagicLink))
 
 	// private
 	r.Group(func(private chi.Router){
 		private.Use(s.Auth)
 
+		// Account management needs a session; personal access tokens cannot
+		// change the account or mint more tokens.
+		private.Group(func(account chi.Router){
+			account.Use(handlers.RequireSession)
+
+			// Account
+			account.Get("/me", h(s.GetMe))
+			account.Patch("/me", h(s.UpdateMe))
+			account.Post("/me/password", h(s.ChangePassword))
+			account.Post("/me/email", h(s.ChangeEmail))
+			account.Post("/me/email/confirm", h(s.ConfirmEmailChange))
+			account.Post("/auth/verify-email/resend", h(s.ResendEmailVerification))
+
+			// Two-factor authentication
+			account.Post("/me/mfa/totp", h(s.EnrollTOTP))
+			account.Post("/me/mfa/totp/confirm", h(s.ConfirmTOTP))
+			account.Delete("/me/mfa/totp", h(s.DisableTOTP))
+
+			// Passkeys
+			account.Post("/me/webauthn/register/start", h(s.StartWebAuthnRegistration))
+			account.Post("/me/webauthn/register/finish", h(s.FinishWebAuthnRegistration))
+			account.Get("/me/webauthn/credentials", h(s.GetWebAuthnCredentials))
+			account.Delete("/me/webauthn/credentials/{credential_id}", h(s.DeleteWebAuthnCredential))
+
+			// Sessions
+			account.Post("/auth/logout", h(s.Logout))
+			account.Post("/auth/logout-all", h(s.LogoutAll))
+			account.Get("/me/sessions", h(s.GetMySessions))
+			account.Delete("/me/sessions/{session_id}", h(s.RevokeMySession))
+			account.Get("/me/security/events", h(s.GetSecurityEvents))
+
+			// Personal access tokens
+			account.Post("/me/tokens", h(s.CreatePersonalAccessToken))
+			account.Get("/me/tokens", h(s.GetPersonalAccessTokens))
+			account.Delete("/me/tokens/{token_id}", h(s.RevokePersonalAccessToken))
+		})
+
+		// Everything below is open to personal access tokens with the scope.
+		listsRead := handlers.RequireScope(handlers.ScopeListsRead)
+		listsWrite := handlers.RequireScope(handlers.ScopeListsWrite)
+		paymentsRead := handlers.RequireScope(handlers.ScopePaymentsRead)
+		paymentsWrite := handlers.RequireScope(handlers.ScopePaymentsWrite)
+
 		// Lists
-		private.Post("/lists", s.CreateList)
-		private.Get("/lists", s.GetLists)
-		private.Get("/lists/{list_id}", s.GetListByID)
-		private.Patch("/lists/{list_id}", s.UpdateList)
-		private.Delete("/lists/{list_id}", s.DeleteList)
+		private.With(listsWrite, s.RequireVerifiedEmail(handlers.ActionCreateList)).Post("/lists", h(s.CreateList))
+		private.With(listsRead).Get("/lists", h(s.GetLists))
+		private.With(listsRead).Get("/lists/{list_id}", h(s.GetListByID))
+		private.With(listsWrite).Patch("/lists/{list_id}", h(s.UpdateList))
+		private.With(listsWrite).Delete("/lists/{list_id}", h(s.DeleteList))
+		private.With(listsWrite).Post("/lists/{list_id}/close", h(s.CloseList))
+		private.With(listsWrite).Post("/lists/{list_id}/reopen", h(s.ReopenList))
+		private.With(listsRead).Get("/lists/{list_id}/settlement", h(s.GetListSettlement))
 
 		// Invitations
-		private.Post("/lists/{list_id}/invitations", s.CreateInvitation)
-		private.Get("/lists/{list_id}/invitations", s.GetAllInvitationsForList)
-		private.Get("/invitations/{hash}", s.GetInvitationByHash)
-		private.Get("/invitations/{invitation_id}", s.GetInvitationByID)
-		private.Delete(("/invitations/{invitation_id}"), s.RevokeInvitation)
+		private.With(listsWrite, s.RequireVerifiedEmail(handlers.ActionCreateInvitation)).Post("/lists/{list_id}/invitations", h(s.CreateInvitation))
+		private.With(listsRead).Get("/lists/{list_id}/invitations", h(s.GetAllInvitationsForList))
+		private.With(listsRead).Get("/invitations/{hash}", h(s.GetInvitationByHash))
+		private.With(listsRead).Get("/invitations/{invitation_id}", h(s.GetInvitationByID))
+		private.With(listsWrite).Delete(("/invitations/{invitation_id}"), h(s.RevokeInvitation))
+		private.With(listsWrite, s.RequireVerifiedEmail(handlers.ActionAcceptInvitation)).Post("/invitations/{hash}/accept", h(s.AcceptInvitation))
 
 		// Users
-		private.Get("/lists/{list_id}/users", s.GetUsersFromList)
+		private.With(listsRead).Get("/lists/{list_id}/users", h(s.GetUsersFromList))
+
+		// Members
+		private.With(listsWrite).Patch("/lists/{list_id}/members/{user_id}", h(s.UpdateMemberRole))
+		private.With(listsWrite).Delete("/lists/{list_id}/members/me", h(s.LeaveList))
+		private.With(listsWrite).Delete("/lists/{list_id}/members/{user_id}", h(s.RemoveListMember))
+		private.With(listsWrite).Post("/lists/{list_id}/owner", h(s.TransferListOwnership))
+
+		// Placeholders
+		private.With(listsWrite).Post("/lists/{list_id}/placeholders", h(s.CreatePlaceholder))
+		private.With(listsRead).Get("/lists/{list_id}/placeholders", h(s.GetPlaceholdersForList))
+		private.With(listsWrite).Delete("/lists/{list_id}/placeholders/{placeholder_id}", h(s.DeletePlaceholder))
 
 		// Payments
-		private.Post("/lists/{list_id}/payments", s.CreatePayment)
-		private.Get("/lists/{list_id}/payments", s.GetAllPaymentsForList)
-		private.Delete("/lists/{list_id}/payments/{payment_id}", s.DeletePaymentByID)
+		private.With(paymentsWrite, s.RequireVerifiedEmail(handlers.ActionCreatePayment)).Post("/lists/{list_id}/payments", h(s.CreatePayment))
+		private.With(paymentsRead).Get("/lists/{list_id}/payments", h(s.GetAllPaymentsForList))
+		private.With(paymentsWrite).Delete("/lists/{list_id}/payments/{payment_id}", h(s.DeletePaymentByID))
 
 		// Balances
-		private.Get("/lists/{list_id}/balances", s.GetNetBalances)
+		private.With(paymentsRead).Get("/lists/{list_id}/balances", h(s.GetNetBalances))
 
 		// Transactions
-		private.Get("/lists/{list_id}/transactions", s.GetSugestedTransactions)
+		private.With(paymentsRead).Get("/lists/{list_id}/transactions", h(s.GetSugestedTransactions))
 
 		// Deposits
-		private.Post("/lists/{list_id}/deposits", s.CreateDeposit)
+		private.With(paymentsWrite).Post("/lists/{list_id}/deposits", h(s.CreateDeposit))
+		private.With(paymentsRead).Get("/lists/{list_id}/deposits", h(s.GetAllDepositsForList))
+		private.With(paymentsWrite).Delete("/lists/{list_id}/deposits/{deposit_id}", h(s.DeleteDepositByID))
 	})
 
 	return r
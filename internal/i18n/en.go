@@ -0,0 +1,41 @@
+package i18n
+
+// English messages are their own keys and need no translation.
+var english = catalog{
+	validation: map[string]string{
+		"currency":         "{0} must be a supported currency",
+		"excluded_with":    "{0} cannot be given together with another field",
+		"language":         "{0} must be one of the supported languages",
+		"money":            "{0} must be an amount with at most two decimals",
+		"notblank":         "{0} is required",
+		"required_without": "{0} is required unless another field is given instead",
+		"safechars":        "{0} must not contain any of the following characters: space, /, \\, ?, %, *, :, |, \", <, >",
+		"scope":            "{0} is not a known scope",
+	},
+	mail: map[string]mail{
+		"confirm_email_change": {
+			subject: "Confirm your new email address",
+			body:    "Hi {0},\n\nconfirm this address for your account by opening:\n{1}\n\nThe link expires in 24 hours.",
+		},
+		"email_change_notice": {
+			subject: "Your email address is being changed",
+			body:    "Hi {0},\n\nsomeone asked to change the email of your account to {1}. If that was not you, change your password.",
+		},
+		"magic_link": {
+			subject: "Your login link",
+			body:    "Hi {0},\n\nyou can log in by opening:\n{1}\n\nThe link works once, only in the browser you asked for it from, and expires in 15 minutes. If you did not ask for this, you can ignore this email.",
+		},
+		"new_login": {
+			subject: "New login to your account",
+			body:    "Hi {0},\n\nyour account was just logged in to from {1} (IP address {2}).\n\nIf this was you, there is nothing to do. If it wasn't, log that device out by opening:\n{3}\n\nand change your password.",
+		},
+		"reset_password": {
+			subject: "Reset your password",
+			body:    "Hi {0},\n\nyou can choose a new password by opening:\n{1}\n\nThe link expires in one hour. If you did not ask for this, you can ignore this email.",
+		},
+		"verify_email": {
+			subject: "Verify your email address",
+			body:    "Hi {0},\n\nplease confirm this is your address by opening:\n{1}\n\nThe link expires in 48 hours.",
+		},
+	},
+}
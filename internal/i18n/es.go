@@ -0,0 +1,166 @@
+package i18n
+
+var spanish = catalog{
+	messages: map[string]string{
+		// Problem titles
+		"Bad Request":           "Solicitud incorrecta",
+		"Bad Gateway":           "Puerta de enlace incorrecta",
+		"Conflict":              "Conflicto",
+		"Forbidden":             "Prohibido",
+		"Gone":                  "Ya no disponible",
+		"Internal Server Error": "Error interno del servidor",
+		"Not Found":             "No encontrado",
+		"Payload Too Large":     "Cuerpo demasiado grande",
+		"Precondition Failed":   "Precondición fallida",
+		"Too Many Requests":     "Demasiadas solicitudes",
+		"Unauthorized":          "No autorizado",
+		"Validation Failed":     "Error de validación",
+
+		// Request bodies
+		"invalid JSON body":                                         "cuerpo JSON no válido",
+		"invalid JSON body: {0}":                                    "cuerpo JSON no válido: {0}",
+		"request body must be a JSON object":                        "el cuerpo de la solicitud debe ser un objeto JSON",
+		"request body must be a single JSON value":                  "el cuerpo de la solicitud debe ser un único valor JSON",
+		"request body must not be larger than {0} bytes":            "el cuerpo de la solicitud no puede superar los {0} bytes",
+		"the request has invalid fields":                            "la solicitud tiene campos no válidos",
+		"{0} must be of type {1}":                                   "{0} debe ser de tipo {1}",
+		"unknown field {0}":                                         "campo desconocido {0}",
+		"internal server error":                                     "error interno del servidor",
+		"endpoint not found":                                        "ruta no encontrada",
+		"too many requests":                                         "demasiadas solicitudes",
+		"limit must be between 1 and {0}":                           "el límite debe estar entre 1 y {0}",
+		"invalid deposit ID":                                        "ID de depósito no válido",
+		"invalid invitation ID":                                     "ID de invitación no válido",
+		"invalid invitation hash":                                   "código de invitación no válido",
+		"invalid list ID":                                           "ID de lista no válido",
+		"invalid passkey ID":                                        "ID de llave de acceso no válido",
+		"invalid payment ID":                                        "ID de pago no válido",
+		"invalid placeholder ID":                                    "ID de marcador no válido",
+		"invalid session ID":                                        "ID de sesión no válido",
+		"invalid token ID":                                          "ID de token no válido",
+		"invalid user ID":                                           "ID de usuario no válido",
+		"payer placeholder does not belong to this list":            "el marcador del pagador no pertenece a esta lista",
+		"division placeholder does not belong to this list":         "el marcador del reparto no pertenece a esta lista",
+		"payment amount ({0}) does not match divisions total ({1})": "el importe del pago ({0}) no coincide con el total de los repartos ({1})",
+
+		// Accounts and sessions
+		"an account with this email already exists":          "ya existe una cuenta con este correo electrónico",
+		"current password is incorrect":                      "la contraseña actual es incorrecta",
+		"email address not verified":                         "dirección de correo electrónico no verificada",
+		"email already registered":                           "el correo electrónico ya está registrado",
+		"email already verified":                             "el correo electrónico ya está verificado",
+		"invalid csrf token":                                 "token CSRF no válido",
+		"invalid email or password":                          "correo electrónico o contraseña incorrectos",
+		"invalid or expired token":                           "token no válido o caducado",
+		"invalid refresh token":                              "token de renovación no válido",
+		"invalid token":                                      "token no válido",
+		"missing or invalid access token":                    "token de acceso ausente o no válido",
+		"missing refresh token":                              "falta el token de renovación",
+		"new email is the same as the current one":           "el nuevo correo electrónico es igual al actual",
+		"not available to access tokens":                     "no disponible para tokens de acceso",
+		"open the link in the browser you requested it from": "abre el enlace en el navegador desde el que lo solicitaste",
+		"password is incorrect":                              "la contraseña es incorrecta",
+		"refresh token reused":                               "token de renovación reutilizado",
+		"refresh token revoked":                              "token de renovación revocado",
+		"session expired":                                    "sesión caducada",
+		"session not found":                                  "sesión no encontrada",
+		"session revoked":                                    "sesión revocada",
+		"token expired or already used":                      "el token ha caducado o ya se ha usado",
+		"token is not valid for this list":                   "el token no es válido para esta lista",
+		"token lacks scope {0}":                              "el token no tiene el permiso {0}",
+		"token not found":                                    "token no encontrado",
+		"not a member of list {0}":                           "no eres miembro de la lista {0}",
+		"too many failed attempts, try again later":          "demasiados intentos fallidos, inténtalo de nuevo más tarde",
+		"unauthorized":                                       "no autorizado",
+		"username already taken":                             "el nombre de usuario ya está en uso",
+		"verification email was sent recently":               "el correo de verificación se envió hace poco",
+
+		// Two-factor authentication and passkeys
+		"invalid code":                              "código no válido",
+		"invalid credential":                        "credencial no válida",
+		"invalid or expired challenge":              "desafío no válido o caducado",
+		"invalid or expired mfa token":              "token de doble factor no válido o caducado",
+		"no two-factor enrollment in progress":      "no hay ninguna activación del doble factor en curso",
+		"passkey already registered":                "la llave de acceso ya está registrada",
+		"passkey not found":                         "llave de acceso no encontrada",
+		"passkey verification failed":               "no se pudo verificar la llave de acceso",
+		"two-factor authentication already enabled": "la autenticación de doble factor ya está activada",
+		"two-factor authentication is not enabled":  "la autenticación de doble factor no está activada",
+
+		// Identity providers
+		"identity provider did not share an email address": "el proveedor de identidad no ha compartido una dirección de correo electrónico",
+		"identity provider returned an error: {0}":         "el proveedor de identidad devolvió un error: {0}",
+		"identity provider unavailable":                    "el proveedor de identidad no está disponible",
+		"invalid or expired login state":                   "estado de inicio de sesión no válido o caducado",
+		"missing login state":                              "falta el estado de inicio de sesión",
+		"sign in with identity provider failed":            "no se pudo iniciar sesión con el proveedor de identidad",
+		"unknown provider":                                 "proveedor desconocido",
+
+		// Lists and their contents
+		"deposit has been modified":                                  "el depósito ha sido modificado",
+		"deposit does not pay an outstanding settlement transaction": "el depósito no paga ninguna transacción pendiente de la liquidación",
+		"deposit not found":                                          "depósito no encontrado",
+		"invitation expired, revoked or already used":                "la invitación ha caducado, se ha revocado o ya se ha usado",
+		"invitation not found":                                       "invitación no encontrada",
+		"list has been modified":                                     "la lista ha sido modificada",
+		"list has no settlement":                                     "la lista no tiene liquidación",
+		"list is closed":                                             "la lista está cerrada",
+		"list is not closed":                                         "la lista no está cerrada",
+		"list not found":                                             "lista no encontrada",
+		"member has an outstanding balance of {0}; settle up first or pass force=true": "el miembro tiene un saldo pendiente de {0}; liquídalo primero o indica force=true",
+		"member not found": "miembro no encontrado",
+		"only admins can delete deposits entered by others":           "solo los administradores pueden eliminar depósitos registrados por otros",
+		"only admins can delete payments entered by others":           "solo los administradores pueden eliminar pagos registrados por otros",
+		"payment has been modified":                                   "el pago ha sido modificado",
+		"payment not found":                                           "pago no encontrado",
+		"placeholder cannot be claimed":                               "el marcador no se puede reclamar",
+		"placeholder does not belong to this list":                    "el marcador no pertenece a esta lista",
+		"placeholder not found":                                       "marcador no encontrado",
+		"placeholder still has payments or deposits":                  "el marcador todavía tiene pagos o depósitos",
+		"the owner cannot be removed from the list":                   "no se puede quitar al propietario de la lista",
+		"the owner has to transfer ownership before leaving":          "el propietario tiene que transferir la propiedad antes de salir",
+		"the owner's role only changes through an ownership transfer": "el rol del propietario solo cambia mediante una transferencia de propiedad",
+		"this action requires the {0} role":                           "esta acción requiere el rol {0}",
+		"use the ownership transfer to make someone owner":            "usa la transferencia de propiedad para hacer propietario a alguien",
+
+		// Mail
+		"an unknown device": "un dispositivo desconocido",
+		"{0} on {1}":        "{0} en {1}",
+	},
+	validation: map[string]string{
+		"currency":         "{0} debe ser una moneda admitida",
+		"excluded_with":    "{0} no se puede indicar junto con otro campo",
+		"language":         "{0} debe ser uno de los idiomas admitidos",
+		"money":            "{0} debe ser un importe con dos decimales como máximo",
+		"notblank":         "{0} es un campo requerido",
+		"required_without": "{0} es obligatorio salvo que se indique otro campo en su lugar",
+		"safechars":        "{0} no debe contener ninguno de los siguientes caracteres: espacio, /, \\, ?, %, *, :, |, \", <, >",
+		"scope":            "{0} no es un permiso conocido",
+	},
+	mail: map[string]mail{
+		"confirm_email_change": {
+			subject: "Confirma tu nueva dirección de correo electrónico",
+			body:    "Hola, {0}:\n\nconfirma esta dirección para tu cuenta abriendo:\n{1}\n\nEl enlace caduca en 24 horas.",
+		},
+		"email_change_notice": {
+			subject: "Se está cambiando tu dirección de correo electrónico",
+			body:    "Hola, {0}:\n\nalguien ha pedido cambiar el correo electrónico de tu cuenta a {1}. Si no has sido tú, cambia tu contraseña.",
+		},
+		"magic_link": {
+			subject: "Tu enlace de inicio de sesión",
+			body:    "Hola, {0}:\n\npuedes iniciar sesión abriendo:\n{1}\n\nEl enlace funciona una sola vez, solo en el navegador desde el que lo pediste, y caduca en 15 minutos. Si no lo has pedido tú, puedes ignorar este correo.",
+		},
+		"new_login": {
+			subject: "Nuevo inicio de sesión en tu cuenta",
+			body:    "Hola, {0}:\n\nse acaba de iniciar sesión en tu cuenta desde {1} (dirección IP {2}).\n\nSi has sido tú, no tienes que hacer nada. Si no, cierra la sesión de ese dispositivo abriendo:\n{3}\n\ny cambia tu contraseña.",
+		},
+		"reset_password": {
+			subject: "Restablece tu contraseña",
+			body:    "Hola, {0}:\n\npuedes elegir una nueva contraseña abriendo:\n{1}\n\nEl enlace caduca en una hora. Si no lo has pedido tú, puedes ignorar este correo.",
+		},
+		"verify_email": {
+			subject: "Verifica tu dirección de correo electrónico",
+			body:    "Hola, {0}:\n\nconfirma que esta es tu dirección abriendo:\n{1}\n\nEl enlace caduca en 48 horas.",
+		},
+	},
+}
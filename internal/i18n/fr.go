@@ -0,0 +1,166 @@
+package i18n
+
+var french = catalog{
+	messages: map[string]string{
+		// Problem titles
+		"Bad Request":           "Requête incorrecte",
+		"Bad Gateway":           "Passerelle incorrecte",
+		"Conflict":              "Conflit",
+		"Forbidden":             "Interdit",
+		"Gone":                  "N'est plus disponible",
+		"Internal Server Error": "Erreur interne du serveur",
+		"Not Found":             "Introuvable",
+		"Payload Too Large":     "Corps trop volumineux",
+		"Precondition Failed":   "Échec de la précondition",
+		"Too Many Requests":     "Trop de requêtes",
+		"Unauthorized":          "Non autorisé",
+		"Validation Failed":     "Échec de la validation",
+
+		// Request bodies
+		"invalid JSON body":                                         "corps JSON invalide",
+		"invalid JSON body: {0}":                                    "corps JSON invalide : {0}",
+		"request body must be a JSON object":                        "le corps de la requête doit être un objet JSON",
+		"request body must be a single JSON value":                  "le corps de la requête doit être une seule valeur JSON",
+		"request body must not be larger than {0} bytes":            "le corps de la requête ne doit pas dépasser {0} octets",
+		"the request has invalid fields":                            "la requête contient des champs invalides",
+		"{0} must be of type {1}":                                   "{0} doit être de type {1}",
+		"unknown field {0}":                                         "champ inconnu {0}",
+		"internal server error":                                     "erreur interne du serveur",
+		"endpoint not found":                                        "route introuvable",
+		"too many requests":                                         "trop de requêtes",
+		"limit must be between 1 and {0}":                           "la limite doit être comprise entre 1 et {0}",
+		"invalid deposit ID":                                        "ID de dépôt invalide",
+		"invalid invitation ID":                                     "ID d'invitation invalide",
+		"invalid invitation hash":                                   "code d'invitation invalide",
+		"invalid list ID":                                           "ID de liste invalide",
+		"invalid passkey ID":                                        "ID de clé d'accès invalide",
+		"invalid payment ID":                                        "ID de paiement invalide",
+		"invalid placeholder ID":                                    "ID de membre fictif invalide",
+		"invalid session ID":                                        "ID de session invalide",
+		"invalid token ID":                                          "ID de jeton invalide",
+		"invalid user ID":                                           "ID d'utilisateur invalide",
+		"payer placeholder does not belong to this list":            "le membre fictif payeur n'appartient pas à cette liste",
+		"division placeholder does not belong to this list":         "le membre fictif de la répartition n'appartient pas à cette liste",
+		"payment amount ({0}) does not match divisions total ({1})": "le montant du paiement ({0}) ne correspond pas au total des répartitions ({1})",
+
+		// Accounts and sessions
+		"an account with this email already exists":          "un compte avec cette adresse e-mail existe déjà",
+		"current password is incorrect":                      "le mot de passe actuel est incorrect",
+		"email address not verified":                         "adresse e-mail non vérifiée",
+		"email already registered":                           "adresse e-mail déjà enregistrée",
+		"email already verified":                             "adresse e-mail déjà vérifiée",
+		"invalid csrf token":                                 "jeton CSRF invalide",
+		"invalid email or password":                          "adresse e-mail ou mot de passe incorrect",
+		"invalid or expired token":                           "jeton invalide ou expiré",
+		"invalid refresh token":                              "jeton de renouvellement invalide",
+		"invalid token":                                      "jeton invalide",
+		"missing or invalid access token":                    "jeton d'accès manquant ou invalide",
+		"missing refresh token":                              "jeton de renouvellement manquant",
+		"new email is the same as the current one":           "la nouvelle adresse e-mail est identique à l'actuelle",
+		"not available to access tokens":                     "non disponible pour les jetons d'accès",
+		"open the link in the browser you requested it from": "ouvrez le lien dans le navigateur depuis lequel vous l'avez demandé",
+		"password is incorrect":                              "le mot de passe est incorrect",
+		"refresh token reused":                               "jeton de renouvellement réutilisé",
+		"refresh token revoked":                              "jeton de renouvellement révoqué",
+		"session expired":                                    "session expirée",
+		"session not found":                                  "session introuvable",
+		"session revoked":                                    "session révoquée",
+		"token expired or already used":                      "le jeton a expiré ou a déjà été utilisé",
+		"token is not valid for this list":                   "le jeton n'est pas valable pour cette liste",
+		"token lacks scope {0}":                              "le jeton n'a pas la portée {0}",
+		"token not found":                                    "jeton introuvable",
+		"not a member of list {0}":                           "vous n'êtes pas membre de la liste {0}",
+		"too many failed attempts, try again later":          "trop de tentatives échouées, réessayez plus tard",
+		"unauthorized":                                       "non autorisé",
+		"username already taken":                             "nom d'utilisateur déjà pris",
+		"verification email was sent recently":               "l'e-mail de vérification a été envoyé récemment",
+
+		// Two-factor authentication and passkeys
+		"invalid code":                              "code invalide",
+		"invalid credential":                        "identifiant invalide",
+		"invalid or expired challenge":              "défi invalide ou expiré",
+		"invalid or expired mfa token":              "jeton de double authentification invalide ou expiré",
+		"no two-factor enrollment in progress":      "aucune activation de la double authentification en cours",
+		"passkey already registered":                "clé d'accès déjà enregistrée",
+		"passkey not found":                         "clé d'accès introuvable",
+		"passkey verification failed":               "échec de la vérification de la clé d'accès",
+		"two-factor authentication already enabled": "la double authentification est déjà activée",
+		"two-factor authentication is not enabled":  "la double authentification n'est pas activée",
+
+		// Identity providers
+		"identity provider did not share an email address": "le fournisseur d'identité n'a pas partagé d'adresse e-mail",
+		"identity provider returned an error: {0}":         "le fournisseur d'identité a renvoyé une erreur : {0}",
+		"identity provider unavailable":                    "fournisseur d'identité indisponible",
+		"invalid or expired login state":                   "état de connexion invalide ou expiré",
+		"missing login state":                              "état de connexion manquant",
+		"sign in with identity provider failed":            "échec de la connexion avec le fournisseur d'identité",
+		"unknown provider":                                 "fournisseur inconnu",
+
+		// Lists and their contents
+		"deposit has been modified":                                  "le dépôt a été modifié",
+		"deposit does not pay an outstanding settlement transaction": "le dépôt ne règle aucune transaction restante du règlement",
+		"deposit not found":                                          "dépôt introuvable",
+		"invitation expired, revoked or already used":                "l'invitation a expiré, a été révoquée ou a déjà été utilisée",
+		"invitation not found":                                       "invitation introuvable",
+		"list has been modified":                                     "la liste a été modifiée",
+		"list has no settlement":                                     "la liste n'a pas de règlement",
+		"list is closed":                                             "la liste est fermée",
+		"list is not closed":                                         "la liste n'est pas fermée",
+		"list not found":                                             "liste introuvable",
+		"member has an outstanding balance of {0}; settle up first or pass force=true": "le membre a un solde restant de {0} ; réglez-le d'abord ou passez force=true",
+		"member not found": "membre introuvable",
+		"only admins can delete deposits entered by others":           "seuls les administrateurs peuvent supprimer les dépôts saisis par d'autres",
+		"only admins can delete payments entered by others":           "seuls les administrateurs peuvent supprimer les paiements saisis par d'autres",
+		"payment has been modified":                                   "le paiement a été modifié",
+		"payment not found":                                           "paiement introuvable",
+		"placeholder cannot be claimed":                               "le membre fictif ne peut pas être réclamé",
+		"placeholder does not belong to this list":                    "le membre fictif n'appartient pas à cette liste",
+		"placeholder not found":                                       "membre fictif introuvable",
+		"placeholder still has payments or deposits":                  "le membre fictif a encore des paiements ou des dépôts",
+		"the owner cannot be removed from the list":                   "le propriétaire ne peut pas être retiré de la liste",
+		"the owner has to transfer ownership before leaving":          "le propriétaire doit transférer la propriété avant de partir",
+		"the owner's role only changes through an ownership transfer": "le rôle du propriétaire ne change que par un transfert de propriété",
+		"this action requires the {0} role":                           "cette action nécessite le rôle {0}",
+		"use the ownership transfer to make someone owner":            "utilisez le transfert de propriété pour rendre quelqu'un propriétaire",
+
+		// Mail
+		"an unknown device": "un appareil inconnu",
+		"{0} on {1}":        "{0} sur {1}",
+	},
+	validation: map[string]string{
+		"currency":         "{0} doit être une devise prise en charge",
+		"excluded_with":    "{0} ne peut pas être indiqué avec un autre champ",
+		"language":         "{0} doit être l'une des langues prises en charge",
+		"money":            "{0} doit être un montant avec au plus deux décimales",
+		"notblank":         "{0} est un champ obligatoire",
+		"required_without": "{0} est obligatoire sauf si un autre champ est indiqué à sa place",
+		"safechars":        "{0} ne doit contenir aucun des caractères suivants : espace, /, \\, ?, %, *, :, |, \", <, >",
+		"scope":            "{0} n'est pas une portée connue",
+	},
+	mail: map[string]mail{
+		"confirm_email_change": {
+			subject: "Confirmez votre nouvelle adresse e-mail",
+			body:    "Bonjour {0},\n\nconfirmez cette adresse pour votre compte en ouvrant :\n{1}\n\nLe lien expire dans 24 heures.",
+		},
+		"email_change_notice": {
+			subject: "Votre adresse e-mail est en cours de modification",
+			body:    "Bonjour {0},\n\nquelqu'un a demandé à remplacer l'adresse e-mail de votre compte par {1}. Si ce n'était pas vous, changez votre mot de passe.",
+		},
+		"magic_link": {
+			subject: "Votre lien de connexion",
+			body:    "Bonjour {0},\n\nvous pouvez vous connecter en ouvrant :\n{1}\n\nLe lien ne fonctionne qu'une fois, uniquement dans le navigateur depuis lequel vous l'avez demandé, et expire dans 15 minutes. Si vous ne l'avez pas demandé, vous pouvez ignorer cet e-mail.",
+		},
+		"new_login": {
+			subject: "Nouvelle connexion à votre compte",
+			body:    "Bonjour {0},\n\nune connexion à votre compte vient d'avoir lieu depuis {1} (adresse IP {2}).\n\nSi c'était vous, vous n'avez rien à faire. Sinon, déconnectez cet appareil en ouvrant :\n{3}\n\net changez votre mot de passe.",
+		},
+		"reset_password": {
+			subject: "Réinitialisez votre mot de passe",
+			body:    "Bonjour {0},\n\nvous pouvez choisir un nouveau mot de passe en ouvrant :\n{1}\n\nLe lien expire dans une heure. Si vous ne l'avez pas demandé, vous pouvez ignorer cet e-mail.",
+		},
+		"verify_email": {
+			subject: "Vérifiez votre adresse e-mail",
+			body:    "Bonjour {0},\n\nconfirmez qu'il s'agit bien de votre adresse en ouvrant :\n{1}\n\nLe lien expire dans 48 heures.",
+		},
+	},
+}
@@ -0,0 +1,150 @@
+// Package i18n translates what the API says to people: problem details,
+// validation errors and mail. Messages are written in English in the code and
+// the English text is the key of their translations, so a message without a
+// translation still comes out, in English.
+package i18n
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	ut "github.com/go-playground/universal-translator"
+	"golang.org/x/text/language"
+)
+
+const (
+	English = "en"
+	Spanish = "es"
+	French  = "fr"
+)
+
+// Languages are the languages the API speaks, the default first.
+var Languages = []string{English, Spanish, French}
+
+// message keeps the keys of the catalogs apart from the validate tags the
+// validator registers with the same translators.
+type message string
+
+var (
+	uni     = newUniversalTranslator()
+	matcher = newMatcher()
+)
+
+func newUniversalTranslator() *ut.UniversalTranslator {
+	uni := ut.New(en.New(), en.New(), es.New(), fr.New())
+	for lang, c := range catalogs {
+		trans, _ := uni.GetTranslator(lang)
+		if err := c.register(trans); err != nil {
+			panic(err)
+		}
+	}
+	return uni
+}
+
+func newMatcher() language.Matcher {
+	tags := make([]language.Tag, len(Languages))
+	for i, lang := range Languages {
+		tags[i] = language.MustParse(lang)
+	}
+	return language.NewMatcher(tags)
+}
+
+// Supported reports whether lang is one of Languages.
+func Supported(lang string) bool {
+	_, ok := catalogs[lang]
+	return ok
+}
+
+// Translator returns the translator of lang, or the English one when lang is
+// not supported.
+func Translator(lang string) ut.Translator {
+	trans, ok := uni.GetTranslator(lang)
+	if !ok {
+		return uni.GetFallback()
+	}
+	return trans
+}
+
+// Negotiate picks the language that best suits an Accept-Language header. ok
+// is false when the header asks for none of Languages.
+func Negotiate(acceptLanguage string) (lang string, ok bool) {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return English, false
+	}
+	_, i, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return English, false
+	}
+	return Languages[i], true
+}
+
+// Message translates an English message into lang and fills its {0}-style
+// placeholders with params.
+func Message(lang, msg string, params ...string) string {
+	if lang != English {
+		if s, err := Translator(lang).T(message(msg), pad(msg, params)...); err == nil {
+			return s
+		}
+	}
+	return fill(msg, params)
+}
+
+// Mail renders the subject and body of the named mail in lang, with params
+// in the {0}-style placeholders of the body.
+func Mail(lang, name string, params ...string) (subject, body string) {
+	m, ok := catalogs[lang].mail[name]
+	if !ok {
+		m = catalogs[English].mail[name]
+	}
+	return m.subject, fill(m.body, params)
+}
+
+// pad makes sure there is a param for every placeholder, as the translators
+// do not check and would panic.
+func pad(text string, params []string) []string {
+	n := strings.Count(text, "{")
+	for len(params) < n {
+		params = append(params, "")
+	}
+	return params
+}
+
+func fill(text string, params []string) string {
+	for i, p := range params {
+		text = strings.Replace(text, "{"+strconv.Itoa(i)+"}", p, 1)
+	}
+	return text
+}
+
+// A catalog holds the translations of a language.
+type catalog struct {
+	// messages translates English messages.
+	messages map[string]string
+	// validation holds messages for validate tags, by tag, with the field in
+	// {0} and the tag's parameter in {1}.
+	validation map[string]string
+	mail       map[string]mail
+}
+
+type mail struct {
+	subject, body string
+}
+
+var catalogs = map[string]catalog{
+	English: english,
+	Spanish: spanish,
+	French:  french,
+}
+
+func (c catalog) register(trans ut.Translator) error {
+	for msg, text := range c.messages {
+		if err := trans.Add(message(msg), text, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package i18n
+
+import (
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+)
+
+var defaultTranslations = map[string]func(*validator.Validate, ut.Translator) error{
+	English: en_translations.RegisterDefaultTranslations,
+	Spanish: es_translations.RegisterDefaultTranslations,
+	French:  fr_translations.RegisterDefaultTranslations,
+}
+
+// RegisterValidationTranslations teaches v to describe its errors in every
+// language, with FieldError.Translate and the language's Translator. The
+// validator's own messages cover the built-in tags; the catalogs add custom
+// tags and the built-in ones a language lacks.
+func RegisterValidationTranslations(v *validator.Validate) error {
+	for _, lang := range Languages {
+		trans := Translator(lang)
+		if err := defaultTranslations[lang](v, trans); err != nil {
+			return err
+		}
+		for tag, text := range catalogs[lang].validation {
+			err := v.RegisterTranslation(tag, trans, func(trans ut.Translator) error {
+				return trans.Add(tag, text, true)
+			}, translateFieldError)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func translateFieldError(trans ut.Translator, fe validator.FieldError) string {
+	s, err := trans.T(fe.Tag(), fe.Field(), fe.Param())
+	if err != nil {
+		return fe.Error()
+	}
+	return s
+}
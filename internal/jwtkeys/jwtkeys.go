@@ -0,0 +1,230 @@
+// Package jwtkeys holds the keys tokens are signed and verified with. Keys are
+// PEM files in a directory, named after their key ID; every key in the
+// directory verifies, and the private key with the greatest ID signs unless
+// one is pinned. Naming keys by date makes rotation a matter of adding a new
+// file and reloading, then deleting the old one once the tokens it signed
+// have expired.
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Key is one key of a Set. Keys loaded from a public key file have no private
+// half and can only verify.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private any
+	public  any
+}
+
+// Config says where a Set is loaded from. HMACSecret is the shared secret
+// tokens were signed with before asymmetric keys; it verifies tokens without
+// a kid header, and signs when there is no Dir.
+type Config struct {
+	Dir          string
+	SigningKeyID string
+	HMACSecret   []byte
+}
+
+type Set struct {
+	config Config
+
+	mu      sync.RWMutex
+	signing *Key
+	keys    map[string]*Key
+}
+
+func Load(config Config) (*Set, error) {
+	s := &Set{config: config}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the keys again. On error the keys loaded before stay in use.
+func (s *Set) Reload() error {
+	keys := map[string]*Key{}
+	if len(s.config.HMACSecret) > 0 {
+		keys[""] = &Key{
+			Method:  jwt.SigningMethodHS256,
+			private: s.config.HMACSecret,
+			public:  s.config.HMACSecret,
+		}
+	}
+
+	if s.config.Dir == "" {
+		signing, ok := keys[""]
+		if !ok {
+			return errors.New("no signing key: set a key directory or a secret")
+		}
+		s.swap(signing, keys)
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(s.config.Dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	var private []*Key
+	for _, file := range files {
+		key, err := loadKey(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		keys[key.ID] = key
+		if key.private != nil {
+			private = append(private, key)
+		}
+	}
+
+	var signing *Key
+	switch {
+	case s.config.SigningKeyID != "":
+		signing = keys[s.config.SigningKeyID]
+		if signing == nil || signing.ID == "" || signing.private == nil {
+			return fmt.Errorf("signing key %q not found in %s", s.config.SigningKeyID, s.config.Dir)
+		}
+	case len(private) > 0:
+		signing = private[0]
+		for _, key := range private[1:] {
+			if key.ID > signing.ID {
+				signing = key
+			}
+		}
+	default:
+		return fmt.Errorf("no private key in %s", s.config.Dir)
+	}
+
+	s.swap(signing, keys)
+	return nil
+}
+
+func (s *Set) swap(signing *Key, keys map[string]*Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.signing = signing
+	s.keys = keys
+}
+
+// loadKey reads a PKCS#8 or PKCS#1 private key, or a PKIX public key. The
+// file name without .pem is the key ID.
+func loadKey(file string) (*Key, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+
+	key := &Key{ID: strings.TrimSuffix(filepath.Base(file), ".pem")}
+	if key.ID == "" {
+		return nil, errors.New("empty key ID")
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		key.private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key.private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key.public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.private.(type) {
+	case ed25519.PrivateKey:
+		key.public = k.Public()
+	case *rsa.PrivateKey:
+		key.public = k.Public()
+	case nil:
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", k)
+	}
+
+	switch key.public.(type) {
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key.public)
+	}
+	return key, nil
+}
+
+// Sign signs the claims with the current signing key, naming it in the kid
+// header.
+func (s *Set) Sign(claims jwt.Claims) (string, error) {
+	s.mu.RLock()
+	key := s.signing
+	s.mu.RUnlock()
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.private)
+}
+
+// Parse verifies a token against the key named in its kid header. The key
+// decides the algorithm, so a token cannot pick a weaker one.
+func (s *Set) Parse(tokenStr string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		s.mu.RLock()
+		key, ok := s.keys[kid]
+		s.mu.RUnlock()
+
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("key %q does not sign with %s", kid, token.Method.Alg())
+		}
+		return key.public, nil
+	})
+}
+
+// JWKS returns the public keys for other services to verify our tokens with.
+// The shared secret is never published.
+func (s *Set) JWKS() jose.JSONWebKeySet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{}}
+	for _, key := range s.keys {
+		if key.ID == "" {
+			continue
+		}
+		set.Keys = append(set.Keys, jose.JSONWebKey{
+			Key:       key.public,
+			KeyID:     key.ID,
+			Algorithm: key.Method.Alg(),
+			Use:       "sig",
+		})
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
@@ -0,0 +1,215 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var secret = []byte("test-secret")
+
+// writeKeyDir writes an Ed25519 private key for every ID in ed, an RSA private
+// key for every ID in rs and the public half of an Ed25519 key for every ID
+// in pub.
+func writeKeyDir(t *testing.T, ed, rs, pub []string) string {
+	t.Helper()
+	dir := t.TempDir()
+	write := func(id, typ string, der []byte) {
+		data := pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der})
+		if err := os.WriteFile(filepath.Join(dir, id+".pem"), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range ed {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(id, "PRIVATE KEY", der)
+	}
+	for _, id := range rs {
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(id, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(priv))
+	}
+	for _, id := range pub {
+		pubKey, _, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(pubKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(id, "PUBLIC KEY", der)
+	}
+	return dir
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "user",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		ed, rs  []string
+		pub     []string
+		pinned  string
+		want    string
+		wantAlg string
+		wantErr bool
+	}{
+		{name: "greatest ID signs", ed: []string{"2024-01", "2025-06"}, rs: []string{"2025-01"}, want: "2025-06", wantAlg: "EdDSA"},
+		{name: "RSA key", rs: []string{"2025-01"}, want: "2025-01", wantAlg: "RS256"},
+		{name: "public keys do not sign", ed: []string{"2024-01"}, pub: []string{"2099-01"}, want: "2024-01", wantAlg: "EdDSA"},
+		{name: "pinned key", ed: []string{"2024-01", "2025-06"}, pinned: "2024-01", want: "2024-01", wantAlg: "EdDSA"},
+		{name: "pinned key missing", ed: []string{"2024-01"}, pinned: "2023-01", wantErr: true},
+		{name: "pinned public key", ed: []string{"2024-01"}, pub: []string{"2025-01"}, pinned: "2025-01", wantErr: true},
+		{name: "no private key", pub: []string{"2025-01"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeKeyDir(t, tt.ed, tt.rs, tt.pub)
+			set, err := Load(Config{Dir: dir, SigningKeyID: tt.pinned})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Load succeeded; want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if set.signing.ID != tt.want || set.signing.Method.Alg() != tt.wantAlg {
+				t.Errorf("signing key = %s (%s); want %s (%s)", set.signing.ID, set.signing.Method.Alg(), tt.want, tt.wantAlg)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	dir := writeKeyDir(t, []string{"ed"}, []string{"rsa"}, nil)
+	set, err := Load(Config{Dir: dir, HMACSecret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, err := x509.MarshalPKIXPublicKey(set.keys["ed"].public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := set.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sign makes a token with the given kid, algorithm and key, as an
+	// attacker could.
+	sign := func(kid string, method jwt.SigningMethod, key any) string {
+		token := jwt.NewWithClaims(method, testClaims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "signed by the set", token: signed},
+		{name: "rsa key", token: sign("rsa", jwt.SigningMethodRS256, set.keys["rsa"].private)},
+		{name: "legacy secret without kid", token: sign("", jwt.SigningMethodHS256, secret)},
+		{name: "unknown kid", token: sign("other", jwt.SigningMethodHS256, secret), wantErr: true},
+		{name: "alg does not match kid", token: sign("rsa", jwt.SigningMethodEdDSA, set.keys["ed"].private), wantErr: true},
+		{name: "other alg of the same key", token: sign("rsa", jwt.SigningMethodPS256, set.keys["rsa"].private), wantErr: true},
+		{name: "HMAC with a public key", token: sign("ed", jwt.SigningMethodHS256, edPublic), wantErr: true},
+		{name: "none", token: sign("ed", jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType), wantErr: true},
+		{name: "wrong secret", token: sign("", jwt.SigningMethodHS256, []byte("other")), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := set.Parse(tt.token, &jwt.RegisteredClaims{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse error = %v; want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseWithoutSecret(t *testing.T) {
+	dir := writeKeyDir(t, []string{"ed"}, nil, nil)
+	set, err := Load(Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims()).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := set.Parse(token, &jwt.RegisteredClaims{}); err == nil {
+		t.Error("token without kid verified; want an error when there is no secret")
+	}
+}
+
+func TestJWKSLeavesOutSecret(t *testing.T) {
+	dir := writeKeyDir(t, []string{"ed"}, []string{"rsa"}, []string{"old"})
+	set, err := Load(Config{Dir: dir, HMACSecret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := set.JWKS()
+	var ids []string
+	for _, key := range jwks.Keys {
+		if !key.IsPublic() {
+			t.Errorf("key %s is not public", key.KeyID)
+		}
+		ids = append(ids, key.KeyID)
+	}
+	if len(ids) != 3 || ids[0] != "ed" || ids[1] != "old" || ids[2] != "rsa" {
+		t.Errorf("JWKS key IDs = %v; want [ed old rsa]", ids)
+	}
+}
+
+func TestReloadKeepsKeysOnError(t *testing.T) {
+	dir := writeKeyDir(t, []string{"2024-01"}, nil, nil)
+	set, err := Load(Config{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "2025-01.pem"), []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := set.Reload(); err == nil {
+		t.Fatal("Reload succeeded; want an error for the broken file")
+	}
+	if set.signing.ID != "2024-01" {
+		t.Errorf("signing key = %s after a failed reload; want 2024-01", set.signing.ID)
+	}
+}
@@ -0,0 +1,139 @@
+// Package maintenance deletes rows that are of no use anymore: expired or
+// revoked sessions with their refresh token chains, stale invitations, spent
+// account tokens, old login history and idle rate limit state. Totals are
+// published through expvar under "maintenance".
+package maintenance
+
+import (
+	"context"
+	"debt-manager/internal/db"
+	"expvar"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type Config struct {
+	// Retention is how long rows are kept after they expired, were revoked
+	// or were used.
+	Retention time.Duration
+	// RateLimitIdle is how long rate limit state is kept after the last hit.
+	// It must not be shorter than the lockout window.
+	RateLimitIdle time.Duration
+	Interval      time.Duration
+	BatchSize     int32
+}
+
+var DefaultConfig = Config{
+	Retention:     30 * 24 * time.Hour,
+	RateLimitIdle: 24 * time.Hour,
+	Interval:      time.Hour,
+	BatchSize:     1000,
+}
+
+var metrics = expvar.NewMap("maintenance")
+
+// Result is the number of rows purged per task.
+type Result map[string]int64
+
+type task struct {
+	name  string
+	purge func(ctx context.Context, q *db.Queries, before pgtype.Timestamptz, limit int32) (int64, error)
+	idle  bool
+}
+
+var tasks = []task{
+	{name: "sessions", purge: func(ctx context.Context, q *db.Queries, before pgtype.Timestamptz, limit int32) (int64, error) {
+		return q.PurgeSessions(ctx, db.PurgeSessionsParams{Before: before, Limit: limit})
+	}},
+	{name: "refresh_tokens", purge: func(ctx context.Context, q *db.Queries, before pgtype.Timestamptz, limit int32) (int64, error) {
+		return q.PurgeRefreshTokens(ctx, db.PurgeRefreshTokensParams{Before: before, Limit: limit})
+	}},
+	{name: "invitations", purge: func(ctx context.Context, q *db.Queries, before pgtype.Timestamptz, limit int32) (int64, error) {
+		return q.PurgeInvitations(ctx, db.PurgeInvitationsParams{Before: before, Limit: limit})
+	}},
+	{name: "account_tokens", purge: func(ctx context.Context, q *db.Queries, before pgtype.Timestamptz, limit int32) (int64, error) {
+		return q.PurgeAccountTokens(ctx, db.PurgeAccountTokensParams{Before: before, Limit: limit})
+	}},
+	{name: "personal_access_tokens", purge: func(ctx context.Context, q *db.Queries, before pgtype.Timestamptz, limit int32) (int64, error) {
+		return q.PurgePersonalAccessTokens(ctx, db.PurgePersonalAccessTokensParams{Before: before, Limit: limit})
+	}},
+	{name: "security_events", purge: func(ctx context.Context, q *db.Queries, before pgtype.Timestamptz, limit int32) (int64, error) {
+		return q.PurgeSecurityEvents(ctx, db.PurgeSecurityEventsParams{Before: before, Limit: limit})
+	}},
+	{name: "rate_limits", idle: true, purge: func(ctx context.Context, q *db.Queries, before pgtype.Timestamptz, limit int32) (int64, error) {
+		return q.PurgeRateLimits(ctx, db.PurgeRateLimitsParams{Before: before, Limit: limit})
+	}},
+}
+
+type Worker struct {
+	Tx     *db.TxRunner
+	Config Config
+}
+
+// RunOnce purges every task in batches of Config.BatchSize, each batch in its
+// own transaction so no lock is held for long. It stops at the first error
+// and returns what was purged until then.
+func (w *Worker) RunOnce(ctx context.Context) (Result, error) {
+	res := Result{}
+	now := time.Now()
+
+	for _, t := range tasks {
+		cutoff := now.Add(-w.Config.Retention)
+		if t.idle {
+			cutoff = now.Add(-w.Config.RateLimitIdle)
+		}
+		before := pgtype.Timestamptz{Time: cutoff, Valid: true}
+
+		for {
+			var n int64
+			err := w.Tx.WithTx(ctx, func(q *db.Queries) error {
+				var err error
+				n, err = t.purge(ctx, q, before, w.Config.BatchSize)
+				return err
+			})
+			if err != nil {
+				metrics.Add("errors", 1)
+				return res, err
+			}
+
+			res[t.name] += n
+			metrics.Add("purged_"+t.name, n)
+			if n == 0 || n < int64(w.Config.BatchSize) {
+				break
+			}
+		}
+	}
+
+	metrics.Add("runs", 1)
+	metrics.Set("last_run", timeVar(now))
+	return res, nil
+}
+
+// Run calls RunOnce every Config.Interval until ctx is done.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Config.Interval)
+	defer ticker.Stop()
+
+	for {
+		res, err := w.RunOnce(ctx)
+		if err != nil {
+			log.Println("maintenance failed:", err)
+		} else {
+			log.Println("maintenance purged:", res)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type timeVar time.Time
+
+func (t timeVar) String() string {
+	return `"` + time.Time(t).Format("2006-01-02T15:04:05Z07:00") + `"`
+}
@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pruneEvery is how many calls pass between sweeps of stale entries.
+const pruneEvery = 1000
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+type failure struct {
+	count   int
+	last    time.Time
+	expires time.Time
+}
+
+// MemoryStore keeps limits in the process. It is fine for a single instance;
+// with several behind a load balancer each one counts on its own.
+type MemoryStore struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	failures map[string]*failure
+	calls    int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:  map[string]*bucket{},
+		failures: map[string]*failure{},
+	}
+}
+
+func (m *MemoryStore) Take(ctx context.Context, key string, limit Limit) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.prune(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.limit = limit
+	b.tokens += float64(now.Sub(b.updated)) / float64(limit.Every)
+	if b.tokens > float64(limit.Burst) {
+		b.tokens = float64(limit.Burst)
+	}
+	b.updated = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, nil
+	}
+	return time.Duration((1 - b.tokens) * float64(limit.Every)), nil
+}
+
+func (m *MemoryStore) AddFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	f, ok := m.failures[key]
+	if !ok || now.After(f.expires) {
+		f = &failure{}
+		m.failures[key] = f
+	}
+	f.count++
+	f.last = now
+	f.expires = now.Add(window)
+	return f.count, nil
+}
+
+func (m *MemoryStore) Failures(ctx context.Context, key string) (int, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.failures[key]
+	if !ok {
+		return 0, time.Time{}, nil
+	}
+	return f.count, f.last, nil
+}
+
+func (m *MemoryStore) ClearFailures(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.failures, key)
+	return nil
+}
+
+// prune drops buckets that have refilled completely and failures that have
+// expired, which is the same as not having them. Callers hold m.mu.
+func (m *MemoryStore) prune(now time.Time) {
+	m.calls++
+	if m.calls%pruneEvery != 0 {
+		return
+	}
+	for key, b := range m.buckets {
+		if now.Sub(b.updated) >= time.Duration(b.limit.Burst)*b.limit.Every {
+			delete(m.buckets, key)
+		}
+	}
+	for key, f := range m.failures {
+		if now.After(f.expires) {
+			delete(m.failures, key)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// age moves the key's bucket and failures into the past, as if d had passed.
+func (m *MemoryStore) age(key string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if b, ok := m.buckets[key]; ok {
+		b.updated = b.updated.Add(-d)
+	}
+	if f, ok := m.failures[key]; ok {
+		f.last = f.last.Add(-d)
+		f.expires = f.expires.Add(-d)
+	}
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	limit := Limit{Burst: 3, Every: time.Minute}
+
+	tests := []struct {
+		name    string
+		taken   int
+		elapsed time.Duration
+		wantOK  bool
+	}{
+		{name: "burst available", taken: 2, wantOK: true},
+		{name: "burst exhausted", taken: 3, wantOK: false},
+		{name: "partial refill", taken: 3, elapsed: 30 * time.Second, wantOK: false},
+		{name: "one token refilled", taken: 3, elapsed: time.Minute, wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := NewMemoryStore()
+			for i := 0; i < tt.taken; i++ {
+				if wait, err := m.Take(ctx, "k", limit); err != nil || wait != 0 {
+					t.Fatalf("Take %d = %v, %v; want 0, nil", i, wait, err)
+				}
+			}
+			m.age("k", tt.elapsed)
+
+			wait, err := m.Take(ctx, "k", limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := wait == 0; got != tt.wantOK {
+				t.Errorf("Take after %v waits %v; want allowed %v", tt.elapsed, wait, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreTakeWait(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	limit := Limit{Burst: 1, Every: time.Minute}
+
+	m.Take(ctx, "k", limit)
+	m.age("k", 15*time.Second)
+	wait, err := m.Take(ctx, "k", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait < 44*time.Second || wait > 45*time.Second {
+		t.Errorf("wait = %v; want about 45s", wait)
+	}
+}
+
+func TestMemoryStoreTakeRefillCap(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	limit := Limit{Burst: 2, Every: time.Minute}
+
+	m.Take(ctx, "k", limit)
+	m.age("k", time.Hour)
+	for i := 0; i < limit.Burst; i++ {
+		if wait, _ := m.Take(ctx, "k", limit); wait != 0 {
+			t.Fatalf("Take %d waits %v; want a token", i, wait)
+		}
+	}
+	if wait, _ := m.Take(ctx, "k", limit); wait == 0 {
+		t.Error("Take past the burst got a token; the refill should be capped at the burst")
+	}
+}
+
+func TestMemoryStoreKeysAreSeparate(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	limit := Limit{Burst: 1, Every: time.Minute}
+
+	m.Take(ctx, "a", limit)
+	if wait, _ := m.Take(ctx, "b", limit); wait != 0 {
+		t.Errorf("Take on another key waits %v; want a token", wait)
+	}
+}
+
+func TestMemoryStoreAddFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    int
+	}{
+		{name: "within window", elapsed: time.Minute, want: 3},
+		{name: "window passed", elapsed: time.Hour + time.Second, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			m := NewMemoryStore()
+			m.AddFailure(ctx, "k", time.Hour)
+			m.AddFailure(ctx, "k", time.Hour)
+			m.age("k", tt.elapsed)
+
+			got, err := m.AddFailure(ctx, "k", time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("AddFailure = %d; want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"debt-manager/internal/db"
+	"errors"
+	"time"
+)
+
+// PostgresStore keeps limits in the database so that every API instance sees
+// the same buckets and failures.
+type PostgresStore struct {
+	Tx *db.TxRunner
+}
+
+func (p PostgresStore) Take(ctx context.Context, key string, limit Limit) (time.Duration, error) {
+	var wait float64
+	err := p.Tx.WithTx(ctx, func(q *db.Queries) error {
+		var err error
+		wait, err = q.TakeRateLimitToken(ctx, db.TakeRateLimitTokenParams{
+			Key:             key,
+			Burst:           int32(limit.Burst),
+			IntervalSeconds: limit.Every.Seconds(),
+		})
+		return err
+	})
+	return time.Duration(wait * float64(time.Second)), err
+}
+
+func (p PostgresStore) AddFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	var failures int32
+	err := p.Tx.WithTx(ctx, func(q *db.Queries) error {
+		var err error
+		failures, err = q.AddRateLimitFailure(ctx, db.AddRateLimitFailureParams{
+			Key:           key,
+			WindowSeconds: window.Seconds(),
+		})
+		return err
+	})
+	return int(failures), err
+}
+
+func (p PostgresStore) Failures(ctx context.Context, key string) (int, time.Time, error) {
+	var row db.GetRateLimitFailuresRow
+	err := p.Tx.WithTx(ctx, func(q *db.Queries) error {
+		var err error
+		row, err = q.GetRateLimitFailures(ctx, key)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, time.Time{}, nil
+	}
+	return int(row.Failures), row.LastFailureAt.Time, err
+}
+
+func (p PostgresStore) ClearFailures(ctx context.Context, key string) error {
+	return p.Tx.WithTx(ctx, func(q *db.Queries) error {
+		return q.ClearRateLimitFailures(ctx, key)
+	})
+}
@@ -0,0 +1,95 @@
+// Package ratelimit throttles requests with token buckets and locks keys out
+// after repeated failures. Keys are free-form strings such as "login:ip:1.2.3.4";
+// the Store decides where the state lives.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit is a token bucket: up to Burst requests at once, refilled at one token
+// every Every.
+type Limit struct {
+	Burst int
+	Every time.Duration
+}
+
+// Lockout locks a key after Threshold failures in a row, first for Base and
+// then twice as long for every further failure, up to Max. Failures older
+// than Window are forgotten.
+type Lockout struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+	Window    time.Duration
+}
+
+// duration is how long a key with the given number of failures stays locked
+// after the last one.
+func (l Lockout) duration(failures int) time.Duration {
+	if failures < l.Threshold {
+		return 0
+	}
+	d := l.Base
+	for i := l.Threshold; i < failures && d < l.Max; i++ {
+		d *= 2
+	}
+	if d > l.Max {
+		d = l.Max
+	}
+	return d
+}
+
+type Store interface {
+	// Take takes a token from the key's bucket. It returns zero if there was
+	// one, otherwise how long until there will be.
+	Take(ctx context.Context, key string, limit Limit) (time.Duration, error)
+	// AddFailure records a failure and returns the number of failures in a
+	// row, starting over if the previous one is older than window.
+	AddFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	// Failures returns the failures in a row and when the last one happened.
+	Failures(ctx context.Context, key string) (int, time.Time, error)
+	ClearFailures(ctx context.Context, key string) error
+}
+
+type Limiter struct {
+	Store   Store
+	Lockout Lockout
+}
+
+// Allow reports how long the caller has to wait before the key may be used
+// again; zero means go ahead.
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (time.Duration, error) {
+	return l.Store.Take(ctx, key, limit)
+}
+
+// LockedFor returns how much longer the key is locked out.
+func (l *Limiter) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	failures, last, err := l.Store.Failures(ctx, key)
+	if err != nil || failures == 0 {
+		return 0, err
+	}
+	if time.Since(last) > l.Lockout.Window {
+		return 0, nil
+	}
+	wait := time.Until(last.Add(l.Lockout.duration(failures)))
+	if wait < 0 {
+		return 0, nil
+	}
+	return wait, nil
+}
+
+// Fail records a failed attempt and returns how long the key is now locked.
+func (l *Limiter) Fail(ctx context.Context, key string) (time.Duration, error) {
+	failures, err := l.Store.AddFailure(ctx, key, l.Lockout.Window)
+	if err != nil {
+		return 0, err
+	}
+	return l.Lockout.duration(failures), nil
+}
+
+// Succeed forgets the key's failures.
+func (l *Limiter) Succeed(ctx context.Context, key string) error {
+	return l.Store.ClearFailures(ctx, key)
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var testLockout = Lockout{
+	Threshold: 3,
+	Base:      time.Minute,
+	Max:       10 * time.Minute,
+	Window:    time.Hour,
+}
+
+func TestLockoutDuration(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 2, want: 0},
+		{failures: 3, want: time.Minute},
+		{failures: 4, want: 2 * time.Minute},
+		{failures: 5, want: 4 * time.Minute},
+		{failures: 6, want: 8 * time.Minute},
+		{failures: 7, want: 10 * time.Minute},
+		{failures: 100, want: 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := testLockout.duration(tt.failures); got != tt.want {
+			t.Errorf("duration(%d) = %v; want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLimiterLockout(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		elapsed  time.Duration
+		locked   bool
+	}{
+		{name: "below threshold", failures: 2, locked: false},
+		{name: "at threshold", failures: 3, locked: true},
+		{name: "lockout expired", failures: 3, elapsed: time.Minute + time.Second, locked: false},
+		{name: "backoff outlasts base", failures: 4, elapsed: time.Minute + time.Second, locked: true},
+		{name: "backoff expired", failures: 4, elapsed: 2*time.Minute + time.Second, locked: false},
+		{name: "window passed", failures: 10, elapsed: time.Hour + time.Second, locked: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore()
+			l := &Limiter{Store: store, Lockout: testLockout}
+
+			for i := 0; i < tt.failures; i++ {
+				if _, err := l.Fail(ctx, "k"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			store.age("k", tt.elapsed)
+
+			wait, err := l.LockedFor(ctx, "k")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := wait > 0; got != tt.locked {
+				t.Errorf("LockedFor = %v; want locked %v", wait, tt.locked)
+			}
+		})
+	}
+}
+
+func TestLimiterSucceedClearsLockout(t *testing.T) {
+	ctx := context.Background()
+	l := &Limiter{Store: NewMemoryStore(), Lockout: testLockout}
+
+	for i := 0; i < testLockout.Threshold; i++ {
+		l.Fail(ctx, "k")
+	}
+	if err := l.Succeed(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := l.LockedFor(ctx, "k"); wait != 0 {
+		t.Errorf("LockedFor after Succeed = %v; want 0", wait)
+	}
+}
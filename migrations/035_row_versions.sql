@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Row versions back the ETag / If-Match handling of lists, payments and deposits.
+-- Every UPDATE issued by the API bumps the version, so a client holding a stale
+-- ETag gets 412 Precondition Failed instead of silently overwriting a change.
+ALTER TABLE public.lists
+  ADD COLUMN version integer NOT NULL DEFAULT 1;
+
+ALTER TABLE public.payments
+  ADD COLUMN version integer NOT NULL DEFAULT 1;
+
+ALTER TABLE public.deposits
+  ADD COLUMN version integer NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE public.deposits
+  DROP COLUMN IF EXISTS version;
+
+ALTER TABLE public.payments
+  DROP COLUMN IF EXISTS version;
+
+ALTER TABLE public.lists
+  DROP COLUMN IF EXISTS version;
+-- +goose StatementEnd
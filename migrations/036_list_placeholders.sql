@@ -0,0 +1,144 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Placeholder (guest) members: people who take part in a list's expenses but do
+-- not have an account. They only carry a display name and are scoped to a list.
+CREATE TABLE public.list_placeholders (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	list_id uuid NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+	display_name text NOT NULL,
+	created_at timestamptz DEFAULT now(),
+	created_by uuid REFERENCES users(id) ON DELETE SET NULL,
+	claimed_by uuid REFERENCES users(id) ON DELETE SET NULL,
+	claimed_at timestamptz
+);
+
+CREATE INDEX ON public.list_placeholders (list_id);
+
+ALTER TABLE public.list_placeholders ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY list_placeholders_members_only ON public.list_placeholders
+  USING (app.is_member(list_id))
+  WITH CHECK (app.is_member(list_id));
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE public.list_placeholders TO app_auth, app_admin;
+
+-- A payer / participant is either a user or a placeholder, never both.
+-- (Both may be NULL on old rows whose user was deleted.)
+ALTER TABLE public.payments
+  ADD COLUMN payer_placeholder_id uuid REFERENCES public.list_placeholders(id) ON DELETE RESTRICT,
+  ADD CONSTRAINT payments_single_payer
+    CHECK (num_nonnulls(payer_user_id, payer_placeholder_id) <= 1);
+
+ALTER TABLE public.divisions
+  ADD COLUMN owe_placeholder_id uuid REFERENCES public.list_placeholders(id) ON DELETE RESTRICT,
+  ADD CONSTRAINT divisions_single_owner
+    CHECK (num_nonnulls(owe_user_id, owe_placeholder_id) <= 1);
+
+ALTER TABLE public.deposits
+  ADD COLUMN payer_placeholder_id uuid REFERENCES public.list_placeholders(id) ON DELETE RESTRICT,
+  ADD COLUMN payee_placeholder_id uuid REFERENCES public.list_placeholders(id) ON DELETE RESTRICT,
+  ADD CONSTRAINT deposits_single_payer
+    CHECK (num_nonnulls(payer_user_id, payer_placeholder_id) <= 1),
+  ADD CONSTRAINT deposits_single_payee
+    CHECK (num_nonnulls(payee_user_id, payee_placeholder_id) <= 1);
+
+CREATE INDEX ON public.payments (payer_placeholder_id);
+CREATE INDEX ON public.divisions (owe_placeholder_id);
+CREATE INDEX ON public.deposits (payer_placeholder_id);
+CREATE INDEX ON public.deposits (payee_placeholder_id);
+
+-- Accept an invitation for the current user and, optionally, claim one of the
+-- list's placeholders. Claiming moves every payment, division and deposit of
+-- the placeholder over to the user. SECURITY DEFINER because the caller is not
+-- a member yet, so RLS would hide both the invitation and the placeholder.
+CREATE OR REPLACE FUNCTION app.accept_invitation(
+  _hash           text,
+  _placeholder_id uuid DEFAULT NULL
+) RETURNS uuid
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+DECLARE
+  _user_id uuid := app.current_user_id();
+  _inv     public.invitations%ROWTYPE;
+BEGIN
+  IF _user_id IS NULL THEN
+    RAISE EXCEPTION 'not_authenticated' USING ERRCODE = '28000';
+  END IF;
+
+  SELECT * INTO _inv FROM public.invitations WHERE hash = _hash FOR UPDATE;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'invitation_not_found' USING ERRCODE = 'P0002';
+  END IF;
+
+  IF _inv.revoked_at IS NOT NULL OR _inv.used_at IS NOT NULL OR _inv.expires_at < now() THEN
+    RAISE EXCEPTION 'invitation_not_usable' USING ERRCODE = '22023';
+  END IF;
+
+  INSERT INTO public.users_lists (user_id, list_id)
+  VALUES (_user_id, _inv.invited_to_list_id)
+  ON CONFLICT DO NOTHING;
+
+  IF _placeholder_id IS NOT NULL THEN
+    UPDATE public.list_placeholders
+    SET claimed_by = _user_id,
+        claimed_at = now()
+    WHERE id = _placeholder_id
+      AND list_id = _inv.invited_to_list_id
+      AND claimed_by IS NULL;
+    IF NOT FOUND THEN
+      RAISE EXCEPTION 'placeholder_not_claimable' USING ERRCODE = '22023';
+    END IF;
+
+    UPDATE public.payments
+    SET payer_user_id = _user_id, payer_placeholder_id = NULL, version = version + 1
+    WHERE payer_placeholder_id = _placeholder_id;
+
+    UPDATE public.divisions
+    SET owe_user_id = _user_id, owe_placeholder_id = NULL
+    WHERE owe_placeholder_id = _placeholder_id;
+
+    UPDATE public.deposits
+    SET payer_user_id = _user_id, payer_placeholder_id = NULL, version = version + 1
+    WHERE payer_placeholder_id = _placeholder_id;
+
+    UPDATE public.deposits
+    SET payee_user_id = _user_id, payee_placeholder_id = NULL, version = version + 1
+    WHERE payee_placeholder_id = _placeholder_id;
+  END IF;
+
+  UPDATE public.invitations
+  SET used_by = _user_id,
+      used_at = now()
+  WHERE id = _inv.id;
+
+  RETURN _inv.invited_to_list_id;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.accept_invitation(text, uuid) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.accept_invitation(text, uuid) TO app_auth;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS app.accept_invitation(text, uuid);
+
+ALTER TABLE public.deposits
+  DROP CONSTRAINT IF EXISTS deposits_single_payee,
+  DROP CONSTRAINT IF EXISTS deposits_single_payer,
+  DROP COLUMN IF EXISTS payee_placeholder_id,
+  DROP COLUMN IF EXISTS payer_placeholder_id;
+
+ALTER TABLE public.divisions
+  DROP CONSTRAINT IF EXISTS divisions_single_owner,
+  DROP COLUMN IF EXISTS owe_placeholder_id;
+
+ALTER TABLE public.payments
+  DROP CONSTRAINT IF EXISTS payments_single_payer,
+  DROP COLUMN IF EXISTS payer_placeholder_id;
+
+DROP TABLE IF EXISTS public.list_placeholders;
+-- +goose StatementEnd
@@ -0,0 +1,364 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Roles are declared from least to most privileged so they compare with >=.
+CREATE TYPE list_role AS ENUM ('viewer', 'member', 'admin', 'owner');
+
+ALTER TABLE public.users_lists
+  ADD COLUMN role list_role NOT NULL DEFAULT 'member';
+
+-- Existing memberships predate roles and nobody recorded who created a list.
+-- Everyone keeps managing their lists as an admin, and one member per list
+-- (whoever sent its first invitation, otherwise the lowest user id) becomes
+-- the owner.
+UPDATE public.users_lists SET role = 'admin';
+
+UPDATE public.users_lists ul
+SET role = 'owner'
+FROM (
+  SELECT DISTINCT ON (m.list_id) m.list_id, m.user_id
+  FROM public.users_lists m
+  LEFT JOIN public.invitations i
+    ON i.invited_to_list_id = m.list_id AND i.created_by = m.user_id
+  ORDER BY m.list_id, i.created_at NULLS LAST, m.user_id
+) o
+WHERE ul.list_id = o.list_id AND ul.user_id = o.user_id;
+
+CREATE UNIQUE INDEX users_lists_one_owner
+  ON public.users_lists (list_id)
+  WHERE role = 'owner';
+
+-- Who entered a payment / deposit; members may only change their own.
+ALTER TABLE public.payments
+  ADD COLUMN created_by uuid REFERENCES users(id) ON DELETE SET NULL DEFAULT app.current_user_id();
+
+ALTER TABLE public.deposits
+  ADD COLUMN created_by uuid REFERENCES users(id) ON DELETE SET NULL DEFAULT app.current_user_id();
+
+-- Best guess for rows entered before the column existed.
+UPDATE public.payments SET created_by = payer_user_id WHERE created_by IS NULL;
+UPDATE public.deposits SET created_by = payer_user_id WHERE created_by IS NULL;
+
+-- Helpers (SECURITY DEFINER so they can read users_lists from inside policies)
+CREATE OR REPLACE FUNCTION app.list_role(_list_id uuid)
+RETURNS list_role
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+  SELECT ul.role
+  FROM public.users_lists ul
+  WHERE ul.list_id = _list_id
+    AND ul.user_id = app.current_user_id()
+$$;
+
+CREATE OR REPLACE FUNCTION app.has_list_role(_list_id uuid, _min list_role)
+RETURNS boolean
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+  SELECT COALESCE(app.list_role(_list_id) >= _min, false)
+$$;
+
+-- Admins can change any entry of the list, members only the ones they created.
+CREATE OR REPLACE FUNCTION app.can_write_entry(_list_id uuid, _created_by uuid)
+RETURNS boolean
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+  SELECT app.has_list_role(_list_id, 'admin')
+      OR (app.has_list_role(_list_id, 'member') AND _created_by = app.current_user_id())
+$$;
+
+-- Hand a list over to another member; the previous owner becomes an admin.
+CREATE OR REPLACE FUNCTION app.transfer_list_ownership(_list_id uuid, _new_owner uuid)
+RETURNS void
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+BEGIN
+  IF NOT app.has_list_role(_list_id, 'owner') THEN
+    RAISE EXCEPTION 'not_list_owner' USING ERRCODE = '42501';
+  END IF;
+
+  IF _new_owner = app.current_user_id() THEN
+    RETURN;
+  END IF;
+
+  UPDATE public.users_lists
+  SET role = 'admin'
+  WHERE list_id = _list_id AND user_id = app.current_user_id();
+
+  UPDATE public.users_lists
+  SET role = 'owner'
+  WHERE list_id = _list_id AND user_id = _new_owner;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'member_not_found' USING ERRCODE = 'P0002';
+  END IF;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.transfer_list_ownership(uuid, uuid) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.transfer_list_ownership(uuid, uuid) TO app_auth;
+
+-- lists: admins edit, only the owner deletes
+DROP POLICY IF EXISTS lists_members_update ON public.lists;
+DROP POLICY IF EXISTS lists_members_delete ON public.lists;
+
+CREATE POLICY lists_admins_update ON public.lists
+  FOR UPDATE
+  USING (app.has_list_role(id, 'admin'))
+  WITH CHECK (app.has_list_role(id, 'admin'));
+
+CREATE POLICY lists_owner_delete ON public.lists
+  FOR DELETE
+  USING (app.has_list_role(id, 'owner'));
+
+-- users_lists: the creator inserts themselves as owner; everybody else joins
+-- through app.accept_invitation. Admins change roles and remove members, but
+-- nobody touches the owner row except app.transfer_list_ownership.
+DROP POLICY IF EXISTS users_lists_write          ON public.users_lists;
+DROP POLICY IF EXISTS users_lists_insert_members ON public.users_lists;
+DROP POLICY IF EXISTS users_lists_insert_initial ON public.users_lists;
+DROP POLICY IF EXISTS users_lists_delete         ON public.users_lists;
+
+CREATE POLICY users_lists_insert_initial ON public.users_lists
+  FOR INSERT
+  WITH CHECK (
+    app.current_user_id() IS NOT NULL
+    AND user_id = app.current_user_id()
+    AND role = 'owner'
+    AND NOT EXISTS (
+      SELECT 1
+      FROM public.users_lists ul2
+      WHERE ul2.list_id = users_lists.list_id
+    )
+  );
+
+CREATE POLICY users_lists_admins_update ON public.users_lists
+  FOR UPDATE
+  USING (app.has_list_role(list_id, 'admin') AND role <> 'owner')
+  WITH CHECK (role <> 'owner');
+
+CREATE POLICY users_lists_admins_delete ON public.users_lists
+  FOR DELETE
+  USING (app.has_list_role(list_id, 'admin') AND role <> 'owner');
+
+-- invitations: members see them, admins manage them
+DROP POLICY IF EXISTS invitations_member_insert  ON public.invitations;
+DROP POLICY IF EXISTS invitations_member_update  ON public.invitations;
+DROP POLICY IF EXISTS invitations_insert_members ON public.invitations;
+DROP POLICY IF EXISTS invitations_update_members ON public.invitations;
+DROP POLICY IF EXISTS invitations_delete_members ON public.invitations;
+
+CREATE POLICY invitations_admins_insert ON public.invitations
+  FOR INSERT
+  WITH CHECK (app.has_list_role(invited_to_list_id, 'admin') AND created_by = app.current_user_id());
+
+CREATE POLICY invitations_admins_update ON public.invitations
+  FOR UPDATE
+  USING (app.has_list_role(invited_to_list_id, 'admin'))
+  WITH CHECK (app.has_list_role(invited_to_list_id, 'admin'));
+
+CREATE POLICY invitations_admins_delete ON public.invitations
+  FOR DELETE
+  USING (app.has_list_role(invited_to_list_id, 'admin'));
+
+-- payments / divisions / deposits: every member reads, viewers cannot write
+DROP POLICY IF EXISTS payments_members_only  ON public.payments;
+DROP POLICY IF EXISTS divisions_members_only ON public.divisions;
+DROP POLICY IF EXISTS deposits_members_only  ON public.deposits;
+
+CREATE POLICY payments_members_select ON public.payments
+  FOR SELECT
+  USING (app.is_member(list_id));
+
+CREATE POLICY payments_members_insert ON public.payments
+  FOR INSERT
+  WITH CHECK (app.has_list_role(list_id, 'member') AND created_by = app.current_user_id());
+
+CREATE POLICY payments_writers_update ON public.payments
+  FOR UPDATE
+  USING (app.can_write_entry(list_id, created_by))
+  WITH CHECK (app.can_write_entry(list_id, created_by));
+
+CREATE POLICY payments_writers_delete ON public.payments
+  FOR DELETE
+  USING (app.can_write_entry(list_id, created_by));
+
+CREATE POLICY divisions_members_select ON public.divisions
+  FOR SELECT
+  USING (
+    EXISTS (
+      SELECT 1
+      FROM public.payments p
+      WHERE p.id = public.divisions.payment_id
+        AND app.is_member(p.list_id)
+    )
+  );
+
+CREATE POLICY divisions_writers_all ON public.divisions
+  FOR ALL
+  USING (
+    EXISTS (
+      SELECT 1
+      FROM public.payments p
+      WHERE p.id = public.divisions.payment_id
+        AND app.can_write_entry(p.list_id, p.created_by)
+    )
+  )
+  WITH CHECK (
+    EXISTS (
+      SELECT 1
+      FROM public.payments p
+      WHERE p.id = public.divisions.payment_id
+        AND app.can_write_entry(p.list_id, p.created_by)
+    )
+  );
+
+CREATE POLICY deposits_members_select ON public.deposits
+  FOR SELECT
+  USING (app.is_member(list_id));
+
+CREATE POLICY deposits_members_insert ON public.deposits
+  FOR INSERT
+  WITH CHECK (app.has_list_role(list_id, 'member') AND created_by = app.current_user_id());
+
+CREATE POLICY deposits_writers_update ON public.deposits
+  FOR UPDATE
+  USING (app.can_write_entry(list_id, created_by))
+  WITH CHECK (app.can_write_entry(list_id, created_by));
+
+CREATE POLICY deposits_writers_delete ON public.deposits
+  FOR DELETE
+  USING (app.can_write_entry(list_id, created_by));
+
+-- placeholders: members read, viewers cannot write
+DROP POLICY IF EXISTS list_placeholders_members_only ON public.list_placeholders;
+
+CREATE POLICY list_placeholders_members_select ON public.list_placeholders
+  FOR SELECT
+  USING (app.is_member(list_id));
+
+CREATE POLICY list_placeholders_writers_all ON public.list_placeholders
+  FOR ALL
+  USING (app.has_list_role(list_id, 'member'))
+  WITH CHECK (app.has_list_role(list_id, 'member'));
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP POLICY IF EXISTS list_placeholders_writers_all    ON public.list_placeholders;
+DROP POLICY IF EXISTS list_placeholders_members_select ON public.list_placeholders;
+CREATE POLICY list_placeholders_members_only ON public.list_placeholders
+  USING (app.is_member(list_id))
+  WITH CHECK (app.is_member(list_id));
+
+DROP POLICY IF EXISTS deposits_writers_delete  ON public.deposits;
+DROP POLICY IF EXISTS deposits_writers_update  ON public.deposits;
+DROP POLICY IF EXISTS deposits_members_insert  ON public.deposits;
+DROP POLICY IF EXISTS deposits_members_select  ON public.deposits;
+DROP POLICY IF EXISTS divisions_writers_all    ON public.divisions;
+DROP POLICY IF EXISTS divisions_members_select ON public.divisions;
+DROP POLICY IF EXISTS payments_writers_delete  ON public.payments;
+DROP POLICY IF EXISTS payments_writers_update  ON public.payments;
+DROP POLICY IF EXISTS payments_members_insert  ON public.payments;
+DROP POLICY IF EXISTS payments_members_select  ON public.payments;
+
+CREATE POLICY payments_members_only ON public.payments
+  USING (app.is_member(list_id))
+  WITH CHECK (app.is_member(list_id));
+
+CREATE POLICY divisions_members_only ON public.divisions
+  USING (
+    EXISTS (
+      SELECT 1
+      FROM public.payments p
+      WHERE p.id = public.divisions.payment_id
+        AND app.is_member(p.list_id)
+    )
+  )
+  WITH CHECK (
+    EXISTS (
+      SELECT 1
+      FROM public.payments p
+      WHERE p.id = public.divisions.payment_id
+        AND app.is_member(p.list_id)
+    )
+  );
+
+CREATE POLICY deposits_members_only ON public.deposits
+  USING (app.is_member(list_id))
+  WITH CHECK (app.is_member(list_id));
+
+DROP POLICY IF EXISTS invitations_admins_delete ON public.invitations;
+DROP POLICY IF EXISTS invitations_admins_update ON public.invitations;
+DROP POLICY IF EXISTS invitations_admins_insert ON public.invitations;
+
+CREATE POLICY invitations_insert_members ON public.invitations
+  FOR INSERT
+  WITH CHECK (app.is_member(invited_to_list_id));
+
+CREATE POLICY invitations_update_members ON public.invitations
+  FOR UPDATE
+  USING (app.is_member(invited_to_list_id))
+  WITH CHECK (app.is_member(invited_to_list_id));
+
+CREATE POLICY invitations_delete_members ON public.invitations
+  FOR DELETE
+  USING (app.is_member(invited_to_list_id));
+
+DROP POLICY IF EXISTS users_lists_admins_delete  ON public.users_lists;
+DROP POLICY IF EXISTS users_lists_admins_update  ON public.users_lists;
+DROP POLICY IF EXISTS users_lists_insert_initial ON public.users_lists;
+
+CREATE POLICY users_lists_insert_initial ON public.users_lists
+  FOR INSERT
+  WITH CHECK (
+    app.current_user_id() IS NOT NULL
+    AND user_id = app.current_user_id()
+    AND NOT EXISTS (
+      SELECT 1
+      FROM public.users_lists ul2
+      WHERE ul2.list_id = users_lists.list_id
+    )
+  );
+
+CREATE POLICY users_lists_write ON public.users_lists
+  FOR INSERT
+  WITH CHECK (user_id = app.current_user_id());
+
+CREATE POLICY users_lists_delete ON public.users_lists
+  FOR DELETE
+  USING (app.is_member(list_id));
+
+DROP POLICY IF EXISTS lists_owner_delete  ON public.lists;
+DROP POLICY IF EXISTS lists_admins_update ON public.lists;
+
+CREATE POLICY lists_members_update ON public.lists
+  FOR UPDATE
+  USING (app.is_member(id))
+  WITH CHECK (app.is_member(id));
+
+CREATE POLICY lists_members_delete ON public.lists
+  FOR DELETE
+  USING (app.is_member(id));
+
+DROP FUNCTION IF EXISTS app.transfer_list_ownership(uuid, uuid);
+DROP FUNCTION IF EXISTS app.can_write_entry(uuid, uuid);
+DROP FUNCTION IF EXISTS app.has_list_role(uuid, list_role);
+DROP FUNCTION IF EXISTS app.list_role(uuid);
+
+ALTER TABLE public.deposits DROP COLUMN IF EXISTS created_by;
+ALTER TABLE public.payments DROP COLUMN IF EXISTS created_by;
+
+DROP INDEX IF EXISTS public.users_lists_one_owner;
+ALTER TABLE public.users_lists DROP COLUMN IF EXISTS role;
+DROP TYPE IF EXISTS list_role;
+-- +goose StatementEnd
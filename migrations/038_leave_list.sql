@@ -0,0 +1,334 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Members who leave (or are removed from) a list keep their users_lists row so
+-- their payments stay attributed to them; left_at marks them as former members.
+ALTER TABLE public.users_lists
+  ADD COLUMN left_at timestamptz;
+
+-- Former members lose every permission on the list.
+CREATE OR REPLACE FUNCTION app.is_member(_list_id uuid)
+RETURNS boolean
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+  SELECT EXISTS (
+    SELECT 1
+    FROM public.users_lists ul
+    WHERE ul.list_id = _list_id
+      AND ul.user_id = app.current_user_id()
+      AND ul.left_at IS NULL
+  )
+$$;
+
+CREATE OR REPLACE FUNCTION app.list_role(_list_id uuid)
+RETURNS list_role
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+  SELECT ul.role
+  FROM public.users_lists ul
+  WHERE ul.list_id = _list_id
+    AND ul.user_id = app.current_user_id()
+    AND ul.left_at IS NULL
+$$;
+
+-- Leave a list. The owner has to hand the list over first.
+CREATE OR REPLACE FUNCTION app.leave_list(_list_id uuid)
+RETURNS void
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+DECLARE
+  _role list_role := app.list_role(_list_id);
+BEGIN
+  IF _role IS NULL THEN
+    RAISE EXCEPTION 'member_not_found' USING ERRCODE = 'P0002';
+  END IF;
+
+  IF _role = 'owner' THEN
+    RAISE EXCEPTION 'owner_cannot_leave' USING ERRCODE = '22023';
+  END IF;
+
+  UPDATE public.users_lists
+  SET left_at = now()
+  WHERE list_id = _list_id AND user_id = app.current_user_id();
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.leave_list(uuid) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.leave_list(uuid) TO app_auth;
+
+-- Remove another member. Admins can remove anyone but the owner.
+CREATE OR REPLACE FUNCTION app.remove_list_member(_list_id uuid, _user_id uuid)
+RETURNS void
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+DECLARE
+  _role list_role;
+BEGIN
+  IF NOT app.has_list_role(_list_id, 'admin') THEN
+    RAISE EXCEPTION 'not_list_admin' USING ERRCODE = '42501';
+  END IF;
+
+  SELECT role INTO _role
+  FROM public.users_lists
+  WHERE list_id = _list_id AND user_id = _user_id AND left_at IS NULL
+  FOR UPDATE;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'member_not_found' USING ERRCODE = 'P0002';
+  END IF;
+
+  IF _role = 'owner' THEN
+    RAISE EXCEPTION 'owner_cannot_leave' USING ERRCODE = '22023';
+  END IF;
+
+  UPDATE public.users_lists
+  SET left_at = now()
+  WHERE list_id = _list_id AND user_id = _user_id;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.remove_list_member(uuid, uuid) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.remove_list_member(uuid, uuid) TO app_auth;
+
+-- Ownership can only go to a current member.
+CREATE OR REPLACE FUNCTION app.transfer_list_ownership(_list_id uuid, _new_owner uuid)
+RETURNS void
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+BEGIN
+  IF NOT app.has_list_role(_list_id, 'owner') THEN
+    RAISE EXCEPTION 'not_list_owner' USING ERRCODE = '42501';
+  END IF;
+
+  IF _new_owner = app.current_user_id() THEN
+    RETURN;
+  END IF;
+
+  UPDATE public.users_lists
+  SET role = 'admin'
+  WHERE list_id = _list_id AND user_id = app.current_user_id();
+
+  UPDATE public.users_lists
+  SET role = 'owner'
+  WHERE list_id = _list_id AND user_id = _new_owner AND left_at IS NULL;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'member_not_found' USING ERRCODE = 'P0002';
+  END IF;
+END;
+$$;
+
+-- A former member who accepts a new invitation rejoins as a plain member.
+CREATE OR REPLACE FUNCTION app.accept_invitation(
+  _hash           text,
+  _placeholder_id uuid DEFAULT NULL
+) RETURNS uuid
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+DECLARE
+  _user_id uuid := app.current_user_id();
+  _inv     public.invitations%ROWTYPE;
+BEGIN
+  IF _user_id IS NULL THEN
+    RAISE EXCEPTION 'not_authenticated' USING ERRCODE = '28000';
+  END IF;
+
+  SELECT * INTO _inv FROM public.invitations WHERE hash = _hash FOR UPDATE;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'invitation_not_found' USING ERRCODE = 'P0002';
+  END IF;
+
+  IF _inv.revoked_at IS NOT NULL OR _inv.used_at IS NOT NULL OR _inv.expires_at < now() THEN
+    RAISE EXCEPTION 'invitation_not_usable' USING ERRCODE = '22023';
+  END IF;
+
+  INSERT INTO public.users_lists (user_id, list_id)
+  VALUES (_user_id, _inv.invited_to_list_id)
+  ON CONFLICT (user_id, list_id) DO UPDATE
+    SET left_at = NULL,
+        role    = 'member'
+    WHERE public.users_lists.left_at IS NOT NULL;
+
+  IF _placeholder_id IS NOT NULL THEN
+    UPDATE public.list_placeholders
+    SET claimed_by = _user_id,
+        claimed_at = now()
+    WHERE id = _placeholder_id
+      AND list_id = _inv.invited_to_list_id
+      AND claimed_by IS NULL;
+    IF NOT FOUND THEN
+      RAISE EXCEPTION 'placeholder_not_claimable' USING ERRCODE = '22023';
+    END IF;
+
+    UPDATE public.payments
+    SET payer_user_id = _user_id, payer_placeholder_id = NULL, version = version + 1
+    WHERE payer_placeholder_id = _placeholder_id;
+
+    UPDATE public.divisions
+    SET owe_user_id = _user_id, owe_placeholder_id = NULL
+    WHERE owe_placeholder_id = _placeholder_id;
+
+    UPDATE public.deposits
+    SET payer_user_id = _user_id, payer_placeholder_id = NULL, version = version + 1
+    WHERE payer_placeholder_id = _placeholder_id;
+
+    UPDATE public.deposits
+    SET payee_user_id = _user_id, payee_placeholder_id = NULL, version = version + 1
+    WHERE payee_placeholder_id = _placeholder_id;
+  END IF;
+
+  UPDATE public.invitations
+  SET used_by = _user_id,
+      used_at = now()
+  WHERE id = _inv.id;
+
+  RETURN _inv.invited_to_list_id;
+END;
+$$;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION app.accept_invitation(
+  _hash           text,
+  _placeholder_id uuid DEFAULT NULL
+) RETURNS uuid
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+DECLARE
+  _user_id uuid := app.current_user_id();
+  _inv     public.invitations%ROWTYPE;
+BEGIN
+  IF _user_id IS NULL THEN
+    RAISE EXCEPTION 'not_authenticated' USING ERRCODE = '28000';
+  END IF;
+
+  SELECT * INTO _inv FROM public.invitations WHERE hash = _hash FOR UPDATE;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'invitation_not_found' USING ERRCODE = 'P0002';
+  END IF;
+
+  IF _inv.revoked_at IS NOT NULL OR _inv.used_at IS NOT NULL OR _inv.expires_at < now() THEN
+    RAISE EXCEPTION 'invitation_not_usable' USING ERRCODE = '22023';
+  END IF;
+
+  INSERT INTO public.users_lists (user_id, list_id)
+  VALUES (_user_id, _inv.invited_to_list_id)
+  ON CONFLICT DO NOTHING;
+
+  IF _placeholder_id IS NOT NULL THEN
+    UPDATE public.list_placeholders
+    SET claimed_by = _user_id,
+        claimed_at = now()
+    WHERE id = _placeholder_id
+      AND list_id = _inv.invited_to_list_id
+      AND claimed_by IS NULL;
+    IF NOT FOUND THEN
+      RAISE EXCEPTION 'placeholder_not_claimable' USING ERRCODE = '22023';
+    END IF;
+
+    UPDATE public.payments
+    SET payer_user_id = _user_id, payer_placeholder_id = NULL, version = version + 1
+    WHERE payer_placeholder_id = _placeholder_id;
+
+    UPDATE public.divisions
+    SET owe_user_id = _user_id, owe_placeholder_id = NULL
+    WHERE owe_placeholder_id = _placeholder_id;
+
+    UPDATE public.deposits
+    SET payer_user_id = _user_id, payer_placeholder_id = NULL, version = version + 1
+    WHERE payer_placeholder_id = _placeholder_id;
+
+    UPDATE public.deposits
+    SET payee_user_id = _user_id, payee_placeholder_id = NULL, version = version + 1
+    WHERE payee_placeholder_id = _placeholder_id;
+  END IF;
+
+  UPDATE public.invitations
+  SET used_by = _user_id,
+      used_at = now()
+  WHERE id = _inv.id;
+
+  RETURN _inv.invited_to_list_id;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION app.transfer_list_ownership(_list_id uuid, _new_owner uuid)
+RETURNS void
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+BEGIN
+  IF NOT app.has_list_role(_list_id, 'owner') THEN
+    RAISE EXCEPTION 'not_list_owner' USING ERRCODE = '42501';
+  END IF;
+
+  IF _new_owner = app.current_user_id() THEN
+    RETURN;
+  END IF;
+
+  UPDATE public.users_lists
+  SET role = 'admin'
+  WHERE list_id = _list_id AND user_id = app.current_user_id();
+
+  UPDATE public.users_lists
+  SET role = 'owner'
+  WHERE list_id = _list_id AND user_id = _new_owner;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'member_not_found' USING ERRCODE = 'P0002';
+  END IF;
+END;
+$$;
+
+DROP FUNCTION IF EXISTS app.remove_list_member(uuid, uuid);
+DROP FUNCTION IF EXISTS app.leave_list(uuid);
+
+CREATE OR REPLACE FUNCTION app.list_role(_list_id uuid)
+RETURNS list_role
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+  SELECT ul.role
+  FROM public.users_lists ul
+  WHERE ul.list_id = _list_id
+    AND ul.user_id = app.current_user_id()
+$$;
+
+CREATE OR REPLACE FUNCTION app.is_member(_list_id uuid)
+RETURNS boolean
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+  SELECT EXISTS (
+    SELECT 1
+    FROM public.users_lists ul
+    WHERE ul.list_id = _list_id
+      AND ul.user_id = app.current_user_id()
+  )
+$$;
+
+-- Former members cannot be represented without left_at.
+DELETE FROM public.users_lists WHERE left_at IS NOT NULL;
+
+ALTER TABLE public.users_lists
+  DROP COLUMN IF EXISTS left_at;
+-- +goose StatementEnd
@@ -0,0 +1,147 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Closed lists are frozen: nothing but settlement deposits can be added, and
+-- they are hidden from the default list overview.
+ALTER TABLE public.lists
+  ADD COLUMN closed_at timestamptz,
+  ADD COLUMN closed_by uuid REFERENCES users(id) ON DELETE SET NULL;
+
+-- Settlement deposits pay off the plan stored when the list was closed.
+ALTER TABLE public.deposits
+  ADD COLUMN is_settlement boolean NOT NULL DEFAULT false;
+
+-- One snapshot per close: the final balances and the suggested transactions.
+-- Reopening and closing again stores a new snapshot; the old ones stay.
+CREATE TABLE public.list_settlements (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	list_id uuid NOT NULL REFERENCES lists(id) ON DELETE CASCADE,
+	balances jsonb NOT NULL,
+	transactions jsonb NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	created_by uuid REFERENCES users(id) ON DELETE SET NULL DEFAULT app.current_user_id()
+);
+
+CREATE INDEX ON public.list_settlements (list_id, created_at);
+
+ALTER TABLE public.list_settlements ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY list_settlements_members_select ON public.list_settlements
+  FOR SELECT
+  USING (app.is_member(list_id));
+
+CREATE POLICY list_settlements_owner_insert ON public.list_settlements
+  FOR INSERT
+  WITH CHECK (app.has_list_role(list_id, 'owner'));
+
+GRANT SELECT, INSERT ON TABLE public.list_settlements TO app_auth;
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE public.list_settlements TO app_admin;
+
+CREATE OR REPLACE FUNCTION app.list_is_open(_list_id uuid)
+RETURNS boolean
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+  SELECT NOT EXISTS (
+    SELECT 1
+    FROM public.lists l
+    WHERE l.id = _list_id
+      AND l.closed_at IS NOT NULL
+  )
+$$;
+
+-- Restrictive policies are ANDed with the role-based ones from 037, so a
+-- closed list stays read-only whatever the caller's role.
+CREATE POLICY payments_open_insert ON public.payments AS RESTRICTIVE
+  FOR INSERT
+  WITH CHECK (app.list_is_open(list_id));
+
+CREATE POLICY payments_open_update ON public.payments AS RESTRICTIVE
+  FOR UPDATE
+  USING (app.list_is_open(list_id));
+
+CREATE POLICY payments_open_delete ON public.payments AS RESTRICTIVE
+  FOR DELETE
+  USING (app.list_is_open(list_id));
+
+CREATE POLICY divisions_open_insert ON public.divisions AS RESTRICTIVE
+  FOR INSERT
+  WITH CHECK (
+    EXISTS (
+      SELECT 1
+      FROM public.payments p
+      WHERE p.id = public.divisions.payment_id
+        AND app.list_is_open(p.list_id)
+    )
+  );
+
+CREATE POLICY divisions_open_update ON public.divisions AS RESTRICTIVE
+  FOR UPDATE
+  USING (
+    EXISTS (
+      SELECT 1
+      FROM public.payments p
+      WHERE p.id = public.divisions.payment_id
+        AND app.list_is_open(p.list_id)
+    )
+  );
+
+CREATE POLICY divisions_open_delete ON public.divisions AS RESTRICTIVE
+  FOR DELETE
+  USING (
+    EXISTS (
+      SELECT 1
+      FROM public.payments p
+      WHERE p.id = public.divisions.payment_id
+        AND app.list_is_open(p.list_id)
+    )
+  );
+
+CREATE POLICY deposits_open_insert ON public.deposits AS RESTRICTIVE
+  FOR INSERT
+  WITH CHECK (app.list_is_open(list_id) OR is_settlement);
+
+CREATE POLICY deposits_open_update ON public.deposits AS RESTRICTIVE
+  FOR UPDATE
+  USING (app.list_is_open(list_id));
+
+CREATE POLICY deposits_open_delete ON public.deposits AS RESTRICTIVE
+  FOR DELETE
+  USING (app.list_is_open(list_id));
+
+CREATE POLICY list_placeholders_open_insert ON public.list_placeholders AS RESTRICTIVE
+  FOR INSERT
+  WITH CHECK (app.list_is_open(list_id));
+
+CREATE POLICY list_placeholders_open_delete ON public.list_placeholders AS RESTRICTIVE
+  FOR DELETE
+  USING (app.list_is_open(list_id));
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP POLICY IF EXISTS list_placeholders_open_delete ON public.list_placeholders;
+DROP POLICY IF EXISTS list_placeholders_open_insert ON public.list_placeholders;
+DROP POLICY IF EXISTS deposits_open_delete          ON public.deposits;
+DROP POLICY IF EXISTS deposits_open_update          ON public.deposits;
+DROP POLICY IF EXISTS deposits_open_insert          ON public.deposits;
+DROP POLICY IF EXISTS divisions_open_delete         ON public.divisions;
+DROP POLICY IF EXISTS divisions_open_update         ON public.divisions;
+DROP POLICY IF EXISTS divisions_open_insert         ON public.divisions;
+DROP POLICY IF EXISTS payments_open_delete          ON public.payments;
+DROP POLICY IF EXISTS payments_open_update          ON public.payments;
+DROP POLICY IF EXISTS payments_open_insert          ON public.payments;
+
+DROP FUNCTION IF EXISTS app.list_is_open(uuid);
+
+DROP TABLE IF EXISTS public.list_settlements;
+
+ALTER TABLE public.deposits
+  DROP COLUMN IF EXISTS is_settlement;
+
+ALTER TABLE public.lists
+  DROP COLUMN IF EXISTS closed_by,
+  DROP COLUMN IF EXISTS closed_at;
+-- +goose StatementEnd
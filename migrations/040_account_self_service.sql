@@ -0,0 +1,116 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE public.users
+  ADD COLUMN display_name text;
+
+-- New columns can only be appended to a view.
+CREATE OR REPLACE VIEW app.users_safe AS
+SELECT id, username, email, created_at, password_changed_at, last_login_at, display_name
+FROM public.users;
+
+-- Update the caller's own profile. NULL leaves a field unchanged; an empty
+-- display name clears it.
+CREATE OR REPLACE FUNCTION app.update_profile(
+  _username     text DEFAULT NULL,
+  _display_name text DEFAULT NULL
+) RETURNS void
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+BEGIN
+  UPDATE public.users
+  SET username     = COALESCE(_username, username),
+      display_name = CASE
+                       WHEN _display_name IS NULL THEN display_name
+                       ELSE NULLIF(_display_name, '')
+                     END
+  WHERE id = app.current_user_id();
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'user_not_found' USING ERRCODE = '02000';
+  END IF;
+EXCEPTION
+  WHEN unique_violation THEN
+    RAISE EXCEPTION 'username_taken' USING ERRCODE = '23505';
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.update_profile(text, text) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.update_profile(text, text) TO app_auth;
+
+-- Pending email changes. Only the sha256 of the token sent to the new address
+-- is stored; the address switches once the token comes back.
+CREATE TABLE app.email_change_requests (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  new_email text NOT NULL,
+  token_hash bytea NOT NULL UNIQUE,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  expires_at timestamptz NOT NULL,
+  used_at timestamptz
+);
+
+CREATE INDEX ON app.email_change_requests (user_id);
+CREATE INDEX ON app.email_change_requests (expires_at);
+
+GRANT SELECT, INSERT, UPDATE ON TABLE app.email_change_requests TO app_auth;
+
+-- Switch the caller's email to the one confirmed by the token. Every other
+-- pending request of the user is dropped with it.
+CREATE OR REPLACE FUNCTION app.confirm_email_change(_token_hash bytea)
+RETURNS text
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+DECLARE
+  _req app.email_change_requests%ROWTYPE;
+BEGIN
+  SELECT * INTO _req
+  FROM app.email_change_requests
+  WHERE token_hash = _token_hash
+    AND user_id = app.current_user_id()
+  FOR UPDATE;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'email_change_not_found' USING ERRCODE = 'P0002';
+  END IF;
+
+  IF _req.used_at IS NOT NULL OR _req.expires_at < now() THEN
+    RAISE EXCEPTION 'email_change_not_usable' USING ERRCODE = '22023';
+  END IF;
+
+  BEGIN
+    UPDATE public.users SET email = _req.new_email WHERE id = _req.user_id;
+  EXCEPTION
+    WHEN unique_violation THEN
+      RAISE EXCEPTION 'email_already_registered' USING ERRCODE = '23505';
+  END;
+
+  UPDATE app.email_change_requests
+  SET used_at = now()
+  WHERE user_id = _req.user_id AND used_at IS NULL;
+
+  RETURN _req.new_email;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.confirm_email_change(bytea) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.confirm_email_change(bytea) TO app_auth;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS app.confirm_email_change(bytea);
+DROP TABLE IF EXISTS app.email_change_requests;
+DROP FUNCTION IF EXISTS app.update_profile(text, text);
+
+DROP VIEW IF EXISTS app.users_safe;
+CREATE VIEW app.users_safe AS
+SELECT id, username, email, created_at, password_changed_at, last_login_at
+FROM public.users;
+GRANT SELECT ON app.users_safe TO app_auth;
+
+ALTER TABLE public.users
+  DROP COLUMN IF EXISTS display_name;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- When a session was last seen, so users can tell their devices apart.
+-- Updated by the auth middleware at most every few minutes.
+ALTER TABLE app.sessions
+  ADD COLUMN last_used_at timestamptz;
+
+UPDATE app.sessions SET last_used_at = created_at;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE app.sessions
+  DROP COLUMN IF EXISTS last_used_at;
+-- +goose StatementEnd
@@ -0,0 +1,70 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Password reset tokens. Like email changes, only the sha256 of the token that
+-- was mailed is stored, and each token can be used once.
+CREATE TABLE app.password_reset_tokens (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  token_hash bytea NOT NULL UNIQUE,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  expires_at timestamptz NOT NULL,
+  used_at timestamptz
+);
+
+CREATE INDEX ON app.password_reset_tokens (user_id);
+CREATE INDEX ON app.password_reset_tokens (expires_at);
+
+-- Tokens are only ever read back through app.reset_password.
+GRANT INSERT ON TABLE app.password_reset_tokens TO app_auth;
+
+-- Set a new password for the owner of the token. There is no current user
+-- here, the token is the only proof of identity. All other pending tokens of
+-- the user are spent with it.
+CREATE OR REPLACE FUNCTION app.reset_password(
+  _token_hash    bytea,
+  _password_hash text,
+  _password_algo text DEFAULT 'argon2id'
+) RETURNS uuid
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+DECLARE
+  _tok app.password_reset_tokens%ROWTYPE;
+BEGIN
+  SELECT * INTO _tok
+  FROM app.password_reset_tokens
+  WHERE token_hash = _token_hash
+  FOR UPDATE;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'password_reset_not_found' USING ERRCODE = 'P0002';
+  END IF;
+
+  IF _tok.used_at IS NOT NULL OR _tok.expires_at < now() THEN
+    RAISE EXCEPTION 'password_reset_not_usable' USING ERRCODE = '22023';
+  END IF;
+
+  UPDATE public.users
+  SET password_hash = _password_hash,
+      password_algo = _password_algo,
+      password_changed_at = now()
+  WHERE id = _tok.user_id;
+
+  UPDATE app.password_reset_tokens
+  SET used_at = now()
+  WHERE user_id = _tok.user_id AND used_at IS NULL;
+
+  RETURN _tok.user_id;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.reset_password(bytea, text, text) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.reset_password(bytea, text, text) TO app_auth;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS app.reset_password(bytea, text, text);
+DROP TABLE IF EXISTS app.password_reset_tokens;
+-- +goose StatementEnd
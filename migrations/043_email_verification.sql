@@ -0,0 +1,165 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE public.users
+  ADD COLUMN email_verified_at timestamptz;
+
+-- Accounts from before verification existed have been in use for a while;
+-- treat their addresses as confirmed rather than locking them out.
+UPDATE public.users SET email_verified_at = created_at;
+
+CREATE OR REPLACE VIEW app.users_safe AS
+SELECT id, username, email, created_at, password_changed_at, last_login_at, display_name, email_verified_at
+FROM public.users;
+
+-- Verification tokens carry the address they were sent to, so a token issued
+-- before an email change cannot verify the new address.
+CREATE TABLE app.email_verification_tokens (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  email text NOT NULL,
+  token_hash bytea NOT NULL UNIQUE,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  expires_at timestamptz NOT NULL,
+  used_at timestamptz
+);
+
+CREATE INDEX ON app.email_verification_tokens (user_id, created_at);
+CREATE INDEX ON app.email_verification_tokens (expires_at);
+
+GRANT SELECT, INSERT ON TABLE app.email_verification_tokens TO app_auth;
+
+-- Mark the address behind the token as verified. Like password resets this
+-- runs without a current user, the link may be opened on another device.
+CREATE OR REPLACE FUNCTION app.verify_email(_token_hash bytea)
+RETURNS uuid
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+DECLARE
+  _tok app.email_verification_tokens%ROWTYPE;
+BEGIN
+  SELECT * INTO _tok
+  FROM app.email_verification_tokens
+  WHERE token_hash = _token_hash
+  FOR UPDATE;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'email_verification_not_found' USING ERRCODE = 'P0002';
+  END IF;
+
+  IF _tok.used_at IS NOT NULL OR _tok.expires_at < now() THEN
+    RAISE EXCEPTION 'email_verification_not_usable' USING ERRCODE = '22023';
+  END IF;
+
+  UPDATE public.users
+  SET email_verified_at = COALESCE(email_verified_at, now())
+  WHERE id = _tok.user_id AND email = _tok.email;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'email_verification_not_usable' USING ERRCODE = '22023';
+  END IF;
+
+  UPDATE app.email_verification_tokens
+  SET used_at = now()
+  WHERE user_id = _tok.user_id AND used_at IS NULL;
+
+  RETURN _tok.user_id;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.verify_email(bytea) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.verify_email(bytea) TO app_auth;
+
+-- The confirmation link of an email change already proves the new address.
+CREATE OR REPLACE FUNCTION app.confirm_email_change(_token_hash bytea)
+RETURNS text
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+DECLARE
+  _req app.email_change_requests%ROWTYPE;
+BEGIN
+  SELECT * INTO _req
+  FROM app.email_change_requests
+  WHERE token_hash = _token_hash
+    AND user_id = app.current_user_id()
+  FOR UPDATE;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'email_change_not_found' USING ERRCODE = 'P0002';
+  END IF;
+
+  IF _req.used_at IS NOT NULL OR _req.expires_at < now() THEN
+    RAISE EXCEPTION 'email_change_not_usable' USING ERRCODE = '22023';
+  END IF;
+
+  BEGIN
+    UPDATE public.users
+    SET email = _req.new_email,
+        email_verified_at = now()
+    WHERE id = _req.user_id;
+  EXCEPTION
+    WHEN unique_violation THEN
+      RAISE EXCEPTION 'email_already_registered' USING ERRCODE = '23505';
+  END;
+
+  UPDATE app.email_change_requests
+  SET used_at = now()
+  WHERE user_id = _req.user_id AND used_at IS NULL;
+
+  RETURN _req.new_email;
+END;
+$$;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION app.confirm_email_change(_token_hash bytea)
+RETURNS text
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+DECLARE
+  _req app.email_change_requests%ROWTYPE;
+BEGIN
+  SELECT * INTO _req
+  FROM app.email_change_requests
+  WHERE token_hash = _token_hash
+    AND user_id = app.current_user_id()
+  FOR UPDATE;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'email_change_not_found' USING ERRCODE = 'P0002';
+  END IF;
+
+  IF _req.used_at IS NOT NULL OR _req.expires_at < now() THEN
+    RAISE EXCEPTION 'email_change_not_usable' USING ERRCODE = '22023';
+  END IF;
+
+  BEGIN
+    UPDATE public.users SET email = _req.new_email WHERE id = _req.user_id;
+  EXCEPTION
+    WHEN unique_violation THEN
+      RAISE EXCEPTION 'email_already_registered' USING ERRCODE = '23505';
+  END;
+
+  UPDATE app.email_change_requests
+  SET used_at = now()
+  WHERE user_id = _req.user_id AND used_at IS NULL;
+
+  RETURN _req.new_email;
+END;
+$$;
+
+DROP FUNCTION IF EXISTS app.verify_email(bytea);
+DROP TABLE IF EXISTS app.email_verification_tokens;
+
+DROP VIEW IF EXISTS app.users_safe;
+CREATE VIEW app.users_safe AS
+SELECT id, username, email, created_at, password_changed_at, last_login_at, display_name
+FROM public.users;
+GRANT SELECT ON app.users_safe TO app_auth;
+
+ALTER TABLE public.users
+  DROP COLUMN IF EXISTS email_verified_at;
+-- +goose StatementEnd
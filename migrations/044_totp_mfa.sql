@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+-- TOTP second factor. A row without confirmed_at is an enrollment in progress;
+-- login only asks for a code once the user proved their authenticator works.
+-- last_used_step is the 30 second window of the last accepted code, so a code
+-- cannot be replayed within its window.
+CREATE TABLE app.user_mfa (
+  user_id uuid PRIMARY KEY REFERENCES public.users(id) ON DELETE CASCADE,
+  totp_secret text NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  confirmed_at timestamptz,
+  last_used_step bigint
+);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE app.user_mfa TO app_auth;
+
+-- One-time recovery codes for when the authenticator is lost. Only their
+-- sha256 is stored.
+CREATE TABLE app.mfa_recovery_codes (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  code_hash bytea NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  used_at timestamptz,
+  UNIQUE (user_id, code_hash)
+);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE app.mfa_recovery_codes TO app_auth;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS app.mfa_recovery_codes;
+DROP TABLE IF EXISTS app.user_mfa;
+-- +goose StatementEnd
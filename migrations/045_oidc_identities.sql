@@ -0,0 +1,72 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Accounts at external OpenID Connect providers, keyed by the provider's
+-- stable subject rather than the email, which can change on either side.
+CREATE TABLE app.user_identities (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  provider text NOT NULL,
+  subject text NOT NULL,
+  email text,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  last_login_at timestamptz,
+  UNIQUE (provider, subject)
+);
+
+CREATE INDEX ON app.user_identities (user_id);
+
+GRANT SELECT, INSERT, UPDATE ON TABLE app.user_identities TO app_auth;
+
+-- Create an account for someone signing in through a provider for the first
+-- time. There is no password; one can be set later through a password reset.
+-- A taken username gets a short random suffix instead of failing the login.
+CREATE OR REPLACE FUNCTION app.register_oidc_user(
+  _email          text,
+  _username       text,
+  _email_verified boolean,
+  _provider       text,
+  _subject        text
+) RETURNS uuid
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+DECLARE
+  _id uuid;
+  _candidate text := _username;
+BEGIN
+  LOOP
+    BEGIN
+      INSERT INTO public.users (id, email, username, password_hash, password_algo, email_verified_at)
+      VALUES (
+        gen_random_uuid(), _email, _candidate, NULL, 'none',
+        CASE WHEN _email_verified THEN now() END
+      )
+      RETURNING id INTO _id;
+      EXIT;
+    EXCEPTION
+      WHEN unique_violation THEN
+        IF EXISTS (SELECT 1 FROM public.users WHERE email = _email) THEN
+          RAISE EXCEPTION 'email_already_registered' USING ERRCODE = '23505';
+        END IF;
+        _candidate := _username || '-' || substr(md5(random()::text), 1, 4);
+    END;
+  END LOOP;
+
+  INSERT INTO app.user_identities (user_id, provider, subject, email, last_login_at)
+  VALUES (_id, _provider, _subject, _email, now());
+
+  RETURN _id;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.register_oidc_user(text, text, boolean, text, text) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.register_oidc_user(text, text, boolean, text, text) TO app_auth;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS app.register_oidc_user(text, text, boolean, text, text);
+DROP TABLE IF EXISTS app.user_identities;
+-- +goose StatementEnd
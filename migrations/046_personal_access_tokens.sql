@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Long-lived tokens for scripts. Only the sha256 of the token is stored. An
+-- empty list_ids means the token works on all of the user's lists.
+CREATE TABLE app.personal_access_tokens (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  name text NOT NULL,
+  token_hash bytea NOT NULL UNIQUE,
+  scopes text[] NOT NULL,
+  list_ids uuid[] NOT NULL DEFAULT '{}',
+  created_at timestamptz NOT NULL DEFAULT now(),
+  expires_at timestamptz,
+  last_used_at timestamptz,
+  revoked_at timestamptz
+);
+
+CREATE INDEX ON app.personal_access_tokens (user_id);
+
+GRANT SELECT, INSERT, UPDATE ON TABLE app.personal_access_tokens TO app_auth;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS app.personal_access_tokens;
+-- +goose StatementEnd
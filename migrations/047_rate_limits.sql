@@ -0,0 +1,69 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Shared rate limit state, so limits hold across API instances. Rows are
+-- throwaway: a missing bucket is a full one and a missing failure row means
+-- no failures.
+CREATE UNLOGGED TABLE app.rate_limit_buckets (
+  key text PRIMARY KEY,
+  tokens double precision NOT NULL,
+  updated_at timestamptz NOT NULL
+);
+
+CREATE UNLOGGED TABLE app.rate_limit_failures (
+  key text PRIMARY KEY,
+  failures integer NOT NULL,
+  last_failure_at timestamptz NOT NULL
+);
+
+CREATE INDEX ON app.rate_limit_buckets (updated_at);
+CREATE INDEX ON app.rate_limit_failures (last_failure_at);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE app.rate_limit_buckets TO app_auth;
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE app.rate_limit_failures TO app_auth;
+
+-- Take a token from the key's bucket. Returns 0 if there was one, otherwise
+-- the seconds until there will be. The row lock serialises concurrent takes.
+CREATE OR REPLACE FUNCTION app.take_rate_limit_token(
+  _key              text,
+  _burst            integer,
+  _interval_seconds double precision
+) RETURNS double precision
+LANGUAGE plpgsql
+SET search_path = pg_catalog, app
+AS $$
+DECLARE
+  _tokens  double precision;
+  _updated timestamptz;
+BEGIN
+  INSERT INTO app.rate_limit_buckets (key, tokens, updated_at)
+  VALUES (_key, _burst, now())
+  ON CONFLICT (key) DO NOTHING;
+
+  SELECT tokens, updated_at INTO _tokens, _updated
+  FROM app.rate_limit_buckets
+  WHERE key = _key
+  FOR UPDATE;
+
+  _tokens := LEAST(_burst, _tokens + EXTRACT(EPOCH FROM now() - _updated) / _interval_seconds);
+
+  IF _tokens >= 1 THEN
+    UPDATE app.rate_limit_buckets SET tokens = _tokens - 1, updated_at = now() WHERE key = _key;
+    RETURN 0;
+  END IF;
+
+  UPDATE app.rate_limit_buckets SET tokens = _tokens, updated_at = now() WHERE key = _key;
+  RETURN (1 - _tokens) * _interval_seconds;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.take_rate_limit_token(text, integer, double precision) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.take_rate_limit_token(text, integer, double precision) TO app_auth;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS app.take_rate_limit_token(text, integer, double precision);
+DROP TABLE IF EXISTS app.rate_limit_failures;
+DROP TABLE IF EXISTS app.rate_limit_buckets;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Replace a stored hash with a stronger one after a successful login. Unlike
+-- app.update_user_password this is not a password change: password_changed_at
+-- stays, and nothing happens if the hash changed since it was verified.
+CREATE OR REPLACE FUNCTION app.rehash_user_password(
+  _user_id  uuid,
+  _old_hash text,
+  _new_hash text,
+  _new_algo text DEFAULT 'argon2id'
+) RETURNS boolean
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public
+AS $$
+BEGIN
+  UPDATE public.users
+  SET password_hash = _new_hash,
+      password_algo = _new_algo
+  WHERE id = _user_id AND password_hash = _old_hash;
+  RETURN FOUND;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.rehash_user_password(uuid, text, text, text) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.rehash_user_password(uuid, text, text, text) TO app_auth;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS app.rehash_user_password(uuid, text, text, text);
+-- +goose StatementEnd
@@ -0,0 +1,195 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Garbage collection for rows that are only kept while they can still be
+-- used. Each function deletes at most _limit rows that expired, were revoked
+-- or were used before _before and returns how many it deleted, so callers can
+-- work in short transactions until nothing is left.
+CREATE INDEX ON app.refresh_tokens (expires_at);
+CREATE INDEX ON app.personal_access_tokens (expires_at);
+
+-- Deleting a session deletes its refresh token chain with it.
+CREATE OR REPLACE FUNCTION app.purge_sessions(_before timestamptz, _limit integer) RETURNS bigint
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, app
+AS $$
+DECLARE
+  _n bigint;
+BEGIN
+  DELETE FROM app.sessions
+  WHERE id IN (
+    SELECT id FROM app.sessions
+    WHERE expires_at < _before OR revoked_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  RETURN _n;
+END;
+$$;
+
+-- Refresh tokens of live sessions: every refresh leaves the replaced token
+-- behind for reuse detection, which is pointless once it expired. parent_id
+-- cascades, so the children that stay are detached first or the live end of
+-- the chain would go with its ancestors.
+CREATE OR REPLACE FUNCTION app.purge_refresh_tokens(_before timestamptz, _limit integer) RETURNS bigint
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, app
+AS $$
+DECLARE
+  _ids uuid[];
+  _n   bigint;
+BEGIN
+  SELECT array_agg(id) INTO _ids
+  FROM (
+    SELECT id FROM app.refresh_tokens
+    WHERE expires_at < _before OR revoked_at < _before
+    LIMIT _limit
+  ) t;
+  IF _ids IS NULL THEN
+    RETURN 0;
+  END IF;
+
+  UPDATE app.refresh_tokens
+  SET parent_id = NULL
+  WHERE parent_id = ANY(_ids) AND NOT id = ANY(_ids);
+
+  DELETE FROM app.refresh_tokens WHERE id = ANY(_ids);
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  RETURN _n;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION app.purge_invitations(_before timestamptz, _limit integer) RETURNS bigint
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public
+AS $$
+DECLARE
+  _n bigint;
+BEGIN
+  DELETE FROM public.invitations
+  WHERE id IN (
+    SELECT id FROM public.invitations
+    WHERE expires_at < _before OR revoked_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  RETURN _n;
+END;
+$$;
+
+-- Password reset, email verification and email change tokens.
+CREATE OR REPLACE FUNCTION app.purge_account_tokens(_before timestamptz, _limit integer) RETURNS bigint
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, app
+AS $$
+DECLARE
+  _n     bigint;
+  _total bigint := 0;
+BEGIN
+  DELETE FROM app.password_reset_tokens
+  WHERE id IN (
+    SELECT id FROM app.password_reset_tokens
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.email_verification_tokens
+  WHERE id IN (
+    SELECT id FROM app.email_verification_tokens
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.email_change_requests
+  WHERE id IN (
+    SELECT id FROM app.email_change_requests
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  RETURN _total + _n;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION app.purge_personal_access_tokens(_before timestamptz, _limit integer) RETURNS bigint
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, app
+AS $$
+DECLARE
+  _n bigint;
+BEGIN
+  DELETE FROM app.personal_access_tokens
+  WHERE id IN (
+    SELECT id FROM app.personal_access_tokens
+    WHERE expires_at < _before OR revoked_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  RETURN _n;
+END;
+$$;
+
+-- Rate limit rows carry no history; anything idle since _before is as good
+-- as a full bucket or a forgotten failure.
+CREATE OR REPLACE FUNCTION app.purge_rate_limits(_before timestamptz, _limit integer) RETURNS bigint
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, app
+AS $$
+DECLARE
+  _n     bigint;
+  _total bigint;
+BEGIN
+  DELETE FROM app.rate_limit_buckets
+  WHERE key IN (
+    SELECT key FROM app.rate_limit_buckets
+    WHERE updated_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _total = ROW_COUNT;
+
+  DELETE FROM app.rate_limit_failures
+  WHERE key IN (
+    SELECT key FROM app.rate_limit_failures
+    WHERE last_failure_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  RETURN _total + _n;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.purge_sessions(timestamptz, integer) FROM PUBLIC;
+REVOKE ALL ON FUNCTION app.purge_refresh_tokens(timestamptz, integer) FROM PUBLIC;
+REVOKE ALL ON FUNCTION app.purge_invitations(timestamptz, integer) FROM PUBLIC;
+REVOKE ALL ON FUNCTION app.purge_account_tokens(timestamptz, integer) FROM PUBLIC;
+REVOKE ALL ON FUNCTION app.purge_personal_access_tokens(timestamptz, integer) FROM PUBLIC;
+REVOKE ALL ON FUNCTION app.purge_rate_limits(timestamptz, integer) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.purge_sessions(timestamptz, integer) TO app_auth;
+GRANT EXECUTE ON FUNCTION app.purge_refresh_tokens(timestamptz, integer) TO app_auth;
+GRANT EXECUTE ON FUNCTION app.purge_invitations(timestamptz, integer) TO app_auth;
+GRANT EXECUTE ON FUNCTION app.purge_account_tokens(timestamptz, integer) TO app_auth;
+GRANT EXECUTE ON FUNCTION app.purge_personal_access_tokens(timestamptz, integer) TO app_auth;
+GRANT EXECUTE ON FUNCTION app.purge_rate_limits(timestamptz, integer) TO app_auth;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS app.purge_rate_limits(timestamptz, integer);
+DROP FUNCTION IF EXISTS app.purge_personal_access_tokens(timestamptz, integer);
+DROP FUNCTION IF EXISTS app.purge_account_tokens(timestamptz, integer);
+DROP FUNCTION IF EXISTS app.purge_invitations(timestamptz, integer);
+DROP FUNCTION IF EXISTS app.purge_refresh_tokens(timestamptz, integer);
+DROP FUNCTION IF EXISTS app.purge_sessions(timestamptz, integer);
+DROP INDEX IF EXISTS app.personal_access_tokens_expires_at_idx;
+DROP INDEX IF EXISTS app.refresh_tokens_expires_at_idx;
+-- +goose StatementEnd
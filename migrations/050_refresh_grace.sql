@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- When a refresh token was replaced, so a reuse shortly after can be told
+-- apart from a stolen token showing up later.
+ALTER TABLE app.refresh_tokens ADD COLUMN replaced_at timestamptz;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE app.refresh_tokens DROP COLUMN IF EXISTS replaced_at;
+-- +goose StatementEnd
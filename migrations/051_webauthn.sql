@@ -0,0 +1,134 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Passkeys. credential_id is what the authenticator names the key by,
+-- public_key its COSE encoding. flags holds the raw authenticator flags byte
+-- the credential was registered with; the backup eligible bit must not change
+-- between logins. sign_count only ever goes up unless the key was cloned.
+CREATE TABLE app.webauthn_credentials (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  name text NOT NULL,
+  credential_id bytea NOT NULL UNIQUE,
+  public_key bytea NOT NULL,
+  attestation_type text NOT NULL,
+  transports text[] NOT NULL DEFAULT '{}',
+  aaguid bytea,
+  sign_count bigint NOT NULL DEFAULT 0,
+  flags smallint NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  last_used_at timestamptz
+);
+
+CREATE INDEX ON app.webauthn_credentials (user_id);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON TABLE app.webauthn_credentials TO app_auth;
+
+-- The server half of a registration or login ceremony between its start and
+-- finish requests. Each challenge is taken, and so deleted, exactly once.
+-- user_id is empty for a login that lets the authenticator pick the account.
+CREATE TABLE app.webauthn_challenges (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid REFERENCES public.users(id) ON DELETE CASCADE,
+  ceremony text NOT NULL CHECK (ceremony IN ('registration', 'login')),
+  session jsonb NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  expires_at timestamptz NOT NULL
+);
+
+CREATE INDEX ON app.webauthn_challenges (expires_at);
+
+GRANT SELECT, INSERT, DELETE ON TABLE app.webauthn_challenges TO app_auth;
+
+-- Abandoned ceremonies are purged with the other account tokens.
+CREATE OR REPLACE FUNCTION app.purge_account_tokens(_before timestamptz, _limit integer) RETURNS bigint
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, app
+AS $$
+DECLARE
+  _n     bigint;
+  _total bigint := 0;
+BEGIN
+  DELETE FROM app.password_reset_tokens
+  WHERE id IN (
+    SELECT id FROM app.password_reset_tokens
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.email_verification_tokens
+  WHERE id IN (
+    SELECT id FROM app.email_verification_tokens
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.email_change_requests
+  WHERE id IN (
+    SELECT id FROM app.email_change_requests
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.webauthn_challenges
+  WHERE id IN (
+    SELECT id FROM app.webauthn_challenges
+    WHERE expires_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  RETURN _total + _n;
+END;
+$$;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION app.purge_account_tokens(_before timestamptz, _limit integer) RETURNS bigint
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, app
+AS $$
+DECLARE
+  _n     bigint;
+  _total bigint := 0;
+BEGIN
+  DELETE FROM app.password_reset_tokens
+  WHERE id IN (
+    SELECT id FROM app.password_reset_tokens
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.email_verification_tokens
+  WHERE id IN (
+    SELECT id FROM app.email_verification_tokens
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.email_change_requests
+  WHERE id IN (
+    SELECT id FROM app.email_change_requests
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  RETURN _total + _n;
+END;
+$$;
+
+DROP TABLE IF EXISTS app.webauthn_challenges;
+DROP TABLE IF EXISTS app.webauthn_credentials;
+-- +goose StatementEnd
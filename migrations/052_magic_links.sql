@@ -0,0 +1,181 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Passwordless login links. Besides the token that was mailed, each link is
+-- bound to a nonce kept in a cookie of the browser that asked for it, so a
+-- forwarded or intercepted link is of no use elsewhere. Like verification
+-- tokens they carry the address they were sent to.
+CREATE TABLE app.magic_link_tokens (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  email text NOT NULL,
+  token_hash bytea NOT NULL UNIQUE,
+  nonce_hash bytea NOT NULL,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  expires_at timestamptz NOT NULL,
+  used_at timestamptz
+);
+
+CREATE INDEX ON app.magic_link_tokens (user_id);
+CREATE INDEX ON app.magic_link_tokens (expires_at);
+
+-- Tokens are only ever read back through app.consume_magic_link.
+GRANT INSERT ON TABLE app.magic_link_tokens TO app_auth;
+
+-- Spend a login link and return its user. A link opened in another browser
+-- is refused without being spent, so the user can still open it where they
+-- asked for it. Opening the link proves the address, so it is marked as
+-- verified on the way.
+CREATE OR REPLACE FUNCTION app.consume_magic_link(_token_hash bytea, _nonce_hash bytea)
+RETURNS uuid
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+DECLARE
+  _tok app.magic_link_tokens%ROWTYPE;
+BEGIN
+  SELECT * INTO _tok
+  FROM app.magic_link_tokens
+  WHERE token_hash = _token_hash
+  FOR UPDATE;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'magic_link_not_found' USING ERRCODE = 'P0002';
+  END IF;
+
+  IF _tok.used_at IS NOT NULL OR _tok.expires_at < now() THEN
+    RAISE EXCEPTION 'magic_link_not_usable' USING ERRCODE = '22023';
+  END IF;
+
+  IF _tok.nonce_hash <> _nonce_hash THEN
+    RAISE EXCEPTION 'magic_link_wrong_browser' USING ERRCODE = '42501';
+  END IF;
+
+  UPDATE public.users
+  SET email_verified_at = COALESCE(email_verified_at, now())
+  WHERE id = _tok.user_id AND email = _tok.email;
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'magic_link_not_usable' USING ERRCODE = '22023';
+  END IF;
+
+  UPDATE app.magic_link_tokens
+  SET used_at = now()
+  WHERE user_id = _tok.user_id AND used_at IS NULL;
+
+  RETURN _tok.user_id;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.consume_magic_link(bytea, bytea) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.consume_magic_link(bytea, bytea) TO app_auth;
+
+-- Login links are purged with the other account tokens.
+CREATE OR REPLACE FUNCTION app.purge_account_tokens(_before timestamptz, _limit integer) RETURNS bigint
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, app
+AS $$
+DECLARE
+  _n     bigint;
+  _total bigint := 0;
+BEGIN
+  DELETE FROM app.password_reset_tokens
+  WHERE id IN (
+    SELECT id FROM app.password_reset_tokens
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.email_verification_tokens
+  WHERE id IN (
+    SELECT id FROM app.email_verification_tokens
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.email_change_requests
+  WHERE id IN (
+    SELECT id FROM app.email_change_requests
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.webauthn_challenges
+  WHERE id IN (
+    SELECT id FROM app.webauthn_challenges
+    WHERE expires_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.magic_link_tokens
+  WHERE id IN (
+    SELECT id FROM app.magic_link_tokens
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  RETURN _total + _n;
+END;
+$$;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION app.purge_account_tokens(_before timestamptz, _limit integer) RETURNS bigint
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, app
+AS $$
+DECLARE
+  _n     bigint;
+  _total bigint := 0;
+BEGIN
+  DELETE FROM app.password_reset_tokens
+  WHERE id IN (
+    SELECT id FROM app.password_reset_tokens
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.email_verification_tokens
+  WHERE id IN (
+    SELECT id FROM app.email_verification_tokens
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.email_change_requests
+  WHERE id IN (
+    SELECT id FROM app.email_change_requests
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.webauthn_challenges
+  WHERE id IN (
+    SELECT id FROM app.webauthn_challenges
+    WHERE expires_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  RETURN _total + _n;
+END;
+$$;
+
+DROP FUNCTION IF EXISTS app.consume_magic_link(bytea, bytea);
+DROP TABLE IF EXISTS app.magic_link_tokens;
+-- +goose StatementEnd
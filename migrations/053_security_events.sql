@@ -0,0 +1,92 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Login history. Failed attempts are only recorded for existing accounts;
+-- session_id is set for successful ones and outlives the session.
+CREATE TABLE app.security_events (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  kind text NOT NULL CHECK (kind IN ('login_succeeded', 'login_failed')),
+  session_id uuid REFERENCES app.sessions(id) ON DELETE SET NULL,
+  ip text,
+  user_agent text,
+  browser text,
+  os text,
+  new_device boolean NOT NULL DEFAULT false,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX ON app.security_events (user_id, created_at DESC);
+CREATE INDEX ON app.security_events (created_at);
+
+GRANT SELECT, INSERT ON TABLE app.security_events TO app_auth;
+
+-- Devices a user has logged in from, by fingerprint, to tell which logins
+-- deserve an alert.
+CREATE TABLE app.known_devices (
+  user_id uuid NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  fingerprint bytea NOT NULL,
+  first_seen_at timestamptz NOT NULL DEFAULT now(),
+  last_seen_at timestamptz NOT NULL DEFAULT now(),
+  PRIMARY KEY (user_id, fingerprint)
+);
+
+GRANT SELECT, INSERT, UPDATE ON TABLE app.known_devices TO app_auth;
+
+-- The "this wasn't me" links of new device alerts. Only the sha256 of the
+-- token is stored; using it revokes the session the alert was about.
+CREATE TABLE app.device_alerts (
+  id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id uuid NOT NULL REFERENCES public.users(id) ON DELETE CASCADE,
+  session_id uuid NOT NULL REFERENCES app.sessions(id) ON DELETE CASCADE,
+  token_hash bytea NOT NULL UNIQUE,
+  created_at timestamptz NOT NULL DEFAULT now(),
+  expires_at timestamptz NOT NULL,
+  used_at timestamptz
+);
+
+CREATE INDEX ON app.device_alerts (expires_at);
+
+GRANT SELECT, INSERT, UPDATE ON TABLE app.device_alerts TO app_auth;
+
+-- History is kept for the retention period, alerts until they are of no use.
+CREATE OR REPLACE FUNCTION app.purge_security_events(_before timestamptz, _limit integer) RETURNS bigint
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, app
+AS $$
+DECLARE
+  _n     bigint;
+  _total bigint := 0;
+BEGIN
+  DELETE FROM app.security_events
+  WHERE id IN (
+    SELECT id FROM app.security_events
+    WHERE created_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  _total := _total + _n;
+
+  DELETE FROM app.device_alerts
+  WHERE id IN (
+    SELECT id FROM app.device_alerts
+    WHERE expires_at < _before OR used_at < _before
+    LIMIT _limit
+  );
+  GET DIAGNOSTICS _n = ROW_COUNT;
+  RETURN _total + _n;
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.purge_security_events(timestamptz, integer) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.purge_security_events(timestamptz, integer) TO app_auth;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS app.purge_security_events(timestamptz, integer);
+DROP TABLE IF EXISTS app.device_alerts;
+DROP TABLE IF EXISTS app.known_devices;
+DROP TABLE IF EXISTS app.security_events;
+-- +goose StatementEnd
@@ -0,0 +1,90 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The language mail and API messages are written in for the user. NULL
+-- follows the language of the request.
+ALTER TABLE public.users
+  ADD COLUMN language text CHECK (language IN ('en', 'es', 'fr'));
+
+CREATE OR REPLACE VIEW app.users_safe AS
+SELECT id, username, email, created_at, password_changed_at, last_login_at, display_name, email_verified_at, language
+FROM public.users;
+
+DROP FUNCTION IF EXISTS app.update_profile(text, text);
+
+-- Like the display name, an empty language clears it.
+CREATE OR REPLACE FUNCTION app.update_profile(
+  _username     text DEFAULT NULL,
+  _display_name text DEFAULT NULL,
+  _language     text DEFAULT NULL
+) RETURNS void
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+BEGIN
+  UPDATE public.users
+  SET username     = COALESCE(_username, username),
+      display_name = CASE
+                       WHEN _display_name IS NULL THEN display_name
+                       ELSE NULLIF(_display_name, '')
+                     END,
+      language     = CASE
+                       WHEN _language IS NULL THEN language
+                       ELSE NULLIF(_language, '')
+                     END
+  WHERE id = app.current_user_id();
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'user_not_found' USING ERRCODE = '02000';
+  END IF;
+EXCEPTION
+  WHEN unique_violation THEN
+    RAISE EXCEPTION 'username_taken' USING ERRCODE = '23505';
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.update_profile(text, text, text) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.update_profile(text, text, text) TO app_auth;
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP FUNCTION IF EXISTS app.update_profile(text, text, text);
+
+CREATE OR REPLACE FUNCTION app.update_profile(
+  _username     text DEFAULT NULL,
+  _display_name text DEFAULT NULL
+) RETURNS void
+LANGUAGE plpgsql
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+BEGIN
+  UPDATE public.users
+  SET username     = COALESCE(_username, username),
+      display_name = CASE
+                       WHEN _display_name IS NULL THEN display_name
+                       ELSE NULLIF(_display_name, '')
+                     END
+  WHERE id = app.current_user_id();
+  IF NOT FOUND THEN
+    RAISE EXCEPTION 'user_not_found' USING ERRCODE = '02000';
+  END IF;
+EXCEPTION
+  WHEN unique_violation THEN
+    RAISE EXCEPTION 'username_taken' USING ERRCODE = '23505';
+END;
+$$;
+
+REVOKE ALL ON FUNCTION app.update_profile(text, text) FROM PUBLIC;
+GRANT EXECUTE ON FUNCTION app.update_profile(text, text) TO app_auth;
+
+DROP VIEW IF EXISTS app.users_safe;
+CREATE VIEW app.users_safe AS
+SELECT id, username, email, created_at, password_changed_at, last_login_at, display_name, email_verified_at
+FROM public.users;
+GRANT SELECT ON app.users_safe TO app_auth;
+
+ALTER TABLE public.users
+  DROP COLUMN IF EXISTS language;
+-- +goose StatementEnd
@@ -0,0 +1,71 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Whether a deposit pays one of the transactions of a closed list's latest
+-- settlement that no settlement deposit has paid yet. Participants are known
+-- by their user ID, or the placeholder ID for guests, as in the snapshot.
+CREATE OR REPLACE FUNCTION app.settlement_outstanding(
+  _list_id uuid,
+  _from    uuid,
+  _to      uuid,
+  _amount  numeric
+) RETURNS boolean
+LANGUAGE sql
+STABLE
+SECURITY DEFINER
+SET search_path = pg_catalog, public, app
+AS $$
+  WITH settlement AS (
+    SELECT s.transactions, s.created_at
+    FROM public.lists l
+    JOIN public.list_settlements s ON s.list_id = l.id
+    WHERE l.id = _list_id
+      AND l.closed_at IS NOT NULL
+    ORDER BY s.created_at DESC
+    LIMIT 1
+  )
+  SELECT (
+    SELECT count(*)
+    FROM settlement s, jsonb_array_elements(s.transactions) t
+    WHERE (t->>'from')::uuid = _from
+      AND (t->>'to')::uuid = _to
+      AND round((t->>'amount')::numeric, 2) = _amount
+  ) > (
+    SELECT count(*)
+    FROM settlement s
+    JOIN public.deposits d ON d.list_id = _list_id
+    WHERE d.is_settlement
+      AND d.created_at >= s.created_at
+      AND COALESCE(d.payer_placeholder_id, d.payer_user_id) = _from
+      AND COALESCE(d.payee_placeholder_id, d.payee_user_id) = _to
+      AND d.amount = _amount
+  )
+$$;
+
+-- Settlement deposits only go into closed lists, and only to pay what the
+-- settlement says is still owed.
+DROP POLICY IF EXISTS deposits_open_insert ON public.deposits;
+CREATE POLICY deposits_open_insert ON public.deposits AS RESTRICTIVE
+  FOR INSERT
+  WITH CHECK (
+    CASE
+      WHEN is_settlement THEN app.settlement_outstanding(
+        list_id,
+        COALESCE(payer_placeholder_id, payer_user_id),
+        COALESCE(payee_placeholder_id, payee_user_id),
+        amount
+      )
+      ELSE app.list_is_open(list_id)
+    END
+  );
+-- +goose StatementEnd
+
+
+-- +goose Down
+-- +goose StatementBegin
+DROP POLICY IF EXISTS deposits_open_insert ON public.deposits;
+CREATE POLICY deposits_open_insert ON public.deposits AS RESTRICTIVE
+  FOR INSERT
+  WITH CHECK (app.list_is_open(list_id) OR is_settlement);
+
+DROP FUNCTION IF EXISTS app.settlement_outstanding(uuid, uuid, uuid, numeric);
+-- +goose StatementEnd